@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"os"
+	"testAlvtoShp/internal/config"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/service"
+	"testAlvtoShp/internal/store"
+)
+
+// adminPasswordEnv holds the password of an admin made by create-admin, so
+// that it does not show up in the process list.
+const adminPasswordEnv = "ADMIN_PASSWORD"
+
+// runCreateAdmin is the create-admin subcommand. It gives the user from
+// --username the admin role and creates the user with the password from
+// ADMIN_PASSWORD when it does not exist yet. The exit code is 1 on failure.
+func runCreateAdmin(ctx context.Context, db *sqlx.DB, cfg *config.Config, args []string) int {
+	log := logger.LoggerFromContext(ctx)
+
+	flags := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := flags.String("username", "", "the user to make an admin")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if *username == "" {
+		log.Errorw("--username is required")
+		return 1
+	}
+
+	bootstrap := service.NewAdminBootstrap(store.NewAuthStore(db), store.NewAdminStore(db),
+		service.NewPasswordHasher(cfg.PasswordHashAlgo), cfg)
+	if _, _, err := bootstrap.EnsureAdmin(ctx, *username, os.Getenv(adminPasswordEnv)); err != nil {
+		log.Errorw("failed to create admin", "username", *username, zap.Error(err))
+		return 1
+	}
+
+	return 0
+}
@@ -36,14 +36,44 @@ func main() {
 		return
 	}
 
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "reconcile":
+		code := runReconcile(ctx, dbConn, os.Args[2:])
+		if err = store.ShutDown(ctx, dbConn); err != nil && code == 0 {
+			code = reconcileFailed
+		}
+		os.Exit(code)
+	case len(os.Args) > 1 && os.Args[1] == "create-admin":
+		code := runCreateAdmin(ctx, dbConn, cfg, os.Args[2:])
+		if err = store.ShutDown(ctx, dbConn); err != nil && code == 0 {
+			code = 1
+		}
+		os.Exit(code)
+	case len(os.Args) > 1:
+		log.Fatalw("unknown subcommand", "subcommand", os.Args[1])
+	}
+
 	storeLevel := store.NewStore(dbConn)
-	serviceLevel := service.NewService(storeLevel, cfg)
+	if cfg.LoginAttemptsStore == service.LoginAttemptsMemory {
+		storeLevel.LoginAttempts = store.NewMemoryLoginAttemptStore()
+	}
+	serviceLevel, err := service.NewService(storeLevel, cfg)
+	if err != nil {
+		log.Fatalw("error with initializing service", zap.Error(err))
+		return
+	}
 	handlerLevel := handler.NewHandler(serviceLevel)
 
 	httpServer := new(server.Server)
 
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	if cfg.ReconcileInterval > 0 {
+		go serviceLevel.Reconciler.Run(jobsCtx, cfg.ReconcileInterval, cfg.ReconcileFix)
+	}
+
 	go func() {
-		if err = httpServer.InitServer(cfg.Port, handlerLevel.InitRoutes(ctx)); err != nil {
+		if err = httpServer.InitServer(cfg.Port, handlerLevel.InitRoutes(ctx, cfg)); err != nil {
 			log.Fatalw("error with initializing server", zap.Error(err))
 			return
 		}
@@ -55,6 +85,7 @@ func main() {
 	<-quit
 
 	log.Infow("shutting down server in port", "port", cfg.Port)
+	stopJobs()
 
 	if err = httpServer.Shutdown(ctx); err != nil {
 		log.Fatalw("error with shutting down server", zap.Error(err))
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"os"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/service"
+	"testAlvtoShp/internal/store"
+)
+
+// Exit codes of the reconcile subcommand.
+const (
+	reconcileFailed    = 1
+	reconcileDriftLeft = 2
+)
+
+// runReconcile is the reconcile subcommand. It compares the cached balances
+// with the ledger once and prints the report as JSON to stdout. Without
+// --fix it is a dry run, that only reports. The exit code is
+// reconcileDriftLeft when some balance still drifts afterwards.
+func runReconcile(ctx context.Context, db *sqlx.DB, args []string) int {
+	log := logger.LoggerFromContext(ctx)
+
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "reset the drifted balances to the ledger")
+	dryRun := flags.Bool("dry-run", false, "only report the drifted balances, the default")
+	if err := flags.Parse(args); err != nil {
+		return reconcileFailed
+	}
+	if *fix && *dryRun {
+		log.Errorw("--fix and --dry-run exclude each other")
+		return reconcileFailed
+	}
+
+	report, err := service.NewReconcileService(store.NewLedgerStore(db)).Reconcile(ctx, *fix)
+	if err != nil {
+		log.Errorw("failed to reconcile balances", zap.Error(err))
+		return reconcileFailed
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	if err = out.Encode(report); err != nil {
+		log.Errorw("failed to write report", zap.Error(err))
+		return reconcileFailed
+	}
+
+	for _, drift := range report.Drifted {
+		if drift.Drift != 0 && !drift.Fixed {
+			return reconcileDriftLeft
+		}
+	}
+	return 0
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/ilyakaznacheev/cleanenv"
 	"go.uber.org/zap"
 	"testAlvtoShp/internal/logger"
+	"time"
 )
 
 type Config struct {
@@ -15,6 +16,70 @@ type Config struct {
 	DbName    string `env:"DATABASE_NAME"`
 	JwtSecret string `env:"JWT_SECRET"`
 	Port      string `env:"SERVER_PORT"`
+
+	// PasswordHashAlgo is either "argon2id" or "bcrypt"
+	PasswordHashAlgo string `env:"PASSWORD_HASH_ALGO" env-default:"argon2id"`
+
+	// JwtKeys is a comma separated list of kid=source, source is a PEM file
+	// path or env:VAR_NAME. JwtSigningKid selects the key new tokens are
+	// signed with, the HMAC key from JwtSecret has kid "default".
+	JwtKeys       string `env:"JWT_KEYS"`
+	JwtSigningKid string `env:"JWT_SIGNING_KID" env-default:"default"`
+
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" env-default:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" env-default:"720h"`
+
+	// RegistrationMode is "open", "explicit" or "invite"
+	RegistrationMode string        `env:"REGISTRATION_MODE" env-default:"open"`
+	InviteTTL        time.Duration `env:"INVITE_TTL" env-default:"168h"`
+
+	// WelcomeBonus is the starting balance of every new user
+	WelcomeBonus int `env:"WELCOME_BONUS" env-default:"1000"`
+
+	// Failed logins are counted per username and per client IP within
+	// LoginFailureWindow. Every failure delays the next attempt by
+	// LoginBackoffBase doubled per failure, reaching the max failures locks
+	// the key for LoginLockout, doubled for every further failure.
+	// LoginAttemptsStore is "postgres" or "memory".
+	LoginMaxFailures      int           `env:"LOGIN_MAX_FAILURES" env-default:"5"`
+	LoginMaxFailuresPerIP int           `env:"LOGIN_MAX_FAILURES_PER_IP" env-default:"50"`
+	LoginBackoffBase      time.Duration `env:"LOGIN_BACKOFF_BASE" env-default:"1s"`
+	LoginLockout          time.Duration `env:"LOGIN_LOCKOUT" env-default:"15m"`
+	LoginFailureWindow    time.Duration `env:"LOGIN_FAILURE_WINDOW" env-default:"1h"`
+	LoginAttemptsStore    string        `env:"LOGIN_ATTEMPTS_STORE" env-default:"postgres"`
+
+	// TrustedProxies is a comma separated list of the IPs and CIDRs of the
+	// proxies in front of the server. The client IP is only taken from
+	// X-Forwarded-For and X-Real-IP of a request that came through one of
+	// them, without proxies it is always the remote address.
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	// IdempotencyKeyTTL is how long the response to a request made with an
+	// Idempotency-Key is replayed for
+	IdempotencyKeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" env-default:"24h"`
+
+	// ReturnWindow is how long after a purchase the order may be returned
+	ReturnWindow time.Duration `env:"RETURN_WINDOW" env-default:"336h"`
+
+	// InfoHistoryLimit caps the received and the sent coin transactions in
+	// the user info, the newest ones are shown, the rest is in /api/transactions
+	InfoHistoryLimit int `env:"INFO_HISTORY_LIMIT" env-default:"50"`
+
+	// CoinRequestTTL is how long a request for coins can be accepted by the
+	// user it is made to
+	CoinRequestTTL time.Duration `env:"COIN_REQUEST_TTL" env-default:"72h"`
+
+	// ReconcileInterval is how often the server compares the cached balances
+	// with the ledger, 0 turns the job off. With ReconcileFix the job also
+	// resets drifted balances to the ledger, otherwise it only logs them.
+	ReconcileInterval time.Duration `env:"RECONCILE_INTERVAL" env-default:"1h"`
+	ReconcileFix      bool          `env:"RECONCILE_FIX" env-default:"false"`
+
+	// LegacyBuyGet keeps GET /api/buy/:item as an alias of the POST route.
+	// Its responses announce the deprecation and LegacyBuyGetSunset, the
+	// date the alias goes away.
+	LegacyBuyGet       bool      `env:"LEGACY_BUY_GET" env-default:"false"`
+	LegacyBuyGetSunset time.Time `env:"LEGACY_BUY_GET_SUNSET" env-layout:"2006-01-02" env-default:"2027-01-01"`
 }
 
 func InitConfig(ctx context.Context) *Config {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -67,4 +68,27 @@ func TestInitConfig_Success(t *testing.T) {
 	assert.Equal(t, "testdb", cfg.DbName)
 	assert.Equal(t, "mysecret", cfg.JwtSecret)
 	assert.Equal(t, "8080", cfg.Port)
+	assert.Equal(t, "argon2id", cfg.PasswordHashAlgo)
+	assert.Equal(t, "", cfg.JwtKeys)
+	assert.Equal(t, "default", cfg.JwtSigningKid)
+	assert.Equal(t, 15*time.Minute, cfg.AccessTokenTTL)
+	assert.Equal(t, 720*time.Hour, cfg.RefreshTokenTTL)
+	assert.Equal(t, "open", cfg.RegistrationMode)
+	assert.Equal(t, 168*time.Hour, cfg.InviteTTL)
+	assert.Equal(t, 1000, cfg.WelcomeBonus)
+	assert.Equal(t, 5, cfg.LoginMaxFailures)
+	assert.Equal(t, 50, cfg.LoginMaxFailuresPerIP)
+	assert.Equal(t, time.Second, cfg.LoginBackoffBase)
+	assert.Equal(t, 15*time.Minute, cfg.LoginLockout)
+	assert.Equal(t, time.Hour, cfg.LoginFailureWindow)
+	assert.Equal(t, "postgres", cfg.LoginAttemptsStore)
+	assert.Empty(t, cfg.TrustedProxies)
+	assert.Equal(t, 24*time.Hour, cfg.IdempotencyKeyTTL)
+	assert.Equal(t, 336*time.Hour, cfg.ReturnWindow)
+	assert.Equal(t, 50, cfg.InfoHistoryLimit)
+	assert.Equal(t, 72*time.Hour, cfg.CoinRequestTTL)
+	assert.Equal(t, time.Hour, cfg.ReconcileInterval)
+	assert.False(t, cfg.ReconcileFix)
+	assert.False(t, cfg.LegacyBuyGet)
+	assert.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), cfg.LegacyBuyGetSunset)
 }
@@ -3,5 +3,63 @@ package errors
 import "errors"
 
 var (
-	NoMoney = errors.New("U havent enough money")
+	NoMoney           = errors.New("U havent enough money")
+	UnknownHashFormat = errors.New("unknown password hash format")
+
+	InvalidRefreshToken = errors.New("invalid refresh token")
+	RefreshTokenReused  = errors.New("refresh token reused")
+	SessionRevoked      = errors.New("session revoked")
+
+	UserExists      = errors.New("user already exists")
+	UnknownUser     = errors.New("unknown user")
+	InvalidUsername = errors.New("invalid username")
+	InvalidInvite   = errors.New("invalid or used invite code")
+
+	UserNotFound = errors.New("user not found")
+	InvalidRole  = errors.New("invalid role")
+	RoleChanged  = errors.New("role changed since the token was issued")
+
+	InvalidAmount          = errors.New("invalid amount")
+	InvalidReason          = errors.New("invalid reason")
+	IdempotencyKeyRequired = errors.New("idempotency key is required")
+	IdempotencyKeyReused   = errors.New("idempotency key was used for a different request")
+
+	InvalidIdempotencyKey    = errors.New("invalid idempotency key")
+	IdempotencyKeyInProgress = errors.New("request with this idempotency key is in progress")
+
+	InvalidItem  = errors.New("invalid item")
+	InvalidPrice = errors.New("price must be positive")
+	ItemExists   = errors.New("item already exists")
+	ItemNotFound = errors.New("item not found")
+
+	SoldOut              = errors.New("item is sold out")
+	PurchaseLimitReached = errors.New("purchase limit for the item reached")
+	InvalidCart          = errors.New("invalid cart")
+
+	OrderNotFound      = errors.New("order not found")
+	ReturnWindowClosed = errors.New("return window is closed")
+	ReturnExists       = errors.New("order already has a return")
+	ReturnNotFound     = errors.New("return not found")
+	ReturnDecided      = errors.New("return is already decided")
+	ReturnItemsMissing = errors.New("returned items are no longer in the inventory")
+	GiftNotReturnable  = errors.New("gifts can not be returned")
+
+	GiftToSelf = errors.New("can not gift to yourself")
+
+	InvalidMemo     = errors.New("invalid memo")
+	InvalidTransfer = errors.New("invalid transfer")
+	TransferToSelf  = errors.New("can not transfer to yourself")
+	NotEnoughItems  = errors.New("not enough items in the inventory")
+
+	InvalidCoinRequest  = errors.New("invalid coin request")
+	CoinRequestToSelf   = errors.New("can not request coins from yourself")
+	CoinRequestNotFound = errors.New("coin request not found")
+	CoinRequestDecided  = errors.New("coin request is already decided")
+	CoinRequestExpired  = errors.New("coin request has expired")
+
+	UnbalancedEntry       = errors.New("journal entry is not balanced")
+	NegativeLedgerBalance = errors.New("ledger balance of the user is negative")
+
+	InvalidCursor = errors.New("invalid cursor")
+	InvalidFilter = errors.New("invalid filter")
 )
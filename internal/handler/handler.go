@@ -5,12 +5,67 @@ import (
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.uber.org/zap"
 	"net/http"
 	_ "testAlvtoShp/docs"
+	"testAlvtoShp/internal/config"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/service"
 )
 
+// Machine readable codes returned in models.ErrorResponse
+const (
+	codeUnknownUser     = "unknown_user"
+	codeUserExists      = "user_exists"
+	codeInvalidUsername = "invalid_username"
+	codeInvalidInvite   = "invalid_invite"
+	codeTooManyAttempts = "too_many_attempts"
+	codeForbidden       = "forbidden"
+	codeUserNotFound    = "user_not_found"
+	codeInvalidRole     = "invalid_role"
+
+	codeInvalidAmount          = "invalid_amount"
+	codeInvalidReason          = "invalid_reason"
+	codeInsufficientBalance    = "insufficient_balance"
+	codeIdempotencyKeyRequired = "idempotency_key_required"
+	codeIdempotencyKeyReused   = "idempotency_key_reused"
+
+	codeInvalidIdempotencyKey    = "invalid_idempotency_key"
+	codeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+
+	codeInvalidItem  = "invalid_item"
+	codeInvalidPrice = "invalid_price"
+	codeItemExists   = "item_exists"
+	codeItemNotFound = "item_not_found"
+
+	codeSoldOut              = "sold_out"
+	codePurchaseLimitReached = "purchase_limit_reached"
+	codeInvalidCart          = "invalid_cart"
+
+	codeOrderNotFound      = "order_not_found"
+	codeReturnNotFound     = "return_not_found"
+	codeReturnWindowClosed = "return_window_closed"
+	codeReturnExists       = "return_exists"
+	codeReturnDecided      = "return_decided"
+	codeReturnItemsMissing = "return_items_missing"
+	codeGiftNotReturnable  = "gift_not_returnable"
+	codeGiftToSelf         = "gift_to_self"
+
+	codeInvalidMemo     = "invalid_memo"
+	codeInvalidTransfer = "invalid_transfer"
+	codeTransferToSelf  = "transfer_to_self"
+	codeNotEnoughItems  = "not_enough_items"
+
+	codeInvalidCoinRequest  = "invalid_coin_request"
+	codeCoinRequestToSelf   = "coin_request_to_self"
+	codeCoinRequestNotFound = "coin_request_not_found"
+	codeCoinRequestDecided  = "coin_request_decided"
+	codeCoinRequestExpired  = "coin_request_expired"
+
+	codeInvalidCursor = "invalid_cursor"
+	codeInvalidFilter = "invalid_filter"
+)
+
 type Handler struct {
 	service *service.Service
 }
@@ -21,20 +76,58 @@ func NewHandler(service *service.Service) *Handler {
 	}
 }
 
-func (h *Handler) InitRoutes(ctx context.Context) http.Handler {
+func (h *Handler) InitRoutes(ctx context.Context, cfg *config.Config) http.Handler {
 	gin.SetMode(gin.ReleaseMode)
 	log := logger.LoggerFromContext(ctx)
 
 	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalw("invalid trusted proxies", zap.Error(err))
+	}
 	router.Use(gin.Recovery(), logger.LoggerMiddleware(log))
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/.well-known/jwks.json", h.GetJWKS)
 	api := router.Group("/api")
 	{
 		api.POST("/auth", h.GetAuthToken)
-		api.Use(h.CheckAuth).GET("/info", h.GetUserInfo)
-		api.Use(h.CheckAuth).GET("/buy/:item", h.BuyItem)
-		api.Use(h.CheckAuth).POST("/sendCoin", h.SendCoin)
+		api.POST("/auth/refresh", h.RefreshToken)
+		api.POST("/register", h.Register)
+		api.GET("/items", h.GetItems)
+
+		authorized := api.Group("", h.CheckAuth)
+		authorized.POST("/auth/logout", h.Logout)
+		authorized.POST("/auth/logout/all", h.LogoutAll)
+		authorized.POST("/invites", h.CreateInvite)
+		authorized.GET("/info", h.GetUserInfo)
+		authorized.POST("/buy/:item", h.Idempotent, h.BuyItem)
+		if cfg.LegacyBuyGet {
+			authorized.GET("/buy/:item", h.LegacyBuyItem(cfg.LegacyBuyGetSunset), h.Idempotent, h.BuyItem)
+		}
+		authorized.POST("/checkout", h.Idempotent, h.Checkout)
+		authorized.POST("/gift", h.Idempotent, h.Gift)
+		authorized.POST("/sendCoin", h.Idempotent, h.SendCoin)
+		authorized.POST("/inventory/transfer", h.Idempotent, h.TransferItem)
+		authorized.GET("/orders", h.GetOrders)
+		authorized.GET("/transactions", h.GetTransactions)
+		authorized.POST("/orders/:id/return", h.RequestReturn)
+		authorized.POST("/coinRequests", h.Idempotent, h.RequestCoins)
+		authorized.POST("/coinRequests/:id/accept", h.Idempotent, h.AcceptCoinRequest)
+		authorized.POST("/coinRequests/:id/decline", h.DeclineCoinRequest)
 
+		admin := authorized.Group("/admin")
+		admin.GET("/users/:username", h.RequireRole(service.RoleAdmin, service.RoleAuditor), h.GetUser)
+		admin.PUT("/users/:id/role", h.RequireRole(service.RoleAdmin), h.SetUserRole)
+		admin.POST("/users/:id/coins/grant", h.RequireRole(service.RoleAdmin), h.GrantCoins)
+		admin.POST("/users/:id/coins/deduct", h.RequireRole(service.RoleAdmin), h.DeductCoins)
+		admin.PUT("/users/:id/coins", h.RequireRole(service.RoleAdmin), h.SetBalance)
+		admin.POST("/items", h.RequireRole(service.RoleAdmin), h.CreateItem)
+		admin.PUT("/items/:name/price", h.RequireRole(service.RoleAdmin), h.SetItemPrice)
+		admin.PUT("/items/:name/limits", h.RequireRole(service.RoleAdmin), h.SetItemLimits)
+		admin.DELETE("/items/:name", h.RequireRole(service.RoleAdmin), h.RetireItem)
+		admin.GET("/items/:name/prices", h.RequireRole(service.RoleAdmin, service.RoleAuditor), h.GetPriceHistory)
+		admin.GET("/returns", h.RequireRole(service.RoleAdmin, service.RoleAuditor), h.GetReturns)
+		admin.POST("/returns/:id/approve", h.RequireRole(service.RoleAdmin), h.ApproveReturn)
+		admin.POST("/returns/:id/reject", h.RequireRole(service.RoleAdmin), h.RejectReturn)
 	}
 	return router
 }
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+)
+
+// @Summary GetUser
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description look up a user, admin or auditor only
+// @ID admin-get-user
+// @Produce json
+// @Param username path string true "username"
+// @Success 200 {object} models.AdminUserResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{username} [get]
+func (h *Handler) GetUser(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+
+	user, err := h.service.GetUser(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		if errors.Is(err, internalErrors.UserNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error: "User not found",
+				Code:  codeUserNotFound,
+			})
+			return
+		}
+		log.Errorw("GetUser", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error getting user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// @Summary SetUserRole
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description change the role of a user, admin only
+// @ID admin-set-user-role
+// @Accept json
+// @Produce json
+// @Param id path int true "user id"
+// @Param input body models.SetRoleRequest true "new role"
+// @Success 200 {object} nil
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{id}/role [put]
+func (h *Handler) SetUserRole(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect user id",
+		})
+		return
+	}
+
+	var req models.SetRoleRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	err = h.service.SetUserRole(c.Request.Context(), c.GetInt("userId"), userId, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.InvalidRole):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Invalid role",
+				Code:  codeInvalidRole,
+			})
+		case errors.Is(err, internalErrors.UserNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error: "User not found",
+				Code:  codeUserNotFound,
+			})
+		default:
+			log.Errorw("SetUserRole", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Error setting user role",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary GrantCoins
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description credit coins to a user, admin only
+// @ID admin-grant-coins
+// @Accept json
+// @Produce json
+// @Param id path int true "user id"
+// @Param Idempotency-Key header string true "unique key of the operation"
+// @Param input body models.CoinAdjustmentRequest true "amount and reason"
+// @Success 200 {object} models.BalanceAdjustmentResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{id}/coins/grant [post]
+func (h *Handler) GrantCoins(c *gin.Context) {
+	var req models.CoinAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	h.adjustBalance(c, models.CoinTxGrant, req.Amount, req.Reason)
+}
+
+// @Summary DeductCoins
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description debit coins from a user, admin only
+// @ID admin-deduct-coins
+// @Accept json
+// @Produce json
+// @Param id path int true "user id"
+// @Param Idempotency-Key header string true "unique key of the operation"
+// @Param input body models.CoinAdjustmentRequest true "amount and reason"
+// @Success 200 {object} models.BalanceAdjustmentResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{id}/coins/deduct [post]
+func (h *Handler) DeductCoins(c *gin.Context) {
+	var req models.CoinAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	h.adjustBalance(c, models.CoinTxDeduction, req.Amount, req.Reason)
+}
+
+// @Summary SetBalance
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description correct the balance of a user, admin only
+// @ID admin-set-balance
+// @Accept json
+// @Produce json
+// @Param id path int true "user id"
+// @Param Idempotency-Key header string true "unique key of the operation"
+// @Param input body models.SetBalanceRequest true "new balance and reason"
+// @Success 200 {object} models.BalanceAdjustmentResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/users/{id}/coins [put]
+func (h *Handler) SetBalance(c *gin.Context) {
+	var req models.SetBalanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	h.adjustBalance(c, models.CoinTxAdjustment, req.Balance, req.Reason)
+}
+
+func (h *Handler) adjustBalance(c *gin.Context, kind string, amount int, reason string) {
+	log := logger.LoggerFromContext(c.Request.Context())
+
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect user id",
+		})
+		return
+	}
+
+	res, err := h.service.AdjustBalance(c.Request.Context(), models.BalanceAdjustment{
+		UserID:         userId,
+		ActorID:        c.GetInt("userId"),
+		Kind:           kind,
+		Amount:         amount,
+		Reason:         reason,
+		IdempotencyKey: c.GetHeader("Idempotency-Key"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.InvalidAmount):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Invalid amount",
+				Code:  codeInvalidAmount,
+			})
+		case errors.Is(err, internalErrors.InvalidReason):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidReason,
+			})
+		case errors.Is(err, internalErrors.IdempotencyKeyRequired):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Idempotency-Key header is required",
+				Code:  codeIdempotencyKeyRequired,
+			})
+		case errors.Is(err, internalErrors.InvalidIdempotencyKey):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidIdempotencyKey,
+			})
+		case errors.Is(err, internalErrors.IdempotencyKeyReused):
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error: "Idempotency-Key was used for a different request",
+				Code:  codeIdempotencyKeyReused,
+			})
+		case errors.Is(err, internalErrors.UserNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error: "User not found",
+				Code:  codeUserNotFound,
+			})
+		case errors.Is(err, internalErrors.NoMoney):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: "Balance is lower than the amount",
+				Code:  codeInsufficientBalance,
+			})
+		default:
+			log.Errorw("AdjustBalance", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Error adjusting balance",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
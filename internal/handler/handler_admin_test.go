@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/service"
+	"testAlvtoShp/internal/service/mocks"
+)
+
+func TestHandler_GetUser(t *testing.T) {
+	type mockBehavior func(s *mocks.MockAdmin)
+
+	testTable := []struct {
+		name                 string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Success",
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().GetUser(gomock.Any(), "test").
+					Return(models.AdminUserResponse{ID: 7, Username: "test", Role: "user", Coins: 1000}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":7,"username":"test","role":"user","coins":1000}`,
+		},
+		{
+			name: "Not found",
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().GetUser(gomock.Any(), "test").Return(models.AdminUserResponse{}, internalErrors.UserNotFound)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"errors":"User not found","code":"user_not_found"}`,
+		},
+		{
+			name: "Internal error",
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().GetUser(gomock.Any(), "test").Return(models.AdminUserResponse{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors":"Error getting user"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockAdmin(ctrl)
+			testCase.mockBehavior(mockService)
+
+			handler := NewHandler(&service.Service{
+				Admin: mockService,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/admin/users/test", nil)
+			c.Params = gin.Params{{Key: "username", Value: "test"}}
+			handler.GetUser(c)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.JSONEq(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_SetUserRole(t *testing.T) {
+	type mockBehavior func(s *mocks.MockAdmin)
+
+	testTable := []struct {
+		name                 string
+		id                   string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "Incorrect id",
+			id:                   "abc",
+			inputBody:            `{"role": "admin"}`,
+			mockBehavior:         func(s *mocks.MockAdmin) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"Incorrect user id"}`,
+		},
+		{
+			name:      "Invalid role",
+			id:        "7",
+			inputBody: `{"role": "root"}`,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().SetUserRole(gomock.Any(), 1, 7, "root").Return(internalErrors.InvalidRole)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"Invalid role","code":"invalid_role"}`,
+		},
+		{
+			name:      "Not found",
+			id:        "7",
+			inputBody: `{"role": "admin"}`,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().SetUserRole(gomock.Any(), 1, 7, "admin").Return(internalErrors.UserNotFound)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"errors":"User not found","code":"user_not_found"}`,
+		},
+		{
+			name:      "Success",
+			id:        "7",
+			inputBody: `{"role": "auditor"}`,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().SetUserRole(gomock.Any(), 1, 7, "auditor").Return(nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockAdmin(ctrl)
+			testCase.mockBehavior(mockService)
+
+			handler := NewHandler(&service.Service{
+				Admin: mockService,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest("PUT", "/api/admin/users/"+testCase.id+"/role", bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: testCase.id}}
+			c.Set("userId", 1)
+			handler.SetUserRole(c)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.JSONEq(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_AdjustBalance(t *testing.T) {
+	type mockBehavior func(s *mocks.MockAdmin)
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		idempotencyKey       string
+		call                 func(h *Handler, c *gin.Context)
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:           "Grant",
+			inputBody:      `{"amount": 100, "reason": "hackathon prize"}`,
+			idempotencyKey: "key-1",
+			call:           (*Handler).GrantCoins,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().AdjustBalance(gomock.Any(), models.BalanceAdjustment{
+					UserID: 7, ActorID: 1, Kind: models.CoinTxGrant, Amount: 100, Reason: "hackathon prize", IdempotencyKey: "key-1",
+				}).Return(models.BalanceAdjustmentResponse{TransactionID: 3, UserID: 7, Kind: models.CoinTxGrant, Delta: 100, Balance: 1100}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"transactionId":3,"userId":7,"kind":"grant","delta":100,"balance":1100}`,
+		},
+		{
+			name:           "Deduct more than balance",
+			inputBody:      `{"amount": 5000, "reason": "chargeback"}`,
+			idempotencyKey: "key-2",
+			call:           (*Handler).DeductCoins,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().AdjustBalance(gomock.Any(), models.BalanceAdjustment{
+					UserID: 7, ActorID: 1, Kind: models.CoinTxDeduction, Amount: 5000, Reason: "chargeback", IdempotencyKey: "key-2",
+				}).Return(models.BalanceAdjustmentResponse{}, internalErrors.NoMoney)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"errors":"Balance is lower than the amount","code":"insufficient_balance"}`,
+		},
+		{
+			name:           "Set balance",
+			inputBody:      `{"balance": 0, "reason": "test account"}`,
+			idempotencyKey: "key-3",
+			call:           (*Handler).SetBalance,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().AdjustBalance(gomock.Any(), models.BalanceAdjustment{
+					UserID: 7, ActorID: 1, Kind: models.CoinTxAdjustment, Amount: 0, Reason: "test account", IdempotencyKey: "key-3",
+				}).Return(models.BalanceAdjustmentResponse{TransactionID: 4, UserID: 7, Kind: models.CoinTxAdjustment, Delta: -1000, Balance: 0}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"transactionId":4,"userId":7,"kind":"adjustment","delta":-1000,"balance":0}`,
+		},
+		{
+			name:      "Missing reason",
+			inputBody: `{"amount": 100}`,
+			call:      (*Handler).GrantCoins,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).
+					Return(models.BalanceAdjustmentResponse{}, fmt.Errorf("%w: reason is required", internalErrors.InvalidReason))
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"invalid reason: reason is required","code":"invalid_reason"}`,
+		},
+		{
+			name:      "Missing idempotency key",
+			inputBody: `{"amount": 100, "reason": "prize"}`,
+			call:      (*Handler).GrantCoins,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).
+					Return(models.BalanceAdjustmentResponse{}, internalErrors.IdempotencyKeyRequired)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"Idempotency-Key header is required","code":"idempotency_key_required"}`,
+		},
+		{
+			name:           "Invalid idempotency key",
+			inputBody:      `{"amount": 100, "reason": "prize"}`,
+			idempotencyKey: "key-1",
+			call:           (*Handler).GrantCoins,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).
+					Return(models.BalanceAdjustmentResponse{}, fmt.Errorf("%w: length must be between 1 and 128", internalErrors.InvalidIdempotencyKey))
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"invalid idempotency key: length must be between 1 and 128","code":"invalid_idempotency_key"}`,
+		},
+		{
+			name:           "Reused idempotency key",
+			inputBody:      `{"amount": 200, "reason": "prize"}`,
+			idempotencyKey: "key-1",
+			call:           (*Handler).GrantCoins,
+			mockBehavior: func(s *mocks.MockAdmin) {
+				s.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).
+					Return(models.BalanceAdjustmentResponse{}, internalErrors.IdempotencyKeyReused)
+			},
+			expectedStatusCode:   http.StatusUnprocessableEntity,
+			expectedResponseBody: `{"errors":"Idempotency-Key was used for a different request","code":"idempotency_key_reused"}`,
+		},
+		{
+			name:                 "Invalid body",
+			inputBody:            `{"amount": "many"}`,
+			call:                 (*Handler).GrantCoins,
+			mockBehavior:         func(s *mocks.MockAdmin) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"Error in parsing body"}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockAdmin(ctrl)
+			testCase.mockBehavior(mockService)
+
+			handler := NewHandler(&service.Service{
+				Admin: mockService,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest("POST", "/api/admin/users/7/coins", bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Content-Type", "application/json")
+			if testCase.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", testCase.idempotencyKey)
+			}
+			c.Request = req
+			c.Params = gin.Params{{Key: "id", Value: "7"}}
+			c.Set("userId", 1)
+			testCase.call(handler, c)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.JSONEq(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
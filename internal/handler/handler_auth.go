@@ -1,11 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"math"
 	"net/http"
+	"strconv"
+	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
+	"time"
 )
 
 // @Summary GetAuthToken
@@ -17,6 +22,9 @@ import (
 // @Param input body models.AuthRequest true "account info"
 // @Success 200 {object} models.AuthResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Header 429 {integer} Retry-After "seconds until the next attempt is allowed"
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/auth [post]
 func (h *Handler) GetAuthToken(c *gin.Context) {
@@ -37,6 +45,20 @@ func (h *Handler) GetAuthToken(c *gin.Context) {
 		return
 	}
 
+	ip := c.ClientIP()
+	retryAfter, err := h.service.CheckLogin(c.Request.Context(), req.Username, ip)
+	if err != nil {
+		log.Errorw("CheckLogin", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error in checking login attempts",
+		})
+		return
+	}
+	if retryAfter > 0 {
+		tooManyAttempts(c, retryAfter)
+		return
+	}
+
 	userId, err := h.service.GetUserByUsername(c.Request.Context(), req.Username)
 	if err != nil {
 		log.Errorw("GetUserByUsername", zap.Error(err))
@@ -50,6 +72,27 @@ func (h *Handler) GetAuthToken(c *gin.Context) {
 		var id int
 		id, err = h.service.CreateUser(c.Request.Context(), req)
 		if err != nil {
+			if errors.Is(err, internalErrors.UnknownUser) {
+				// answered like a wrong password and just as slowly, so
+				// that logins do not tell which users exist
+				if _, err = h.service.CheckPassword(c.Request.Context(), req); err != nil {
+					log.Errorw("CheckPassword", zap.Error(err))
+				}
+				if _, err = h.service.RecordLoginFailure(c.Request.Context(), req.Username, ip); err != nil {
+					log.Errorw("RecordLoginFailure", zap.Error(err))
+				}
+				c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+					Error: "Invalid password",
+				})
+				return
+			}
+			if errors.Is(err, internalErrors.InvalidUsername) {
+				c.JSON(http.StatusBadRequest, models.ErrorResponse{
+					Error: err.Error(),
+					Code:  codeInvalidUsername,
+				})
+				return
+			}
 			log.Errorw("CreateUser", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 				Error: "Error in creating user",
@@ -70,16 +113,23 @@ func (h *Handler) GetAuthToken(c *gin.Context) {
 			return
 		}
 		if !isValid {
+			if _, err = h.service.RecordLoginFailure(c.Request.Context(), req.Username, ip); err != nil {
+				log.Errorw("RecordLoginFailure", zap.Error(err))
+			}
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 				Error: "Invalid password",
 			})
 			return
 		}
+
+		if err = h.service.RecordLoginSuccess(c.Request.Context(), req.Username, ip); err != nil {
+			log.Errorw("RecordLoginSuccess", zap.Error(err))
+		}
 	}
 
-	token, err := h.service.GenerateToken(userId)
+	tokens, err := h.service.GenerateTokens(c.Request.Context(), userId)
 	if err != nil {
-		log.Errorw("GenerateToken", zap.Error(err))
+		log.Errorw("GenerateTokens", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: "Error in generating token",
 		})
@@ -88,5 +138,218 @@ func (h *Handler) GetAuthToken(c *gin.Context) {
 
 	log.Infow("user authorize", zap.Int("user_id", userId))
 
-	c.JSON(http.StatusOK, models.AuthResponse{Token: token})
+	c.JSON(http.StatusOK, tokens)
+}
+
+func tooManyAttempts(c *gin.Context, retryAfter time.Duration) {
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	c.JSON(http.StatusTooManyRequests, models.ErrorResponse{
+		Error: "Too many login attempts",
+		Code:  codeTooManyAttempts,
+	})
+}
+
+// @Summary Register
+// @Tags auth
+// @Description explicit registration 4 user
+// @ID register-user
+// @Accept json
+// @Produce json
+// @Param input body models.RegisterRequest true "account info"
+// @Success 201 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/register [post]
+func (h *Handler) Register(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+
+	var req models.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	if req.Password == "" || req.Username == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect body",
+		})
+		return
+	}
+
+	userId, err := h.service.Register(c.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.InvalidUsername):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidUsername,
+			})
+		case errors.Is(err, internalErrors.InvalidInvite):
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error: "Invalid invite code",
+				Code:  codeInvalidInvite,
+			})
+		case errors.Is(err, internalErrors.UserExists):
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: "User already exists",
+				Code:  codeUserExists,
+			})
+		default:
+			log.Errorw("Register", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Error in creating user",
+			})
+		}
+		return
+	}
+
+	tokens, err := h.service.GenerateTokens(c.Request.Context(), userId)
+	if err != nil {
+		log.Errorw("GenerateTokens", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error in generating token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tokens)
+}
+
+// @Summary CreateInvite
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description create single-use invite code
+// @ID create-invite
+// @Produce json
+// @Success 200 {object} models.InviteResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/invites [post]
+func (h *Handler) CreateInvite(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+	userId := c.GetInt("userId")
+
+	invite, err := h.service.CreateInvite(c.Request.Context(), userId)
+	if err != nil {
+		log.Errorw("CreateInvite", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error in creating invite",
+		})
+		return
+	}
+
+	log.Infow("invite created", zap.Int("user_id", userId))
+	c.JSON(http.StatusOK, invite)
+}
+
+// @Summary RefreshToken
+// @Tags auth
+// @Description exchange refresh token 4 new token pair
+// @ID refresh-token
+// @Accept json
+// @Produce json
+// @Param input body models.RefreshRequest true "refresh token"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/auth/refresh [post]
+func (h *Handler) RefreshToken(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect body",
+		})
+		return
+	}
+
+	tokens, err := h.service.RefreshTokens(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, internalErrors.InvalidRefreshToken) ||
+			errors.Is(err, internalErrors.RefreshTokenReused) ||
+			errors.Is(err, internalErrors.SessionRevoked) {
+			log.Errorw("refresh token rejected", zap.Error(err))
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error: "Invalid refresh token",
+			})
+			return
+		}
+		log.Errorw("RefreshTokens", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error in refreshing token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// @Summary Logout
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description revoke current session
+// @ID logout
+// @Produce json
+// @Success 200 {object} nil
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/auth/logout [post]
+func (h *Handler) Logout(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+	userId := c.GetInt("userId")
+
+	if err := h.service.Logout(c.Request.Context(), c.GetString("sessionId")); err != nil {
+		log.Errorw("Logout", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error in logging out",
+		})
+		return
+	}
+
+	log.Infow("user logged out", zap.Int("user_id", userId))
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary LogoutAll
+// @Security ApiKeyAuth
+// @Tags auth
+// @Description revoke all sessions of user
+// @ID logout-all
+// @Produce json
+// @Success 200 {object} nil
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/auth/logout/all [post]
+func (h *Handler) LogoutAll(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+	userId := c.GetInt("userId")
+
+	if err := h.service.LogoutAll(c.Request.Context(), userId); err != nil {
+		log.Errorw("LogoutAll", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error in logging out",
+		})
+		return
+	}
+
+	log.Infow("user logged out everywhere", zap.Int("user_id", userId))
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary GetJWKS
+// @Tags auth
+// @Description public keys 4 verifying access tokens
+// @ID get-jwks
+// @Produce json
+// @Success 200 {object} models.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) GetJWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.JWKS())
 }
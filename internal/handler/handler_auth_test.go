@@ -2,15 +2,20 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/models"
 	"testAlvtoShp/internal/service"
 	"testAlvtoShp/internal/service/mocks"
@@ -79,6 +84,24 @@ func TestHandler_GetAuthToken(t *testing.T) {
 			expectedStatusCode:   http.StatusInternalServerError,
 			expectedResponseBody: `{"errors":"Error in creating user"}`,
 		},
+		{
+			name:      "Unknown user when auto registration is disabled",
+			inputBody: `{"username": "newuser", "password": "newpass"}`,
+			inputRequest: models.AuthRequest{
+				Username: "newuser",
+				Password: "newpass",
+			},
+			mockBehavior: func(s *mocks.MockAuth, req models.AuthRequest) {
+				s.EXPECT().GetUserByUsername(gomock.Any(), req.Username).
+					Return(0, nil)
+				s.EXPECT().CreateUser(gomock.Any(), req).
+					Return(0, internalErrors.UnknownUser)
+				s.EXPECT().CheckPassword(gomock.Any(), req).
+					Return(false, nil)
+			},
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: `{"errors":"Invalid password"}`,
+		},
 		{
 			name:      "GenerateToken Error for New User",
 			inputBody: `{"username": "newuser", "password": "newpass"}`,
@@ -91,8 +114,8 @@ func TestHandler_GetAuthToken(t *testing.T) {
 					Return(0, nil)
 				s.EXPECT().CreateUser(gomock.Any(), req).
 					Return(42, nil)
-				s.EXPECT().GenerateToken(42).
-					Return("", errors.New("token generation failed"))
+				s.EXPECT().GenerateTokens(gomock.Any(), 42).
+					Return(models.AuthResponse{}, errors.New("token generation failed"))
 			},
 			expectedStatusCode:   http.StatusInternalServerError,
 			expectedResponseBody: `{"errors":"Error in generating token"}`,
@@ -141,8 +164,8 @@ func TestHandler_GetAuthToken(t *testing.T) {
 					Return(1, nil)
 				s.EXPECT().CheckPassword(gomock.Any(), req).
 					Return(true, nil)
-				s.EXPECT().GenerateToken(1).
-					Return("", errors.New("token generation failed"))
+				s.EXPECT().GenerateTokens(gomock.Any(), 1).
+					Return(models.AuthResponse{}, errors.New("token generation failed"))
 			},
 			expectedStatusCode:   http.StatusInternalServerError,
 			expectedResponseBody: `{"errors":"Error in generating token"}`,
@@ -159,11 +182,11 @@ func TestHandler_GetAuthToken(t *testing.T) {
 					Return(1, nil)
 				s.EXPECT().CheckPassword(gomock.Any(), req).
 					Return(true, nil)
-				s.EXPECT().GenerateToken(1).
-					Return("validtoken", nil)
+				s.EXPECT().GenerateTokens(gomock.Any(), 1).
+					Return(models.AuthResponse{Token: "validtoken", RefreshToken: "refresh", ExpiresIn: 900}, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `{"token":"validtoken"}`,
+			expectedResponseBody: `{"token":"validtoken","refreshToken":"refresh","expiresIn":900}`,
 		},
 		{
 			name:      "Success New User",
@@ -177,11 +200,11 @@ func TestHandler_GetAuthToken(t *testing.T) {
 					Return(0, nil)
 				s.EXPECT().CreateUser(gomock.Any(), req).
 					Return(42, nil)
-				s.EXPECT().GenerateToken(42).
-					Return("newusertoken", nil)
+				s.EXPECT().GenerateTokens(gomock.Any(), 42).
+					Return(models.AuthResponse{Token: "newusertoken", RefreshToken: "refresh", ExpiresIn: 900}, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `{"token":"newusertoken"}`,
+			expectedResponseBody: `{"token":"newusertoken","refreshToken":"refresh","expiresIn":900}`,
 		},
 	}
 
@@ -195,8 +218,14 @@ func TestHandler_GetAuthToken(t *testing.T) {
 				testCase.mockBehavior(mockService, testCase.inputRequest)
 			}
 
+			mockGuard := mocks.NewMockLoginGuard(ctrl)
+			mockGuard.EXPECT().CheckLogin(gomock.Any(), gomock.Any(), gomock.Any()).Return(time.Duration(0), nil).AnyTimes()
+			mockGuard.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(time.Second, nil).AnyTimes()
+			mockGuard.EXPECT().RecordLoginSuccess(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
 			handler := NewHandler(&service.Service{
-				Auth: mockService,
+				Auth:       mockService,
+				LoginGuard: mockGuard,
 			})
 
 			w := httptest.NewRecorder()
@@ -213,3 +242,396 @@ func TestHandler_GetAuthToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_GetAuthToken_LoginGuard(t *testing.T) {
+	type mockBehavior func(s *mocks.MockAuth, g *mocks.MockLoginGuard)
+
+	testTable := []struct {
+		name                 string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedRetryAfter   string
+		expectedResponseBody string
+	}{
+		{
+			name: "Locked out",
+			mockBehavior: func(s *mocks.MockAuth, g *mocks.MockLoginGuard) {
+				g.EXPECT().CheckLogin(gomock.Any(), "user", "10.0.0.1").Return(90*time.Second+time.Millisecond, nil)
+			},
+			expectedStatusCode:   http.StatusTooManyRequests,
+			expectedRetryAfter:   "91",
+			expectedResponseBody: `{"errors":"Too many login attempts","code":"too_many_attempts"}`,
+		},
+		{
+			name: "CheckLogin error",
+			mockBehavior: func(s *mocks.MockAuth, g *mocks.MockLoginGuard) {
+				g.EXPECT().CheckLogin(gomock.Any(), "user", "10.0.0.1").Return(time.Duration(0), errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors":"Error in checking login attempts"}`,
+		},
+		{
+			name: "Wrong password is recorded",
+			mockBehavior: func(s *mocks.MockAuth, g *mocks.MockLoginGuard) {
+				g.EXPECT().CheckLogin(gomock.Any(), "user", "10.0.0.1").Return(time.Duration(0), nil)
+				s.EXPECT().GetUserByUsername(gomock.Any(), "user").Return(1, nil)
+				s.EXPECT().CheckPassword(gomock.Any(), gomock.Any()).Return(false, nil)
+				g.EXPECT().RecordLoginFailure(gomock.Any(), "user", "10.0.0.1").Return(2*time.Second, nil)
+			},
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: `{"errors":"Invalid password"}`,
+		},
+		{
+			name: "Unknown user is recorded",
+			mockBehavior: func(s *mocks.MockAuth, g *mocks.MockLoginGuard) {
+				g.EXPECT().CheckLogin(gomock.Any(), "user", "10.0.0.1").Return(time.Duration(0), nil)
+				s.EXPECT().GetUserByUsername(gomock.Any(), "user").Return(0, nil)
+				s.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(0, internalErrors.UnknownUser)
+				s.EXPECT().CheckPassword(gomock.Any(), gomock.Any()).Return(false, nil)
+				g.EXPECT().RecordLoginFailure(gomock.Any(), "user", "10.0.0.1").Return(2*time.Second, nil)
+			},
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: `{"errors":"Invalid password"}`,
+		},
+		{
+			name: "Successful login resets counter",
+			mockBehavior: func(s *mocks.MockAuth, g *mocks.MockLoginGuard) {
+				g.EXPECT().CheckLogin(gomock.Any(), "user", "10.0.0.1").Return(time.Duration(0), nil)
+				s.EXPECT().GetUserByUsername(gomock.Any(), "user").Return(1, nil)
+				s.EXPECT().CheckPassword(gomock.Any(), gomock.Any()).Return(true, nil)
+				g.EXPECT().RecordLoginSuccess(gomock.Any(), "user", "10.0.0.1").Return(nil)
+				s.EXPECT().GenerateTokens(gomock.Any(), 1).
+					Return(models.AuthResponse{Token: "token", RefreshToken: "refresh", ExpiresIn: 900}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"token":"token","refreshToken":"refresh","expiresIn":900}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockAuth(ctrl)
+			mockGuard := mocks.NewMockLoginGuard(ctrl)
+			testCase.mockBehavior(mockService, mockGuard)
+
+			handler := NewHandler(&service.Service{
+				Auth:       mockService,
+				LoginGuard: mockGuard,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest("POST", "/auth", bytes.NewBufferString(`{"username": "user", "password": "pass"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "10.0.0.1:54321"
+			c.Request = req
+			handler.GetAuthToken(c)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedRetryAfter, w.Header().Get("Retry-After"))
+			assert.JSONEq(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_RefreshToken(t *testing.T) {
+	type mockBehavior func(s *mocks.MockAuth)
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "Missing refresh token",
+			inputBody:            `{}`,
+			mockBehavior:         func(s *mocks.MockAuth) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"Incorrect body"}`,
+		},
+		{
+			name:      "Unknown refresh token",
+			inputBody: `{"refreshToken": "unknown"}`,
+			mockBehavior: func(s *mocks.MockAuth) {
+				s.EXPECT().RefreshTokens(gomock.Any(), "unknown").
+					Return(models.AuthResponse{}, internalErrors.InvalidRefreshToken)
+			},
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: `{"errors":"Invalid refresh token"}`,
+		},
+		{
+			name:      "Reused refresh token",
+			inputBody: `{"refreshToken": "used"}`,
+			mockBehavior: func(s *mocks.MockAuth) {
+				s.EXPECT().RefreshTokens(gomock.Any(), "used").
+					Return(models.AuthResponse{}, internalErrors.RefreshTokenReused)
+			},
+			expectedStatusCode:   http.StatusUnauthorized,
+			expectedResponseBody: `{"errors":"Invalid refresh token"}`,
+		},
+		{
+			name:      "Store error",
+			inputBody: `{"refreshToken": "token"}`,
+			mockBehavior: func(s *mocks.MockAuth) {
+				s.EXPECT().RefreshTokens(gomock.Any(), "token").
+					Return(models.AuthResponse{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors":"Error in refreshing token"}`,
+		},
+		{
+			name:      "Success",
+			inputBody: `{"refreshToken": "token"}`,
+			mockBehavior: func(s *mocks.MockAuth) {
+				s.EXPECT().RefreshTokens(gomock.Any(), "token").
+					Return(models.AuthResponse{Token: "access", RefreshToken: "next", ExpiresIn: 900}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"token":"access","refreshToken":"next","expiresIn":900}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockAuth(ctrl)
+			testCase.mockBehavior(mockService)
+
+			handler := NewHandler(&service.Service{
+				Auth: mockService,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest("POST", "/auth/refresh", bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+			handler.RefreshToken(c)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.JSONEq(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_Logout(t *testing.T) {
+	testTable := []struct {
+		name                 string
+		all                  bool
+		mockBehavior         func(s *mocks.MockAuth)
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "Logout error",
+			mockBehavior: func(s *mocks.MockAuth) {
+				s.EXPECT().Logout(gomock.Any(), "session").Return(errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors":"Error in logging out"}`,
+		},
+		{
+			name: "Logout current session",
+			mockBehavior: func(s *mocks.MockAuth) {
+				s.EXPECT().Logout(gomock.Any(), "session").Return(nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{}`,
+		},
+		{
+			name: "Logout everywhere",
+			all:  true,
+			mockBehavior: func(s *mocks.MockAuth) {
+				s.EXPECT().LogoutAll(gomock.Any(), 42).Return(nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockAuth(ctrl)
+			testCase.mockBehavior(mockService)
+
+			handler := NewHandler(&service.Service{
+				Auth: mockService,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", 42)
+			c.Set("sessionId", "session")
+			c.Request = httptest.NewRequest("POST", "/auth/logout", nil)
+			if testCase.all {
+				handler.LogoutAll(c)
+			} else {
+				handler.Logout(c)
+			}
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.JSONEq(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_GetJWKS(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockService := mocks.NewMockAuth(ctrl)
+	mockService.EXPECT().JWKS().Return(models.JWKS{Keys: []models.JWK{
+		{Kty: "OKP", Kid: "ed-1", Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: "key"},
+	}})
+
+	handler := NewHandler(&service.Service{
+		Auth: mockService,
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	handler.GetJWKS(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"keys":[{"kty":"OKP","kid":"ed-1","use":"sig","alg":"EdDSA","crv":"Ed25519","x":"key"}]}`, w.Body.String())
+}
+
+func TestHandler_Register(t *testing.T) {
+	type mockBehavior func(s *mocks.MockAuth, req models.RegisterRequest)
+
+	testTable := []struct {
+		name                 string
+		inputBody            string
+		inputRequest         models.RegisterRequest
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "Missing password",
+			inputBody:            `{"username": "newuser"}`,
+			mockBehavior:         func(s *mocks.MockAuth, req models.RegisterRequest) {},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"Incorrect body"}`,
+		},
+		{
+			name:         "Invalid username",
+			inputBody:    `{"username": "admin", "password": "pass"}`,
+			inputRequest: models.RegisterRequest{Username: "admin", Password: "pass"},
+			mockBehavior: func(s *mocks.MockAuth, req models.RegisterRequest) {
+				s.EXPECT().Register(gomock.Any(), req).
+					Return(0, fmt.Errorf("%w: \"admin\" is reserved", internalErrors.InvalidUsername))
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"invalid username: \"admin\" is reserved","code":"invalid_username"}`,
+		},
+		{
+			name:         "User exists",
+			inputBody:    `{"username": "existing", "password": "pass"}`,
+			inputRequest: models.RegisterRequest{Username: "existing", Password: "pass"},
+			mockBehavior: func(s *mocks.MockAuth, req models.RegisterRequest) {
+				s.EXPECT().Register(gomock.Any(), req).Return(0, internalErrors.UserExists)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"errors":"User already exists","code":"user_exists"}`,
+		},
+		{
+			name:         "Invalid invite",
+			inputBody:    `{"username": "newuser", "password": "pass", "inviteCode": "bad"}`,
+			inputRequest: models.RegisterRequest{Username: "newuser", Password: "pass", InviteCode: "bad"},
+			mockBehavior: func(s *mocks.MockAuth, req models.RegisterRequest) {
+				s.EXPECT().Register(gomock.Any(), req).Return(0, internalErrors.InvalidInvite)
+			},
+			expectedStatusCode:   http.StatusForbidden,
+			expectedResponseBody: `{"errors":"Invalid invite code","code":"invalid_invite"}`,
+		},
+		{
+			name:         "Success",
+			inputBody:    `{"username": "newuser", "password": "pass", "inviteCode": "good"}`,
+			inputRequest: models.RegisterRequest{Username: "newuser", Password: "pass", InviteCode: "good"},
+			mockBehavior: func(s *mocks.MockAuth, req models.RegisterRequest) {
+				s.EXPECT().Register(gomock.Any(), req).Return(42, nil)
+				s.EXPECT().GenerateTokens(gomock.Any(), 42).
+					Return(models.AuthResponse{Token: "access", RefreshToken: "refresh", ExpiresIn: 900}, nil)
+			},
+			expectedStatusCode:   http.StatusCreated,
+			expectedResponseBody: `{"token":"access","refreshToken":"refresh","expiresIn":900}`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockService := mocks.NewMockAuth(ctrl)
+			testCase.mockBehavior(mockService, testCase.inputRequest)
+
+			handler := NewHandler(&service.Service{
+				Auth: mockService,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest("POST", "/register", bytes.NewBufferString(testCase.inputBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+			handler.Register(c)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.JSONEq(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_GetAuthToken_ClientIP(t *testing.T) {
+	testTable := []struct {
+		name           string
+		trustedProxies []string
+		expectedIP     string
+	}{
+		{
+			name:       "Forwarded headers are ignored without trusted proxies",
+			expectedIP: "10.0.0.1",
+		},
+		{
+			name:           "Forwarded headers of a trusted proxy are used",
+			trustedProxies: []string{"10.0.0.0/8"},
+			expectedIP:     "203.0.113.7",
+		},
+	}
+
+	for _, testCase := range testTable {
+		t.Run(testCase.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockGuard := mocks.NewMockLoginGuard(ctrl)
+			mockGuard.EXPECT().CheckLogin(gomock.Any(), "user", testCase.expectedIP).Return(time.Minute, nil)
+
+			handler := NewHandler(&service.Service{LoginGuard: mockGuard})
+			router := handler.InitRoutes(context.Background(), &config.Config{TrustedProxies: testCase.trustedProxies})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/auth", bytes.NewBufferString(`{"username": "user", "password": "pass"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			req.Header.Set("X-Real-IP", "203.0.113.7")
+			req.RemoteAddr = "10.0.0.1:54321"
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		})
+	}
+}
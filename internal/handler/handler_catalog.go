@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+)
+
+// @Summary GetItems
+// @Tags shop
+// @Description list the catalog, supports If-None-Match
+// @ID get-items
+// @Produce json
+// @Param If-None-Match header string false "ETag of a cached catalog"
+// @Success 200 {object} models.CatalogResponse
+// @Success 304 {object} nil
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/items [get]
+func (h *Handler) GetItems(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+
+	catalog, err := h.service.GetItems(c.Request.Context())
+	if err != nil {
+		log.Errorw("GetItems", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error getting items",
+		})
+		return
+	}
+
+	body, err := json.Marshal(catalog)
+	if err != nil {
+		log.Errorw("GetItems marshal", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error getting items",
+		})
+		return
+	}
+
+	tag := etag(body)
+	c.Header("ETag", tag)
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), tag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// @Summary CreateItem
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description add an item to the catalog, admin only
+// @ID admin-create-item
+// @Accept json
+// @Produce json
+// @Param input body models.CreateItemRequest true "new item"
+// @Success 201 {object} models.CatalogItem
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/items [post]
+func (h *Handler) CreateItem(c *gin.Context) {
+	var req models.CreateItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	item, err := h.service.CreateItem(c.Request.Context(), c.GetInt("userId"), req)
+	if err != nil {
+		catalogError(c, "CreateItem", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// @Summary SetItemPrice
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description change the price of an item, admin only
+// @ID admin-set-item-price
+// @Accept json
+// @Produce json
+// @Param name path string true "item name"
+// @Param input body models.SetPriceRequest true "new price"
+// @Success 200 {object} models.CatalogItem
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/items/{name}/price [put]
+func (h *Handler) SetItemPrice(c *gin.Context) {
+	var req models.SetPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	item, err := h.service.SetItemPrice(c.Request.Context(), c.GetInt("userId"), c.Param("name"), req.Price)
+	if err != nil {
+		catalogError(c, "SetItemPrice", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// @Summary SetItemLimits
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description set the stock and the per user limit of an item, null means unlimited, admin only
+// @ID admin-set-item-limits
+// @Accept json
+// @Produce json
+// @Param name path string true "item name"
+// @Param input body models.ItemLimitsRequest true "new limits"
+// @Success 200 {object} models.CatalogItem
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/items/{name}/limits [put]
+func (h *Handler) SetItemLimits(c *gin.Context) {
+	var req models.ItemLimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	item, err := h.service.SetItemLimits(c.Request.Context(), c.GetInt("userId"), c.Param("name"), req)
+	if err != nil {
+		catalogError(c, "SetItemLimits", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// @Summary RetireItem
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description take an item off sale, owners keep it in their inventory, admin only
+// @ID admin-retire-item
+// @Produce json
+// @Param name path string true "item name"
+// @Success 200 {object} nil
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/items/{name} [delete]
+func (h *Handler) RetireItem(c *gin.Context) {
+	if err := h.service.RetireItem(c.Request.Context(), c.GetInt("userId"), c.Param("name")); err != nil {
+		catalogError(c, "RetireItem", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// @Summary GetPriceHistory
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description prices of an item over time, admin or auditor only
+// @ID admin-get-price-history
+// @Produce json
+// @Param name path string true "item name"
+// @Success 200 {array} models.ItemPrice
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/items/{name}/prices [get]
+func (h *Handler) GetPriceHistory(c *gin.Context) {
+	prices, err := h.service.GetPriceHistory(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		catalogError(c, "GetPriceHistory", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prices)
+}
+
+// catalogError answers with the status of a catalog management error.
+func catalogError(c *gin.Context, operation string, err error) {
+	switch {
+	case errors.Is(err, internalErrors.InvalidItem):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidItem,
+		})
+	case errors.Is(err, internalErrors.InvalidPrice):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Price must be positive",
+			Code:  codeInvalidPrice,
+		})
+	case errors.Is(err, internalErrors.ItemExists):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Item already exists",
+			Code:  codeItemExists,
+		})
+	case errors.Is(err, internalErrors.ItemNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Item not found",
+			Code:  codeItemNotFound,
+		})
+	default:
+		logger.LoggerFromContext(c.Request.Context()).Errorw(operation, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error managing catalog",
+		})
+	}
+}
+
+// etag is a strong validator of a response body.
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists the tag. The
+// comparison is weak, as RFC 9110 asks for If-None-Match.
+func etagMatches(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
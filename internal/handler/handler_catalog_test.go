@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/service"
+	"testAlvtoShp/internal/service/mocks"
+)
+
+func TestHandler_GetItems(t *testing.T) {
+	catalog := models.CatalogResponse{Items: []models.CatalogItem{
+		{Name: "cup", Price: 20, Description: "Кружка", Available: true},
+		{Name: "pen", Price: 10, Available: false, ImageURL: "https://example.com/pen.png"},
+	}}
+	catalogBody := `{"items":[{"name":"cup","price":20,"description":"Кружка","available":true},` +
+		`{"name":"pen","price":10,"description":"","available":false,"imageUrl":"https://example.com/pen.png"}]}`
+	catalogTag := etag([]byte(catalogBody))
+
+	testTable := []struct {
+		name                 string
+		ifNoneMatch          string
+		catalogErr           error
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:                 "Catalog",
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: catalogBody,
+		},
+		{
+			name:                 "Stale ETag",
+			ifNoneMatch:          `"stale"`,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: catalogBody,
+		},
+		{
+			name:               "Matching ETag",
+			ifNoneMatch:        catalogTag,
+			expectedStatusCode: http.StatusNotModified,
+		},
+		{
+			name:               "Weak ETag in a list",
+			ifNoneMatch:        `"stale", W/` + catalogTag,
+			expectedStatusCode: http.StatusNotModified,
+		},
+		{
+			name:                 "Store error",
+			catalogErr:           errors.New("db error"),
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors": "Error getting items"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCatalog := mocks.NewMockCatalog(ctrl)
+			if tc.catalogErr != nil {
+				mockCatalog.EXPECT().GetItems(gomock.Any()).Return(models.CatalogResponse{}, tc.catalogErr)
+			} else {
+				mockCatalog.EXPECT().GetItems(gomock.Any()).Return(catalog, nil)
+			}
+
+			h := NewHandler(&service.Service{
+				Catalog: mockCatalog,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/items", nil)
+			if tc.ifNoneMatch != "" {
+				c.Request.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+
+			h.GetItems(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.catalogErr == nil {
+				assert.Equal(t, catalogTag, w.Header().Get("ETag"))
+			}
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			} else {
+				assert.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_CatalogManagement(t *testing.T) {
+	item := models.CatalogItem{Name: "sticker", Price: 5, Available: true}
+
+	testTable := []struct {
+		name                 string
+		method               string
+		param                string
+		body                 string
+		mockBehavior         func(m *mocks.MockCatalog)
+		handle               func(h *Handler, c *gin.Context)
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:   "Create item",
+			method: "POST",
+			body:   `{"name": "sticker", "price": 5}`,
+			mockBehavior: func(m *mocks.MockCatalog) {
+				m.EXPECT().CreateItem(gomock.Any(), 1, models.CreateItemRequest{Name: "sticker", Price: 5}).Return(item, nil)
+			},
+			handle:               (*Handler).CreateItem,
+			expectedStatusCode:   http.StatusCreated,
+			expectedResponseBody: `{"name":"sticker","price":5,"description":"","available":true}`,
+		},
+		{
+			name:   "Create existing item",
+			method: "POST",
+			body:   `{"name": "cup", "price": 5}`,
+			mockBehavior: func(m *mocks.MockCatalog) {
+				m.EXPECT().CreateItem(gomock.Any(), 1, gomock.Any()).Return(models.CatalogItem{}, internalErrors.ItemExists)
+			},
+			handle:               (*Handler).CreateItem,
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"errors": "Item already exists", "code": "item_exists"}`,
+		},
+		{
+			name:               "Create with broken body",
+			method:             "POST",
+			body:               `{"name":`,
+			handle:             (*Handler).CreateItem,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "Reprice",
+			method: "PUT",
+			param:  "sticker",
+			body:   `{"price": 7}`,
+			mockBehavior: func(m *mocks.MockCatalog) {
+				m.EXPECT().SetItemPrice(gomock.Any(), 1, "sticker", 7).Return(models.CatalogItem{Name: "sticker", Price: 7, Available: true}, nil)
+			},
+			handle:               (*Handler).SetItemPrice,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"name":"sticker","price":7,"description":"","available":true}`,
+		},
+		{
+			name:   "Reprice to zero",
+			method: "PUT",
+			param:  "sticker",
+			body:   `{"price": 0}`,
+			mockBehavior: func(m *mocks.MockCatalog) {
+				m.EXPECT().SetItemPrice(gomock.Any(), 1, "sticker", 0).Return(models.CatalogItem{}, internalErrors.InvalidPrice)
+			},
+			handle:               (*Handler).SetItemPrice,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Price must be positive", "code": "invalid_price"}`,
+		},
+		{
+			name:   "Retire unknown item",
+			method: "DELETE",
+			param:  "mug",
+			mockBehavior: func(m *mocks.MockCatalog) {
+				m.EXPECT().RetireItem(gomock.Any(), 1, "mug").Return(internalErrors.ItemNotFound)
+			},
+			handle:               (*Handler).RetireItem,
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"errors": "Item not found", "code": "item_not_found"}`,
+		},
+		{
+			name:   "Retire",
+			method: "DELETE",
+			param:  "cup",
+			mockBehavior: func(m *mocks.MockCatalog) {
+				m.EXPECT().RetireItem(gomock.Any(), 1, "cup").Return(nil)
+			},
+			handle:               (*Handler).RetireItem,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{}`,
+		},
+		{
+			name:   "Price history",
+			method: "GET",
+			param:  "cup",
+			mockBehavior: func(m *mocks.MockCatalog) {
+				m.EXPECT().GetPriceHistory(gomock.Any(), "cup").Return([]models.ItemPrice{{Price: 20, ChangedAt: time.Unix(0, 0).UTC()}}, nil)
+			},
+			handle:               (*Handler).GetPriceHistory,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"price":20,"changedAt":"1970-01-01T00:00:00Z"}]`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCatalog := mocks.NewMockCatalog(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockCatalog)
+			}
+
+			h := NewHandler(&service.Service{
+				Catalog: mockCatalog,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", 1)
+			c.Params = gin.Params{{Key: "name", Value: tc.param}}
+			c.Request = httptest.NewRequest(tc.method, "/api/admin/items", bytes.NewBufferString(tc.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			tc.handle(h, c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
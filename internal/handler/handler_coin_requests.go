@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+)
+
+// @Summary RequestCoins
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description ask another user to send you coins, the request expires unless accepted in time
+// @ID request-coins
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "key to retry the request safely"
+// @Param input body models.CreateCoinRequest true "who to ask, how much and what for"
+// @Success 201 {object} models.CoinRequest
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/coinRequests [post]
+func (h *Handler) RequestCoins(c *gin.Context) {
+	var req models.CreateCoinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	request, err := h.service.RequestCoins(c.Request.Context(), c.GetInt("userId"), req)
+	if err != nil {
+		coinRequestError(c, "RequestCoins", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// @Summary AcceptCoinRequest
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description pay a pending coin request made to you, the coins are sent with the memo of the request
+// @ID accept-coin-request
+// @Produce json
+// @Param Idempotency-Key header string false "key to retry the request safely"
+// @Param id path int true "coin request id"
+// @Success 200 {object} models.CoinRequest
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/coinRequests/{id}/accept [post]
+func (h *Handler) AcceptCoinRequest(c *gin.Context) {
+	requestId, ok := parseCoinRequestId(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.service.AcceptCoinRequest(c.Request.Context(), c.GetInt("userId"), requestId)
+	if err != nil {
+		coinRequestError(c, "AcceptCoinRequest", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// @Summary DeclineCoinRequest
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description decline a pending coin request made to you
+// @ID decline-coin-request
+// @Produce json
+// @Param id path int true "coin request id"
+// @Success 200 {object} models.CoinRequest
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/coinRequests/{id}/decline [post]
+func (h *Handler) DeclineCoinRequest(c *gin.Context) {
+	requestId, ok := parseCoinRequestId(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.service.DeclineCoinRequest(c.Request.Context(), c.GetInt("userId"), requestId)
+	if err != nil {
+		coinRequestError(c, "DeclineCoinRequest", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
+
+// parseCoinRequestId reads the coin request id, it answers the request
+// itself when the id is malformed.
+func parseCoinRequestId(c *gin.Context) (int64, bool) {
+	requestId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect coin request id",
+		})
+		return 0, false
+	}
+
+	return requestId, true
+}
+
+// coinRequestError answers with the status of a coin request error.
+func coinRequestError(c *gin.Context, operation string, err error) {
+	switch {
+	case errors.Is(err, internalErrors.InvalidCoinRequest):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidCoinRequest,
+		})
+	case errors.Is(err, internalErrors.InvalidMemo):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidMemo,
+		})
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect user",
+			Code:  codeUnknownUser,
+		})
+	case errors.Is(err, internalErrors.CoinRequestToSelf):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Can not request coins from yourself",
+			Code:  codeCoinRequestToSelf,
+		})
+	case errors.Is(err, internalErrors.NoMoney):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "No money for this operation",
+			Code:  codeInsufficientBalance,
+		})
+	case errors.Is(err, internalErrors.CoinRequestNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Coin request not found",
+			Code:  codeCoinRequestNotFound,
+		})
+	case errors.Is(err, internalErrors.CoinRequestDecided):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Coin request is already decided",
+			Code:  codeCoinRequestDecided,
+		})
+	case errors.Is(err, internalErrors.CoinRequestExpired):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Coin request has expired",
+			Code:  codeCoinRequestExpired,
+		})
+	default:
+		logger.LoggerFromContext(c.Request.Context()).Errorw(operation, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error with coin request",
+		})
+	}
+}
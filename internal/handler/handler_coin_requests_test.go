@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/service"
+	"testAlvtoShp/internal/service/mocks"
+)
+
+func TestHandler_RequestCoins(t *testing.T) {
+	createdAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type mockBehavior func(m *mocks.MockCoinRequests)
+	testTable := []struct {
+		name                 string
+		requestBody          string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Success",
+			requestBody: `{"fromUser": "alice", "amount": 40, "memo": "pizza"}`,
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().RequestCoins(gomock.Any(), 42, models.CreateCoinRequest{FromUser: "alice", Amount: 40, Memo: "pizza"}).
+					Return(models.CoinRequest{ID: 5, Requester: "bob", Payer: "alice", Amount: 40, Memo: "pizza",
+						Status: models.CoinRequestPending, CreatedAt: createdAt, ExpiresAt: createdAt.Add(72 * time.Hour)}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedResponseBody: `{"id":5,"requester":"bob","payer":"alice","amount":40,"memo":"pizza","status":"pending",` +
+				`"createdAt":"2026-10-01T12:00:00Z","expiresAt":"2026-10-04T12:00:00Z"}`,
+		},
+		{
+			name:                 "Malformed body",
+			requestBody:          `{"fromUser": 1}`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Error in parsing body"}`,
+		},
+		{
+			name:        "Unknown user",
+			requestBody: `{"fromUser": "nobody", "amount": 40}`,
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().RequestCoins(gomock.Any(), 42, gomock.Any()).Return(models.CoinRequest{}, sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Incorrect user", "code": "unknown_user"}`,
+		},
+		{
+			name:        "From yourself",
+			requestBody: `{"fromUser": "bob", "amount": 40}`,
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().RequestCoins(gomock.Any(), 42, gomock.Any()).Return(models.CoinRequest{}, internalErrors.CoinRequestToSelf)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Can not request coins from yourself", "code": "coin_request_to_self"}`,
+		},
+		{
+			name:        "Store error",
+			requestBody: `{"fromUser": "alice", "amount": 40}`,
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().RequestCoins(gomock.Any(), 42, gomock.Any()).Return(models.CoinRequest{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors": "Error with coin request"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCoinRequests := mocks.NewMockCoinRequests(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockCoinRequests)
+			}
+
+			h := NewHandler(&service.Service{
+				CoinRequests: mockCoinRequests,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", 42)
+			req := httptest.NewRequest("POST", "/api/coinRequests", bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+
+			h.RequestCoins(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_DecideCoinRequest(t *testing.T) {
+	type mockBehavior func(m *mocks.MockCoinRequests)
+	testTable := []struct {
+		name               string
+		accept             bool
+		requestId          string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{
+			name:      "Accept",
+			accept:    true,
+			requestId: "5",
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().AcceptCoinRequest(gomock.Any(), 42, int64(5)).
+					Return(models.CoinRequest{ID: 5, Status: models.CoinRequestAccepted}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:      "Decline",
+			requestId: "5",
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().DeclineCoinRequest(gomock.Any(), 42, int64(5)).
+					Return(models.CoinRequest{ID: 5, Status: models.CoinRequestDeclined}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:      "Not enough coins",
+			accept:    true,
+			requestId: "5",
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().AcceptCoinRequest(gomock.Any(), 42, int64(5)).Return(models.CoinRequest{}, internalErrors.NoMoney)
+			},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedCode:       codeInsufficientBalance,
+		},
+		{
+			name:      "Expired",
+			accept:    true,
+			requestId: "5",
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().AcceptCoinRequest(gomock.Any(), 42, int64(5)).Return(models.CoinRequest{}, internalErrors.CoinRequestExpired)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedCode:       codeCoinRequestExpired,
+		},
+		{
+			name:      "Already decided",
+			requestId: "5",
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().DeclineCoinRequest(gomock.Any(), 42, int64(5)).Return(models.CoinRequest{}, internalErrors.CoinRequestDecided)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedCode:       codeCoinRequestDecided,
+		},
+		{
+			name:      "Made to somebody else",
+			requestId: "5",
+			mockBehavior: func(m *mocks.MockCoinRequests) {
+				m.EXPECT().DeclineCoinRequest(gomock.Any(), 42, int64(5)).Return(models.CoinRequest{}, internalErrors.CoinRequestNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedCode:       codeCoinRequestNotFound,
+		},
+		{
+			name:               "Incorrect id",
+			accept:             true,
+			requestId:          "five",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockCoinRequests := mocks.NewMockCoinRequests(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockCoinRequests)
+			}
+
+			h := NewHandler(&service.Service{
+				CoinRequests: mockCoinRequests,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", 42)
+			c.Params = gin.Params{{Key: "id", Value: tc.requestId}}
+			c.Request = httptest.NewRequest("POST", "/api/coinRequests/"+tc.requestId, nil)
+
+			if tc.accept {
+				h.AcceptCoinRequest(c)
+			} else {
+				h.DeclineCoinRequest(c)
+			}
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tc.expectedCode+`"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+)
+
+// @Summary RequestReturn
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description ask to return a whole order within the return window
+// @ID request-return
+// @Accept json
+// @Produce json
+// @Param id path int true "order id"
+// @Param input body models.ReturnRequest true "reason of the return"
+// @Success 201 {object} models.Return
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/orders/{id}/return [post]
+func (h *Handler) RequestReturn(c *gin.Context) {
+	orderId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect order id",
+		})
+		return
+	}
+
+	var req models.ReturnRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	ret, err := h.service.RequestReturn(c.Request.Context(), c.GetInt("userId"), orderId, req)
+	if err != nil {
+		returnError(c, "RequestReturn", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ret)
+}
+
+// @Summary GetReturns
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description list returns, oldest first, page by page, admin and auditor only
+// @ID admin-get-returns
+// @Produce json
+// @Param status query string false "pending, approved or rejected"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Param limit query int false "returns per page, 50 by default, at most 100"
+// @Success 200 {object} models.ReturnsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/returns [get]
+func (h *Handler) GetReturns(c *gin.Context) {
+	query := models.ReturnsQuery{
+		Status: c.Query("status"),
+		Cursor: c.Query("cursor"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: fmt.Sprintf("invalid limit %q", limit),
+				Code:  codeInvalidFilter,
+			})
+			return
+		}
+	}
+
+	returns, err := h.service.GetReturns(c.Request.Context(), query)
+	if err != nil {
+		returnError(c, "GetReturns", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// @Summary ApproveReturn
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description approve a pending return, the items leave the inventory and the order total is refunded, admin only
+// @ID admin-approve-return
+// @Accept json
+// @Produce json
+// @Param id path int true "return id"
+// @Param input body models.ReturnDecisionRequest false "note on the decision"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/returns/{id}/approve [post]
+func (h *Handler) ApproveReturn(c *gin.Context) {
+	returnId, req, ok := parseReturnDecision(c)
+	if !ok {
+		return
+	}
+
+	ret, err := h.service.ApproveReturn(c.Request.Context(), c.GetInt("userId"), returnId, req)
+	if err != nil {
+		returnError(c, "ApproveReturn", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// @Summary RejectReturn
+// @Security ApiKeyAuth
+// @Tags admin
+// @Description reject a pending return, admin only
+// @ID admin-reject-return
+// @Accept json
+// @Produce json
+// @Param id path int true "return id"
+// @Param input body models.ReturnDecisionRequest false "note on the decision"
+// @Success 200 {object} models.Return
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/admin/returns/{id}/reject [post]
+func (h *Handler) RejectReturn(c *gin.Context) {
+	returnId, req, ok := parseReturnDecision(c)
+	if !ok {
+		return
+	}
+
+	ret, err := h.service.RejectReturn(c.Request.Context(), c.GetInt("userId"), returnId, req)
+	if err != nil {
+		returnError(c, "RejectReturn", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ret)
+}
+
+// parseReturnDecision reads the return id and the optional note, it answers
+// the request itself when they are malformed.
+func parseReturnDecision(c *gin.Context) (int64, models.ReturnDecisionRequest, bool) {
+	var req models.ReturnDecisionRequest
+
+	returnId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Incorrect return id",
+		})
+		return 0, req, false
+	}
+
+	if err = c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return 0, req, false
+	}
+
+	return returnId, req, true
+}
+
+// returnError answers with the status of a return flow error.
+func returnError(c *gin.Context, operation string, err error) {
+	switch {
+	case errors.Is(err, internalErrors.InvalidReason):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidReason,
+		})
+	case errors.Is(err, internalErrors.InvalidFilter):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidFilter,
+		})
+	case errors.Is(err, internalErrors.InvalidCursor):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Invalid cursor",
+			Code:  codeInvalidCursor,
+		})
+	case errors.Is(err, internalErrors.OrderNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Order not found",
+			Code:  codeOrderNotFound,
+		})
+	case errors.Is(err, internalErrors.ReturnNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "Return not found",
+			Code:  codeReturnNotFound,
+		})
+	case errors.Is(err, internalErrors.ReturnWindowClosed):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Order can no longer be returned",
+			Code:  codeReturnWindowClosed,
+		})
+	case errors.Is(err, internalErrors.ReturnExists):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Order already has a return",
+			Code:  codeReturnExists,
+		})
+	case errors.Is(err, internalErrors.ReturnDecided):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Return is already decided",
+			Code:  codeReturnDecided,
+		})
+	case errors.Is(err, internalErrors.GiftNotReturnable):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "Gifts can not be returned",
+			Code:  codeGiftNotReturnable,
+		})
+	case errors.Is(err, internalErrors.ReturnItemsMissing):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeReturnItemsMissing,
+		})
+	default:
+		logger.LoggerFromContext(c.Request.Context()).Errorw(operation, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error processing return",
+		})
+	}
+}
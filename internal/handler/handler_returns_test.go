@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/service"
+	"testAlvtoShp/internal/service/mocks"
+)
+
+func TestHandler_RequestReturn(t *testing.T) {
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+
+	type mockBehavior func(m *mocks.MockReturns)
+	testTable := []struct {
+		name                 string
+		orderId              string
+		requestBody          string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Success",
+			orderId:     "7",
+			requestBody: `{"reason": "wrong size"}`,
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().RequestReturn(gomock.Any(), 42, int64(7), models.ReturnRequest{Reason: "wrong size"}).
+					Return(models.Return{ID: 3, OrderID: 7, UserID: 42, Status: "pending", Amount: 120, Reason: "wrong size", CreatedAt: createdAt}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedResponseBody: `{"id":3,"orderId":7,"userId":42,"status":"pending","amount":120,"reason":"wrong size",` +
+				`"createdAt":"2026-09-15T12:00:00Z"}`,
+		},
+		{
+			name:                 "Incorrect order id",
+			orderId:              "seven",
+			requestBody:          `{"reason": "wrong size"}`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Incorrect order id"}`,
+		},
+		{
+			name:        "Window closed",
+			orderId:     "7",
+			requestBody: `{"reason": "wrong size"}`,
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().RequestReturn(gomock.Any(), 42, int64(7), gomock.Any()).Return(models.Return{}, internalErrors.ReturnWindowClosed)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"errors": "Order can no longer be returned", "code": "return_window_closed"}`,
+		},
+		{
+			name:        "Order of another user",
+			orderId:     "7",
+			requestBody: `{"reason": "wrong size"}`,
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().RequestReturn(gomock.Any(), 42, int64(7), gomock.Any()).Return(models.Return{}, internalErrors.OrderNotFound)
+			},
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseBody: `{"errors": "Order not found", "code": "order_not_found"}`,
+		},
+		{
+			name:        "Store error",
+			orderId:     "7",
+			requestBody: `{"reason": "wrong size"}`,
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().RequestReturn(gomock.Any(), 42, int64(7), gomock.Any()).Return(models.Return{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors": "Error processing return"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockReturns := mocks.NewMockReturns(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockReturns)
+			}
+
+			h := NewHandler(&service.Service{
+				Returns: mockReturns,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", 42)
+			c.Params = gin.Params{{Key: "id", Value: tc.orderId}}
+			req := httptest.NewRequest("POST", "/api/orders/"+tc.orderId+"/return", bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+
+			h.RequestReturn(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_DecideReturn(t *testing.T) {
+	type mockBehavior func(m *mocks.MockReturns)
+	testTable := []struct {
+		name               string
+		approve            bool
+		returnId           string
+		requestBody        string
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{
+			name:     "Approve without a note",
+			approve:  true,
+			returnId: "3",
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().ApproveReturn(gomock.Any(), 1, int64(3), models.ReturnDecisionRequest{}).
+					Return(models.Return{ID: 3, Status: models.ReturnApproved}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:        "Reject with a note",
+			returnId:    "3",
+			requestBody: `{"note": "used"}`,
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().RejectReturn(gomock.Any(), 1, int64(3), models.ReturnDecisionRequest{Note: "used"}).
+					Return(models.Return{ID: 3, Status: models.ReturnRejected}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:     "Already decided",
+			approve:  true,
+			returnId: "3",
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().ApproveReturn(gomock.Any(), 1, int64(3), gomock.Any()).Return(models.Return{}, internalErrors.ReturnDecided)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedCode:       codeReturnDecided,
+		},
+		{
+			name:     "Items are gone",
+			approve:  true,
+			returnId: "3",
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().ApproveReturn(gomock.Any(), 1, int64(3), gomock.Any()).Return(models.Return{}, internalErrors.ReturnItemsMissing)
+			},
+			expectedStatusCode: http.StatusConflict,
+			expectedCode:       codeReturnItemsMissing,
+		},
+		{
+			name:     "Unknown return",
+			returnId: "3",
+			mockBehavior: func(m *mocks.MockReturns) {
+				m.EXPECT().RejectReturn(gomock.Any(), 1, int64(3), gomock.Any()).Return(models.Return{}, internalErrors.ReturnNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+			expectedCode:       codeReturnNotFound,
+		},
+		{
+			name:               "Malformed body",
+			approve:            true,
+			returnId:           "3",
+			requestBody:        `{"note": 1}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockReturns := mocks.NewMockReturns(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockReturns)
+			}
+
+			h := NewHandler(&service.Service{
+				Returns: mockReturns,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", 1)
+			c.Params = gin.Params{{Key: "id", Value: tc.returnId}}
+			req := httptest.NewRequest("POST", "/api/admin/returns/"+tc.returnId, bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+
+			if tc.approve {
+				h.ApproveReturn(c)
+			} else {
+				h.RejectReturn(c)
+			}
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+tc.expectedCode+`"`)
+			}
+		})
+	}
+}
@@ -3,12 +3,16 @@ package handler
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"strconv"
 	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
+	"time"
 )
 
 // @Summary GetUserInfo
@@ -45,20 +49,43 @@ func (h *Handler) GetUserInfo(c *gin.Context) {
 // @Description buy item 4 user
 // @ID buy-item-4-user
 // @Produce json
+// @Accept json
 // @Param item path models.ItemForBuy true "Item to purchase" models.ItemForBuy
+// @Param input body models.BuyRequest false "quantity, one by default"
+// @Param Idempotency-Key header string false "retries with the same key return the first response"
 // @Success 200 {object} nil
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
-// @Router /api/buy/{item} [get]
+// @Router /api/buy/{item} [post]
 func (h *Handler) BuyItem(c *gin.Context) {
 	log := logger.LoggerFromContext(c.Request.Context())
 	userId := c.GetInt("userId")
 	item := c.Param("item")
 
-	err := h.service.BuyItem(c.Request.Context(), userId, item)
+	var req models.BuyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+	quantity := 1
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+
+	err := h.service.BuyItem(c.Request.Context(), userId, item, quantity)
 
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, internalErrors.InvalidCart) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidCart,
+			})
+			return
+		} else if errors.Is(err, sql.ErrNoRows) || errors.Is(err, internalErrors.ItemNotFound) {
 			log.Errorw("incorrect item provided", zap.Error(err))
 			c.JSON(http.StatusBadRequest, models.ErrorResponse{
 				Error: "Incorrect item",
@@ -70,6 +97,18 @@ func (h *Handler) BuyItem(c *gin.Context) {
 				Error: "No money for this item",
 			})
 			return
+		} else if errors.Is(err, internalErrors.SoldOut) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: "Item is sold out",
+				Code:  codeSoldOut,
+			})
+			return
+		} else if errors.Is(err, internalErrors.PurchaseLimitReached) {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error: "Purchase limit for this item reached",
+				Code:  codePurchaseLimitReached,
+			})
+			return
 		}
 		log.Errorw("error buying item", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -78,10 +117,166 @@ func (h *Handler) BuyItem(c *gin.Context) {
 		return
 	}
 
-	log.Infow("buy item successfully", zap.String("item", item), zap.Int("userId", userId))
+	log.Infow("buy item successfully", zap.String("item", item), zap.Int("quantity", quantity), zap.Int("userId", userId))
 	c.JSON(http.StatusOK, gin.H{})
 }
 
+// @Summary BuyItem
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description buy one item 4 user, use POST instead
+// @ID buy-item-4-user-legacy
+// @Produce json
+// @Param item path models.ItemForBuy true "Item to purchase" models.ItemForBuy
+// @Param Idempotency-Key header string false "retries with the same key return the first response"
+// @Success 200 {object} nil
+// @Header 200 {string} Deprecation "true"
+// @Header 200 {string} Sunset "date the route is removed"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Deprecated
+// @Router /api/buy/{item} [get]
+func (h *Handler) LegacyBuyItem(sunset time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		log := logger.LoggerFromContext(c.Request.Context())
+		log.Warnw("deprecated GET /api/buy used", zap.String("item", c.Param("item")), zap.Int("userId", c.GetInt("userId")),
+			zap.String("user_agent", c.Request.UserAgent()))
+
+		c.Header("Deprecation", "true")
+		c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
+// @Summary Checkout
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description buy several items at once, all of them or none
+// @ID checkout
+// @Accept json
+// @Produce json
+// @Param input body models.CheckoutRequest true "cart"
+// @Param Idempotency-Key header string false "retries with the same key return the first response"
+// @Success 201 {object} models.Order
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/checkout [post]
+func (h *Handler) Checkout(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	order, err := h.service.Checkout(c.Request.Context(), userId, req)
+	if err != nil {
+		orderError(c, "Checkout", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// @Summary Gift
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description buy an item for another user, the sender pays and the item goes to the inventory of the recipient
+// @ID gift
+// @Accept json
+// @Produce json
+// @Param input body models.GiftRequest true "recipient and item"
+// @Param Idempotency-Key header string false "retries with the same key return the first response"
+// @Success 201 {object} models.Order
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/gift [post]
+func (h *Handler) Gift(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+	userId := c.GetInt("userId")
+
+	var req models.GiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	if req.ToUser == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "bad request data",
+		})
+		return
+	}
+
+	order, err := h.service.Gift(c.Request.Context(), userId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Errorw("incorrect user", zap.Error(err))
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Incorrect user",
+				Code:  codeUnknownUser,
+			})
+		case errors.Is(err, internalErrors.GiftToSelf):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeGiftToSelf,
+			})
+		default:
+			orderError(c, "Gift", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// orderError answers with the status of an error placing an order.
+func orderError(c *gin.Context, operation string, err error) {
+	switch {
+	case errors.Is(err, internalErrors.InvalidCart):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidCart,
+		})
+	case errors.Is(err, internalErrors.ItemNotFound):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeItemNotFound,
+		})
+	case errors.Is(err, internalErrors.NoMoney):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "No money for this order",
+			Code:  codeInsufficientBalance,
+		})
+	case errors.Is(err, internalErrors.SoldOut):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeSoldOut,
+		})
+	case errors.Is(err, internalErrors.PurchaseLimitReached):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codePurchaseLimitReached,
+		})
+	default:
+		logger.LoggerFromContext(c.Request.Context()).Errorw(operation, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: "Error placing order",
+		})
+	}
+}
+
 // @Summary SendCoin
 // @Security ApiKeyAuth
 // @Tags shop
@@ -89,8 +284,11 @@ func (h *Handler) BuyItem(c *gin.Context) {
 // @ID send-coin-to-user
 // @Produce json
 // @Param input body models.SendCoinRequest true "account info"
+// @Param Idempotency-Key header string false "retries with the same key return the first response"
 // @Success 200 {object} nil
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/sendCoin [post]
 func (h *Handler) SendCoin(c *gin.Context) {
@@ -139,6 +337,12 @@ func (h *Handler) SendCoin(c *gin.Context) {
 				Error: "No money for this operation",
 			})
 			return
+		} else if errors.Is(err, internalErrors.InvalidMemo) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidMemo,
+			})
+			return
 		}
 		log.Errorw("error with sending coin", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -151,3 +355,234 @@ func (h *Handler) SendCoin(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{})
 }
+
+// @Summary TransferItem
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description move units of an item from the inventory of the caller to another user
+// @ID transfer-item
+// @Accept json
+// @Produce json
+// @Param input body models.ItemTransferRequest true "recipient, item and quantity"
+// @Param Idempotency-Key header string false "retries with the same key return the first response"
+// @Success 200 {object} models.ItemTransfer
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/inventory/transfer [post]
+func (h *Handler) TransferItem(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+
+	var req models.ItemTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "Error in parsing body",
+		})
+		return
+	}
+
+	transfer, err := h.service.TransferItem(c.Request.Context(), c.GetInt("userId"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.InvalidTransfer):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidTransfer,
+			})
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Incorrect user",
+				Code:  codeUnknownUser,
+			})
+		case errors.Is(err, internalErrors.TransferToSelf):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeTransferToSelf,
+			})
+		case errors.Is(err, internalErrors.NotEnoughItems):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeNotEnoughItems,
+			})
+		default:
+			log.Errorw("TransferItem", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Error transferring item",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// @Summary GetOrders
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description purchase history of the user, newest first
+// @ID get-orders
+// @Produce json
+// @Param item query string false "only orders of this item"
+// @Param from query string false "orders made at or after this time, RFC 3339"
+// @Param to query string false "orders made before this time, RFC 3339"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Success 200 {object} models.OrdersResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/orders [get]
+func (h *Handler) GetOrders(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+	userId := c.GetInt("userId")
+
+	query, err := parseOrdersQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidFilter,
+		})
+		return
+	}
+
+	orders, err := h.service.GetOrders(c.Request.Context(), userId, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.InvalidCursor):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Invalid cursor",
+				Code:  codeInvalidCursor,
+			})
+		case errors.Is(err, internalErrors.InvalidFilter):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidFilter,
+			})
+		default:
+			log.Errorw("GetOrders", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Error getting orders",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func parseOrdersQuery(c *gin.Context) (models.OrdersQuery, error) {
+	query := models.OrdersQuery{
+		Item:   c.Query("item"),
+		Cursor: c.Query("cursor"),
+	}
+
+	var err error
+	if limit := c.Query("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return models.OrdersQuery{}, fmt.Errorf("invalid limit %q", limit)
+		}
+	}
+	if from := c.Query("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return models.OrdersQuery{}, fmt.Errorf("invalid from %q, expected RFC 3339", from)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return models.OrdersQuery{}, fmt.Errorf("invalid to %q, expected RFC 3339", to)
+		}
+	}
+	return query, nil
+}
+
+// @Summary GetTransactions
+// @Security ApiKeyAuth
+// @Tags shop
+// @Description coin transactions the user sent or received, newest first
+// @ID get-transactions
+// @Produce json
+// @Param direction query string false "in or out"
+// @Param counterparty query string false "only transfers with this user"
+// @Param minAmount query int false "only transactions of at least this amount"
+// @Param maxAmount query int false "only transactions of at most this amount"
+// @Param from query string false "transactions made at or after this time, RFC 3339"
+// @Param to query string false "transactions made before this time, RFC 3339"
+// @Param cursor query string false "nextCursor of the previous page"
+// @Param limit query int false "page size, 20 by default, 100 at most"
+// @Success 200 {object} models.TransactionsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/transactions [get]
+func (h *Handler) GetTransactions(c *gin.Context) {
+	log := logger.LoggerFromContext(c.Request.Context())
+	userId := c.GetInt("userId")
+
+	query, err := parseTransactionsQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+			Code:  codeInvalidFilter,
+		})
+		return
+	}
+
+	transactions, err := h.service.GetTransactions(c.Request.Context(), userId, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.InvalidCursor):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "Invalid cursor",
+				Code:  codeInvalidCursor,
+			})
+		case errors.Is(err, internalErrors.InvalidFilter):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidFilter,
+			})
+		default:
+			log.Errorw("GetTransactions", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Error getting transactions",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+func parseTransactionsQuery(c *gin.Context) (models.TransactionsQuery, error) {
+	query := models.TransactionsQuery{
+		Direction:    c.Query("direction"),
+		Counterparty: c.Query("counterparty"),
+		Cursor:       c.Query("cursor"),
+	}
+
+	ints := []struct {
+		name  string
+		value *int
+	}{
+		{"limit", &query.Limit},
+		{"minAmount", &query.MinAmount},
+		{"maxAmount", &query.MaxAmount},
+	}
+	var err error
+	for _, param := range ints {
+		if raw := c.Query(param.name); raw != "" {
+			if *param.value, err = strconv.Atoi(raw); err != nil {
+				return models.TransactionsQuery{}, fmt.Errorf("invalid %s %q", param.name, raw)
+			}
+		}
+	}
+	if from := c.Query("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return models.TransactionsQuery{}, fmt.Errorf("invalid from %q, expected RFC 3339", from)
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return models.TransactionsQuery{}, fmt.Errorf("invalid to %q, expected RFC 3339", to)
+		}
+	}
+	return query, nil
+}
@@ -5,10 +5,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testAlvtoShp/internal/service"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/mock/gomock"
@@ -50,13 +53,23 @@ func TestHandler_GetUserInfo(t *testing.T) {
 						Received: []models.ReceivedTransaction{},
 						Sent:     []models.SentTransaction{},
 					},
+					RecentPurchases: []models.Order{},
+					ReceivedGifts: []models.ReceivedGift{
+						{FromUser: "alice", Item: "cup", Quantity: 1, CreatedAt: time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)},
+					},
+					CoinRequests: models.CoinRequests{
+						Incoming: []models.CoinRequest{},
+						Outgoing: []models.CoinRequest{},
+					},
 				}
 				m.EXPECT().
 					GetUserInfo(gomock.Any(), userId).
 					Return(info, nil)
 			},
-			expectedStatusCode:   http.StatusOK,
-			expectedResponseBody: `{"coins":100,"inventory":[],"coinHistory":{"received":[],"sent":[]}}`,
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `{"coins":100,"inventory":[],"coinHistory":{"received":[],"sent":[]},"recentPurchases":[],` +
+				`"receivedGifts":[{"fromUser":"alice","item":"cup","quantity":1,"createdAt":"2026-09-15T12:00:00Z"}],` +
+				`"coinRequests":{"incoming":[],"outgoing":[]}}`,
 		},
 	}
 
@@ -96,6 +109,7 @@ func TestHandler_BuyItem(t *testing.T) {
 		name                 string
 		userId               int
 		item                 string
+		requestBody          string
 		mockBehavior         mockBehavior
 		expectedStatusCode   int
 		expectedResponseBody string
@@ -106,7 +120,7 @@ func TestHandler_BuyItem(t *testing.T) {
 			item:   "sword",
 			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
 				m.EXPECT().
-					BuyItem(gomock.Any(), userId, item).
+					BuyItem(gomock.Any(), userId, item, 1).
 					Return(sql.ErrNoRows)
 			},
 			expectedStatusCode:   http.StatusBadRequest,
@@ -118,19 +132,43 @@ func TestHandler_BuyItem(t *testing.T) {
 			item:   "shield",
 			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
 				m.EXPECT().
-					BuyItem(gomock.Any(), userId, item).
+					BuyItem(gomock.Any(), userId, item, 1).
 					Return(internalErrors.NoMoney)
 			},
 			expectedStatusCode:   http.StatusBadRequest,
 			expectedResponseBody: `{"errors": "No money for this item"}`,
 		},
+		{
+			name:   "Sold out",
+			userId: 42,
+			item:   "pink-hoody",
+			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
+				m.EXPECT().
+					BuyItem(gomock.Any(), userId, item, 1).
+					Return(internalErrors.SoldOut)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"errors": "Item is sold out", "code": "sold_out"}`,
+		},
+		{
+			name:   "Purchase limit reached",
+			userId: 42,
+			item:   "pink-hoody",
+			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
+				m.EXPECT().
+					BuyItem(gomock.Any(), userId, item, 1).
+					Return(internalErrors.PurchaseLimitReached)
+			},
+			expectedStatusCode:   http.StatusConflict,
+			expectedResponseBody: `{"errors": "Purchase limit for this item reached", "code": "purchase_limit_reached"}`,
+		},
 		{
 			name:   "Generic error buying item",
 			userId: 42,
 			item:   "potion",
 			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
 				m.EXPECT().
-					BuyItem(gomock.Any(), userId, item).
+					BuyItem(gomock.Any(), userId, item, 1).
 					Return(errors.New("some error"))
 			},
 			expectedStatusCode:   http.StatusInternalServerError,
@@ -142,12 +180,45 @@ func TestHandler_BuyItem(t *testing.T) {
 			item:   "armor",
 			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
 				m.EXPECT().
-					BuyItem(gomock.Any(), userId, item).
+					BuyItem(gomock.Any(), userId, item, 1).
+					Return(nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{}`,
+		}, {
+			name:        "Several units",
+			userId:      42,
+			item:        "pen",
+			requestBody: `{"quantity": 5}`,
+			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
+				m.EXPECT().
+					BuyItem(gomock.Any(), userId, item, 5).
 					Return(nil)
 			},
 			expectedStatusCode:   http.StatusOK,
 			expectedResponseBody: `{}`,
 		},
+		{
+			name:        "Invalid quantity",
+			userId:      42,
+			item:        "pen",
+			requestBody: `{"quantity": 0}`,
+			mockBehavior: func(m *mocks.MockShop, userId int, item string) {
+				m.EXPECT().
+					BuyItem(gomock.Any(), userId, item, 0).
+					Return(fmt.Errorf("%w: quantity of pen must be between 1 and 100", internalErrors.InvalidCart))
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "invalid cart: quantity of pen must be between 1 and 100", "code": "invalid_cart"}`,
+		},
+		{
+			name:                 "Malformed body",
+			userId:               42,
+			item:                 "pen",
+			requestBody:          `{"quantity": "five"}`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Error in parsing body"}`,
+		},
 	}
 
 	for _, tc := range testTable {
@@ -168,7 +239,8 @@ func TestHandler_BuyItem(t *testing.T) {
 			c, _ := gin.CreateTestContext(w)
 			c.Set("userId", tc.userId)
 			c.Params = gin.Params{{Key: "item", Value: tc.item}}
-			req := httptest.NewRequest("POST", "/buy/"+tc.item, nil)
+			req := httptest.NewRequest("POST", "/buy/"+tc.item, strings.NewReader(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
 			c.Request = req
 
 			h.BuyItem(c)
@@ -239,6 +311,18 @@ func TestHandler_SendCoin(t *testing.T) {
 			expectedStatusCode:   http.StatusBadRequest,
 			expectedResponseBody: `{"errors": "No money for this operation"}`,
 		},
+		{
+			name:        "Invalid memo",
+			requestBody: `{"amount":10,"toUser":"Alice","memo":"too long"}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int, req models.SendCoinRequest) {
+				m.EXPECT().
+					SendCoin(gomock.Any(), userId, req).
+					Return(internalErrors.InvalidMemo)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "invalid memo", "code": "invalid_memo"}`,
+		},
 		{
 			name:        "Generic error sending coin",
 			requestBody: `{"amount":10,"toUser":"Alice"}`,
@@ -297,3 +381,574 @@ func TestHandler_SendCoin(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_GetOrders(t *testing.T) {
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+
+	type mockBehavior func(m *mocks.MockShop, userId int)
+	testTable := []struct {
+		name                 string
+		userId               int
+		query                string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:   "Page with filters",
+			userId: 42,
+			query:  "?item=cup&from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z&cursor=abc&limit=1",
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					GetOrders(gomock.Any(), userId, models.OrdersQuery{
+						Item:   "cup",
+						From:   time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+						To:     time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+						Cursor: "abc",
+						Limit:  1,
+					}).
+					Return(models.OrdersResponse{
+						Orders: []models.Order{{ID: 7, Total: 20, CreatedAt: createdAt, Lines: []models.OrderLine{
+							{OrderID: 7, Item: "cup", UnitPrice: 20, Quantity: 1},
+						}}},
+						NextCursor: "next",
+					}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"orders":[{"id":7,"total":20,"createdAt":"2026-09-15T12:00:00Z","lines":[{"item":"cup","unitPrice":20,"quantity":1}]}],"nextCursor":"next"}`,
+		},
+		{
+			name:               "Malformed date",
+			userId:             42,
+			query:              "?from=yesterday",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:   "Invalid cursor",
+			userId: 42,
+			query:  "?cursor=abc",
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					GetOrders(gomock.Any(), userId, models.OrdersQuery{Cursor: "abc"}).
+					Return(models.OrdersResponse{}, internalErrors.InvalidCursor)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Invalid cursor", "code": "invalid_cursor"}`,
+		},
+		{
+			name:   "Store error",
+			userId: 42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					GetOrders(gomock.Any(), userId, models.OrdersQuery{}).
+					Return(models.OrdersResponse{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors": "Error getting orders"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockShop := mocks.NewMockShop(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockShop, tc.userId)
+			}
+
+			h := NewHandler(&service.Service{
+				Shop: mockShop,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", tc.userId)
+			c.Request = httptest.NewRequest("GET", "/api/orders"+tc.query, nil)
+
+			h.GetOrders(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_GetTransactions(t *testing.T) {
+	at := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+
+	type mockBehavior func(m *mocks.MockShop, userId int)
+	testTable := []struct {
+		name                 string
+		userId               int
+		query                string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:   "Page with filters",
+			userId: 42,
+			query: "?direction=in&counterparty=bob&minAmount=10&maxAmount=100" +
+				"&from=2026-09-01T00:00:00Z&to=2026-10-01T00:00:00Z&cursor=abc&limit=1",
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					GetTransactions(gomock.Any(), userId, models.TransactionsQuery{
+						Direction:    models.DirectionIn,
+						Counterparty: "bob",
+						MinAmount:    10,
+						MaxAmount:    100,
+						From:         time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+						To:           time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+						Cursor:       "abc",
+						Limit:        1,
+					}).
+					Return(models.TransactionsResponse{
+						Transactions: []models.Transaction{
+							{ID: 9, Direction: models.DirectionIn, Counterparty: "bob", Amount: 30, Kind: "transfer", Timestamp: at},
+						},
+						NextCursor: "next",
+					}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `{"transactions":[{"id":9,"direction":"in","counterparty":"bob","amount":30,` +
+				`"kind":"transfer","timestamp":"2026-09-15T12:00:00Z"}],"nextCursor":"next"}`,
+		},
+		{
+			name:                 "Malformed amount",
+			userId:               42,
+			query:                "?minAmount=ten",
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "invalid minAmount \"ten\"", "code": "invalid_filter"}`,
+		},
+		{
+			name:   "Invalid filter",
+			userId: 42,
+			query:  "?direction=sideways",
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					GetTransactions(gomock.Any(), userId, models.TransactionsQuery{Direction: "sideways"}).
+					Return(models.TransactionsResponse{}, internalErrors.InvalidFilter)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "invalid filter", "code": "invalid_filter"}`,
+		},
+		{
+			name:   "Store error",
+			userId: 42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					GetTransactions(gomock.Any(), userId, models.TransactionsQuery{}).
+					Return(models.TransactionsResponse{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors": "Error getting transactions"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockShop := mocks.NewMockShop(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockShop, tc.userId)
+			}
+
+			h := NewHandler(&service.Service{
+				Shop: mockShop,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", tc.userId)
+			c.Request = httptest.NewRequest("GET", "/api/transactions"+tc.query, nil)
+
+			h.GetTransactions(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Checkout(t *testing.T) {
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	cart := models.CheckoutRequest{Lines: []models.CartLine{{Item: "pen", Quantity: 10}, {Item: "cup", Quantity: 1}}}
+
+	type mockBehavior func(m *mocks.MockShop, userId int)
+	testTable := []struct {
+		name                 string
+		requestBody          string
+		userId               int
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Success",
+			requestBody: `{"lines":[{"item":"pen","quantity":10},{"item":"cup","quantity":1}]}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Checkout(gomock.Any(), userId, cart).
+					Return(models.Order{ID: 7, Total: 120, CreatedAt: createdAt, Lines: []models.OrderLine{
+						{Item: "cup", UnitPrice: 20, Quantity: 1},
+						{Item: "pen", UnitPrice: 10, Quantity: 10},
+					}}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedResponseBody: `{"id":7,"total":120,"createdAt":"2026-09-15T12:00:00Z","lines":[` +
+				`{"item":"cup","unitPrice":20,"quantity":1},{"item":"pen","unitPrice":10,"quantity":10}]}`,
+		},
+		{
+			name:                 "Binding error",
+			requestBody:          "invalid json",
+			userId:               42,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Error in parsing body"}`,
+		},
+		{
+			name:        "Invalid cart",
+			requestBody: `{"lines":[]}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Checkout(gomock.Any(), userId, models.CheckoutRequest{Lines: []models.CartLine{}}).
+					Return(models.Order{}, internalErrors.InvalidCart)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "invalid cart", "code": "invalid_cart"}`,
+		},
+		{
+			name:        "Not enough coins",
+			requestBody: `{"lines":[{"item":"pen","quantity":10},{"item":"cup","quantity":1}]}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Checkout(gomock.Any(), userId, cart).
+					Return(models.Order{}, internalErrors.NoMoney)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "No money for this order", "code": "insufficient_balance"}`,
+		},
+		{
+			name:        "Sold out",
+			requestBody: `{"lines":[{"item":"pen","quantity":10},{"item":"cup","quantity":1}]}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Checkout(gomock.Any(), userId, cart).
+					Return(models.Order{}, internalErrors.SoldOut)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:        "Store error",
+			requestBody: `{"lines":[{"item":"pen","quantity":10},{"item":"cup","quantity":1}]}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Checkout(gomock.Any(), userId, cart).
+					Return(models.Order{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors": "Error placing order"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockShop := mocks.NewMockShop(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockShop, tc.userId)
+			}
+
+			h := NewHandler(&service.Service{
+				Shop: mockShop,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", tc.userId)
+			req := httptest.NewRequest("POST", "/api/checkout", bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+
+			h.Checkout(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Gift(t *testing.T) {
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	gift := models.GiftRequest{ToUser: "bob", Item: "cup"}
+
+	type mockBehavior func(m *mocks.MockShop, userId int)
+	testTable := []struct {
+		name                 string
+		requestBody          string
+		userId               int
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Success",
+			requestBody: `{"toUser":"bob","item":"cup"}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Gift(gomock.Any(), userId, gift).
+					Return(models.Order{ID: 7, Total: 20, CreatedAt: createdAt, GiftTo: "bob", Lines: []models.OrderLine{
+						{Item: "cup", UnitPrice: 20, Quantity: 1},
+					}}, nil)
+			},
+			expectedStatusCode: http.StatusCreated,
+			expectedResponseBody: `{"id":7,"total":20,"createdAt":"2026-09-15T12:00:00Z","giftTo":"bob","lines":[` +
+				`{"item":"cup","unitPrice":20,"quantity":1}]}`,
+		},
+		{
+			name:                 "Binding error",
+			requestBody:          "invalid json",
+			userId:               42,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Error in parsing body"}`,
+		},
+		{
+			name:                 "No recipient",
+			requestBody:          `{"item":"cup"}`,
+			userId:               42,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "bad request data"}`,
+		},
+		{
+			name:        "Unknown recipient",
+			requestBody: `{"toUser":"bob","item":"cup"}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Gift(gomock.Any(), userId, gift).
+					Return(models.Order{}, sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Incorrect user", "code": "unknown_user"}`,
+		},
+		{
+			name:        "Gift to self",
+			requestBody: `{"toUser":"bob","item":"cup"}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Gift(gomock.Any(), userId, gift).
+					Return(models.Order{}, internalErrors.GiftToSelf)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "can not gift to yourself", "code": "gift_to_self"}`,
+		},
+		{
+			name:        "Not enough coins",
+			requestBody: `{"toUser":"bob","item":"cup"}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Gift(gomock.Any(), userId, gift).
+					Return(models.Order{}, internalErrors.NoMoney)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "No money for this order", "code": "insufficient_balance"}`,
+		},
+		{
+			name:        "Purchase limit reached",
+			requestBody: `{"toUser":"bob","item":"cup"}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					Gift(gomock.Any(), userId, gift).
+					Return(models.Order{}, internalErrors.PurchaseLimitReached)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockShop := mocks.NewMockShop(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockShop, tc.userId)
+			}
+
+			h := NewHandler(&service.Service{
+				Shop: mockShop,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", tc.userId)
+			req := httptest.NewRequest("POST", "/api/gift", bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+
+			h.Gift(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_TransferItem(t *testing.T) {
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	transfer := models.ItemTransferRequest{ToUser: "bob", Item: "cup", Quantity: 2}
+	body := `{"toUser":"bob","item":"cup","quantity":2}`
+
+	type mockBehavior func(m *mocks.MockShop, userId int)
+	testTable := []struct {
+		name                 string
+		requestBody          string
+		userId               int
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "Success",
+			requestBody: body,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					TransferItem(gomock.Any(), userId, transfer).
+					Return(models.ItemTransfer{ID: 5, ToUser: "bob", Item: "cup", Quantity: 2, CreatedAt: createdAt}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":5,"toUser":"bob","item":"cup","quantity":2,"createdAt":"2026-09-15T12:00:00Z"}`,
+		},
+		{
+			name:                 "Binding error",
+			requestBody:          "invalid json",
+			userId:               42,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Error in parsing body"}`,
+		},
+		{
+			name:        "Invalid transfer",
+			requestBody: `{"toUser":"bob","item":"cup"}`,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					TransferItem(gomock.Any(), userId, models.ItemTransferRequest{ToUser: "bob", Item: "cup"}).
+					Return(models.ItemTransfer{}, internalErrors.InvalidTransfer)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "invalid transfer", "code": "invalid_transfer"}`,
+		},
+		{
+			name:        "Unknown recipient",
+			requestBody: body,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					TransferItem(gomock.Any(), userId, transfer).
+					Return(models.ItemTransfer{}, sql.ErrNoRows)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "Incorrect user", "code": "unknown_user"}`,
+		},
+		{
+			name:        "Not enough items",
+			requestBody: body,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					TransferItem(gomock.Any(), userId, transfer).
+					Return(models.ItemTransfer{}, internalErrors.NotEnoughItems)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors": "not enough items in the inventory", "code": "not_enough_items"}`,
+		},
+		{
+			name:        "Store error",
+			requestBody: body,
+			userId:      42,
+			mockBehavior: func(m *mocks.MockShop, userId int) {
+				m.EXPECT().
+					TransferItem(gomock.Any(), userId, transfer).
+					Return(models.ItemTransfer{}, errors.New("db error"))
+			},
+			expectedStatusCode:   http.StatusInternalServerError,
+			expectedResponseBody: `{"errors": "Error transferring item"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockShop := mocks.NewMockShop(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockShop, tc.userId)
+			}
+
+			h := NewHandler(&service.Service{
+				Shop: mockShop,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userId", tc.userId)
+			req := httptest.NewRequest("POST", "/api/inventory/transfer", bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			c.Request = req
+
+			h.TransferItem(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_LegacyBuyItem(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockShop := mocks.NewMockShop(ctrl)
+	mockShop.EXPECT().BuyItem(gomock.Any(), 42, "cup", 1).Return(nil)
+
+	h := NewHandler(&service.Service{
+		Shop: mockShop,
+	})
+
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	router := gin.New()
+	router.GET("/api/buy/:item", func(c *gin.Context) { c.Set("userId", 42) }, h.LegacyBuyItem(sunset), h.BuyItem)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/buy/cup", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 01 Jan 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+}
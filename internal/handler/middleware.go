@@ -1,10 +1,16 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
 	"strings"
+	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
 )
@@ -24,12 +30,127 @@ func (h *Handler) CheckAuth(c *gin.Context) {
 		return
 	}
 
-	userId, err := h.service.ExtractUserIDFromAccessToken(c.Request.Context(), splittedHeader[1])
+	claims, err := h.service.ParseAccessToken(c.Request.Context(), splittedHeader[1])
 	if err != nil {
 		log.Errorw("Authorization header is invalid", zap.Error(err))
 		c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{Error: "Invalid token"})
 		return
 	}
 
-	c.Set("userId", userId)
+	c.Set("userId", claims.UserID)
+	c.Set("sessionId", claims.SessionID)
+	c.Set("role", claims.Role)
+}
+
+// RequireRole lets the request through only if CheckAuth has put one of the
+// given roles into the context.
+func (h *Handler) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				return
+			}
+		}
+
+		log := logger.LoggerFromContext(c.Request.Context())
+		log.Warnw("access denied", zap.Int("user_id", c.GetInt("userId")), zap.String("role", role),
+			zap.String("path", c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{Error: "Forbidden", Code: codeForbidden})
+	}
+}
+
+// Idempotent makes a request with an Idempotency-Key header run at most once
+// per user and key. A retry gets the stored response of the first request,
+// the same key with another method, path or body is rejected. Requests that
+// end with a server error are not stored so that a retry runs them again.
+// Requests without the header pass through.
+func (h *Handler) Idempotent(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		return
+	}
+
+	log := logger.LoggerFromContext(c.Request.Context())
+	userId := c.GetInt("userId")
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{Error: "Error reading body"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored, err := h.service.BeginIdempotent(c.Request.Context(), userId, key, requestFingerprint(c.Request, body))
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.InvalidIdempotencyKey):
+			c.AbortWithStatusJSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+				Code:  codeInvalidIdempotencyKey,
+			})
+		case errors.Is(err, internalErrors.IdempotencyKeyReused):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error: "Idempotency-Key was used for a different request",
+				Code:  codeIdempotencyKeyReused,
+			})
+		case errors.Is(err, internalErrors.IdempotencyKeyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, models.ErrorResponse{
+				Error: "A request with this Idempotency-Key is in progress",
+				Code:  codeIdempotencyKeyInProgress,
+			})
+		default:
+			log.Errorw("BeginIdempotent", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: "Error checking Idempotency-Key",
+			})
+		}
+		return
+	}
+	if stored != nil {
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(int(stored.StatusCode.Int32), "application/json; charset=utf-8", stored.Response)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	status := recorder.Status()
+	if status >= http.StatusInternalServerError {
+		if err = h.service.AbortIdempotent(c.Request.Context(), userId, key); err != nil {
+			log.Errorw("AbortIdempotent", zap.Error(err))
+		}
+		return
+	}
+	if err = h.service.CompleteIdempotent(c.Request.Context(), userId, key, status, recorder.body.Bytes()); err != nil {
+		log.Errorw("CompleteIdempotent", zap.Error(err))
+	}
+}
+
+// requestFingerprint identifies what a request does, the same key may only
+// be retried with the same fingerprint.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body for Idempotent.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
 }
@@ -1,7 +1,9 @@
 package handler
 
 import (
+	"database/sql"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,6 +13,8 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
 	"testAlvtoShp/internal/service"
 	"testAlvtoShp/internal/service/mocks"
 )
@@ -47,8 +51,8 @@ func TestHandler_CheckAuth(t *testing.T) {
 			headerValue: "Bearer invalidtoken",
 			mockBehavior: func(mockAuth *mocks.MockAuth, token string) {
 				mockAuth.EXPECT().
-					ExtractUserIDFromAccessToken(gomock.Any(), token).
-					Return(0, errors.New("invalid token"))
+					ParseAccessToken(gomock.Any(), token).
+					Return(models.TokenClaims{}, errors.New("invalid token"))
 			},
 			expectedStatusCode:   http.StatusUnauthorized,
 			expectedResponseBody: `{"errors":"Invalid token"}`,
@@ -59,8 +63,8 @@ func TestHandler_CheckAuth(t *testing.T) {
 			headerValue: "Bearer validtoken",
 			mockBehavior: func(mockAuth *mocks.MockAuth, token string) {
 				mockAuth.EXPECT().
-					ExtractUserIDFromAccessToken(gomock.Any(), token).
-					Return(42, nil)
+					ParseAccessToken(gomock.Any(), token).
+					Return(models.TokenClaims{UserID: 42, SessionID: "session", Role: "admin"}, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
 			expectedResponseBody: "",
@@ -103,6 +107,168 @@ func TestHandler_CheckAuth(t *testing.T) {
 				userID, exists := c.Get("userId")
 				assert.True(t, exists, "userId должен быть установлен в контекст")
 				assert.Equal(t, tc.expectedUserID, userID)
+				assert.Equal(t, "session", c.GetString("sessionId"))
+				assert.Equal(t, "admin", c.GetString("role"))
+			}
+		})
+	}
+}
+
+func TestHandler_RequireRole(t *testing.T) {
+	testTable := []struct {
+		name               string
+		role               string
+		expectedStatusCode int
+		expectedAborted    bool
+	}{
+		{name: "Admin allowed", role: service.RoleAdmin, expectedStatusCode: http.StatusOK},
+		{name: "Auditor allowed", role: service.RoleAuditor, expectedStatusCode: http.StatusOK},
+		{name: "User forbidden", role: service.RoleUser, expectedStatusCode: http.StatusForbidden, expectedAborted: true},
+		{name: "No role forbidden", role: "", expectedStatusCode: http.StatusForbidden, expectedAborted: true},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHandler(&service.Service{})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/admin/users/test", nil)
+			if tc.role != "" {
+				c.Set("role", tc.role)
+			}
+
+			h.RequireRole(service.RoleAdmin, service.RoleAuditor)(c)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedAborted, c.IsAborted())
+			if tc.expectedAborted {
+				assert.JSONEq(t, `{"errors":"Forbidden","code":"forbidden"}`, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_Idempotent(t *testing.T) {
+	type mockBehavior func(m *mocks.MockIdempotency)
+	fingerprint := requestFingerprint(httptest.NewRequest("POST", "/api/sendCoin", nil), []byte(`{"toUser":"bob","amount":10}`))
+
+	testTable := []struct {
+		name                 string
+		key                  string
+		mockBehavior         mockBehavior
+		handlerStatus        int
+		expectedStatusCode   int
+		expectedResponseBody string
+		expectedCalls        int
+		expectedReplayed     string
+	}{
+		{
+			name:               "No key",
+			handlerStatus:      http.StatusOK,
+			expectedStatusCode: http.StatusOK,
+			expectedCalls:      1,
+		},
+		{
+			name: "First request is stored",
+			key:  "k1",
+			mockBehavior: func(m *mocks.MockIdempotency) {
+				m.EXPECT().BeginIdempotent(gomock.Any(), 42, "k1", fingerprint).Return(nil, nil)
+				m.EXPECT().CompleteIdempotent(gomock.Any(), 42, "k1", http.StatusOK, []byte(`{"calls":1}`)).Return(nil)
+			},
+			handlerStatus:        http.StatusOK,
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"calls":1}`,
+			expectedCalls:        1,
+		},
+		{
+			name: "Server error is not stored",
+			key:  "k1",
+			mockBehavior: func(m *mocks.MockIdempotency) {
+				m.EXPECT().BeginIdempotent(gomock.Any(), 42, "k1", fingerprint).Return(nil, nil)
+				m.EXPECT().AbortIdempotent(gomock.Any(), 42, "k1").Return(nil)
+			},
+			handlerStatus:      http.StatusInternalServerError,
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedCalls:      1,
+		},
+		{
+			name: "Replay",
+			key:  "k1",
+			mockBehavior: func(m *mocks.MockIdempotency) {
+				m.EXPECT().BeginIdempotent(gomock.Any(), 42, "k1", fingerprint).Return(&models.IdempotencyRecord{
+					StatusCode: sql.NullInt32{Int32: http.StatusOK, Valid: true},
+					Response:   []byte(`{"calls":1}`),
+				}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"calls":1}`,
+			expectedReplayed:     "true",
+		},
+		{
+			name: "Key reused for another request",
+			key:  "k1",
+			mockBehavior: func(m *mocks.MockIdempotency) {
+				m.EXPECT().BeginIdempotent(gomock.Any(), 42, "k1", fingerprint).Return(nil, internalErrors.IdempotencyKeyReused)
+			},
+			expectedStatusCode:   http.StatusUnprocessableEntity,
+			expectedResponseBody: `{"errors":"Idempotency-Key was used for a different request","code":"idempotency_key_reused"}`,
+		},
+		{
+			name: "Key in progress",
+			key:  "k1",
+			mockBehavior: func(m *mocks.MockIdempotency) {
+				m.EXPECT().BeginIdempotent(gomock.Any(), 42, "k1", fingerprint).Return(nil, internalErrors.IdempotencyKeyInProgress)
+			},
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name: "Invalid key",
+			key:  "k1",
+			mockBehavior: func(m *mocks.MockIdempotency) {
+				m.EXPECT().BeginIdempotent(gomock.Any(), 42, "k1", fingerprint).Return(nil, internalErrors.InvalidIdempotencyKey)
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"errors":"invalid idempotency key","code":"invalid_idempotency_key"}`,
+		},
+	}
+
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockIdempotency := mocks.NewMockIdempotency(ctrl)
+			if tc.mockBehavior != nil {
+				tc.mockBehavior(mockIdempotency)
+			}
+
+			h := NewHandler(&service.Service{
+				Idempotency: mockIdempotency,
+			})
+
+			calls := 0
+			router := gin.New()
+			router.POST("/api/sendCoin", func(c *gin.Context) { c.Set("userId", 42) }, h.Idempotent, func(c *gin.Context) {
+				body, err := io.ReadAll(c.Request.Body)
+				assert.NoError(t, err)
+				assert.JSONEq(t, `{"toUser":"bob","amount":10}`, string(body), "the body is still readable")
+				calls++
+				c.JSON(tc.handlerStatus, gin.H{"calls": calls})
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/api/sendCoin", strings.NewReader(`{"toUser":"bob","amount":10}`))
+			if tc.key != "" {
+				req.Header.Set("Idempotency-Key", tc.key)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedCalls, calls)
+			assert.Equal(t, tc.expectedReplayed, w.Header().Get("Idempotent-Replayed"))
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
 			}
 		})
 	}
@@ -1,9 +1,7 @@
 package models
 
 import (
-	"crypto/sha256"
 	"database/sql"
-	"encoding/hex"
 	"time"
 )
 
@@ -23,27 +21,95 @@ const (
 	ItemPinkHoody ItemForBuy = "pink-hoody"
 )
 
+// @Description Товар каталога
+type CatalogItem struct {
+	Name         string `json:"name" db:"name"`
+	Price        int    `json:"price" db:"price"`
+	Description  string `json:"description" db:"description"`
+	Available    bool   `json:"available" db:"available"`
+	ImageURL     string `json:"imageUrl,omitempty" db:"image_url"`
+	Stock        *int   `json:"stock,omitempty" db:"stock"`
+	PerUserLimit *int   `json:"perUserLimit,omitempty" db:"per_user_limit"`
+}
+
+// @Description Каталог магазина
+type CatalogResponse struct {
+	Items []CatalogItem `json:"items"`
+}
+
+// @Description Новый товар каталога
+type CreateItemRequest struct {
+	Name         string `json:"name"`
+	Price        int    `json:"price"`
+	Description  string `json:"description"`
+	ImageURL     string `json:"imageUrl"`
+	Stock        *int   `json:"stock"`
+	PerUserLimit *int   `json:"perUserLimit"`
+}
+
+// @Description Остаток и ограничение на покупки одним пользователем, null снимает ограничение
+type ItemLimitsRequest struct {
+	Stock        *int `json:"stock"`
+	PerUserLimit *int `json:"perUserLimit"`
+}
+
+// @Description Новая цена товара
+type SetPriceRequest struct {
+	Price int `json:"price"`
+}
+
+// @Description Цена товара, действовавшая с момента changedAt
+type ItemPrice struct {
+	Price     int       `json:"price" db:"price"`
+	ChangedBy *int      `json:"changedBy,omitempty" db:"changed_by"`
+	ChangedAt time.Time `json:"changedAt" db:"changed_at"`
+}
+
 // @Description Запрос на вход/регистрацию
 type AuthRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
+// @Description Запрос на регистрацию
+type RegisterRequest struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	InviteCode string `json:"inviteCode,omitempty"`
+}
+
+// @Description Код приглашения
+type InviteResponse struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
 // @Description Ответ на вход/регистрацию
 type AuthResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+// @Description Запрос на обновление токенов
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 // @Description Ответ с ошибкой
 type ErrorResponse struct {
 	Error string `json:"errors"`
+	Code  string `json:"code,omitempty"`
 }
 
 // @Description Информация о пользователе
 type InfoResponse struct {
-	Coins       int         `json:"coins"`
-	Inventory   []Item      `json:"inventory"`
-	CoinHistory CoinHistory `json:"coinHistory"`
+	Coins           int            `json:"coins"`
+	Inventory       []Item         `json:"inventory"`
+	CoinHistory     CoinHistory    `json:"coinHistory"`
+	RecentPurchases []Order        `json:"recentPurchases"`
+	ReceivedGifts   []ReceivedGift `json:"receivedGifts"`
+	CoinRequests    CoinRequests   `json:"coinRequests"`
 }
 
 // @Description Параметры айтема
@@ -56,6 +122,58 @@ type Item struct {
 type SendCoinRequest struct {
 	ToUser string `json:"toUser"`
 	Amount int    `json:"amount"`
+	Memo   string `json:"memo,omitempty"`
+}
+
+// @Description Запрос на передачу товара из инвентаря
+type ItemTransferRequest struct {
+	ToUser   string `json:"toUser"`
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+// @Description Передача товара из инвентаря
+type ItemTransfer struct {
+	ID        int64     `json:"id"`
+	ToUser    string    `json:"toUser"`
+	Item      string    `json:"item"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Statuses of a coin request. A pending request is decided once by the
+// payer, a pending request past its expiry is shown as expired.
+const (
+	CoinRequestPending  = "pending"
+	CoinRequestAccepted = "accepted"
+	CoinRequestDeclined = "declined"
+	CoinRequestExpired  = "expired"
+)
+
+// @Description Запрос коинов у другого пользователя
+type CreateCoinRequest struct {
+	FromUser string `json:"fromUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+}
+
+// @Description Запрос коинов: Requester просит Payer перевести ему Amount коинов
+type CoinRequest struct {
+	ID        int64      `json:"id" db:"id"`
+	Requester string     `json:"requester" db:"requester"`
+	Payer     string     `json:"payer" db:"payer"`
+	Amount    int        `json:"amount" db:"amount"`
+	Memo      string     `json:"memo,omitempty" db:"memo"`
+	Status    string     `json:"status" db:"status"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expires_at"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty" db:"decided_at"`
+}
+
+// @Description Ожидающие ответа запросы коинов: входящие нужно оплатить, исходящие ждут оплаты
+type CoinRequests struct {
+	Incoming []CoinRequest `json:"incoming"`
+	Outgoing []CoinRequest `json:"outgoing"`
 }
 
 // @Description История переводов коинов
@@ -66,14 +184,206 @@ type CoinHistory struct {
 
 // @Description Полученные коины
 type ReceivedTransaction struct {
-	FromUser string `json:"fromUser" db:"from_user"`
-	Amount   int    `json:"amount"`
+	ID        int64     `json:"id" db:"id"`
+	FromUser  string    `json:"fromUser" db:"from_user"`
+	Amount    int       `json:"amount"`
+	Kind      string    `json:"kind,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Memo      string    `json:"memo,omitempty" db:"memo"`
+	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 }
 
 // @Description Отправленные коины
 type SentTransaction struct {
-	ToUser string `json:"toUser"  db:"to_user"`
-	Amount int    `json:"amount"`
+	ID        int64     `json:"id" db:"id"`
+	ToUser    string    `json:"toUser"  db:"to_user"`
+	Amount    int       `json:"amount"`
+	Kind      string    `json:"kind,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Memo      string    `json:"memo,omitempty" db:"memo"`
+	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+}
+
+// @Description Заказ
+type Order struct {
+	ID        int64       `json:"id" db:"id"`
+	Total     int         `json:"total" db:"total"`
+	CreatedAt time.Time   `json:"createdAt" db:"created_at"`
+	GiftTo    string      `json:"giftTo,omitempty" db:"gift_to"`
+	Lines     []OrderLine `json:"lines"`
+}
+
+// @Description Строка заказа, цена зафиксирована в момент покупки
+type OrderLine struct {
+	OrderID   int64  `json:"-" db:"order_id"`
+	Item      string `json:"item" db:"item_type"`
+	UnitPrice int    `json:"unitPrice" db:"unit_price"`
+	Quantity  int    `json:"quantity" db:"quantity"`
+}
+
+// @Description Покупка товара, по умолчанию одна штука
+type BuyRequest struct {
+	Quantity *int `json:"quantity"`
+}
+
+// @Description Подарок товара другому сотруднику, по умолчанию одна штука
+type GiftRequest struct {
+	ToUser   string `json:"toUser"`
+	Item     string `json:"item"`
+	Quantity *int   `json:"quantity"`
+}
+
+// @Description Полученный подарок
+type ReceivedGift struct {
+	FromUser  string    `json:"fromUser" db:"from_user"`
+	Item      string    `json:"item" db:"item_type"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// @Description Строка корзины
+type CartLine struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+}
+
+// @Description Корзина
+type CheckoutRequest struct {
+	Lines []CartLine `json:"lines"`
+}
+
+// Statuses of a return, a pending return is decided once.
+const (
+	ReturnPending  = "pending"
+	ReturnApproved = "approved"
+	ReturnRejected = "rejected"
+)
+
+// @Description Возврат заказа
+type Return struct {
+	ID        int64      `json:"id" db:"id"`
+	OrderID   int64      `json:"orderId" db:"order_id"`
+	UserID    int        `json:"userId" db:"user_id"`
+	Status    string     `json:"status" db:"status"`
+	Amount    int        `json:"amount" db:"amount"`
+	Reason    string     `json:"reason" db:"reason"`
+	Note      string     `json:"note,omitempty" db:"note"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty" db:"decided_at"`
+	DecidedBy *int       `json:"decidedBy,omitempty" db:"decided_by"`
+}
+
+// @Description Запрос на возврат заказа
+type ReturnRequest struct {
+	Reason string `json:"reason"`
+}
+
+// @Description Решение по возврату
+type ReturnDecisionRequest struct {
+	Note string `json:"note"`
+}
+
+// @Description Страница возвратов
+type ReturnsResponse struct {
+	Returns    []Return `json:"returns"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// ReturnsQuery is the list of returns an admin asks for, all statuses for
+// an empty Status.
+type ReturnsQuery struct {
+	Status string
+	Cursor string
+	Limit  int
+}
+
+// ReturnsFilter selects a page of returns, oldest first. A page continues
+// after the return AfterCreatedAt/AfterID when AfterID is set.
+type ReturnsFilter struct {
+	Status         string
+	AfterCreatedAt time.Time
+	AfterID        int64
+	Limit          int
+}
+
+// @Description Страница истории покупок
+type OrdersResponse struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// OrdersQuery is the purchase history request of a user as it comes from
+// the API. From is inclusive, To is exclusive, zero times are not applied.
+type OrdersQuery struct {
+	Item   string
+	From   time.Time
+	To     time.Time
+	Cursor string
+	Limit  int
+}
+
+// OrdersFilter selects a page of orders of a user, newest first. A page
+// continues after the order AfterCreatedAt/AfterID when AfterID is set.
+type OrdersFilter struct {
+	UserID         int
+	Item           string
+	From           time.Time
+	To             time.Time
+	AfterCreatedAt time.Time
+	AfterID        int64
+	Limit          int
+}
+
+// Directions of a coin transaction as seen by one of its users.
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// @Description Коинная транзакция пользователя
+type Transaction struct {
+	ID           int64     `json:"id" db:"id"`
+	Direction    string    `json:"direction" db:"direction"`
+	Counterparty string    `json:"counterparty" db:"counterparty"`
+	Amount       int       `json:"amount" db:"amount"`
+	Kind         string    `json:"kind" db:"kind"`
+	Reason       string    `json:"reason,omitempty" db:"reason"`
+	Memo         string    `json:"memo,omitempty" db:"memo"`
+	Timestamp    time.Time `json:"timestamp" db:"timestamp"`
+}
+
+// @Description Страница истории коинных транзакций
+type TransactionsResponse struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+}
+
+// TransactionsQuery is the coin history request of a user as it comes from
+// the API. From is inclusive, To is exclusive, zero values are not applied.
+type TransactionsQuery struct {
+	Direction    string
+	Counterparty string
+	MinAmount    int
+	MaxAmount    int
+	From         time.Time
+	To           time.Time
+	Cursor       string
+	Limit        int
+}
+
+// TransactionsFilter selects a page of coin transactions of a user, newest
+// first. A page continues after AfterTimestamp/AfterID when AfterID is set.
+type TransactionsFilter struct {
+	UserID         int
+	Direction      string
+	Counterparty   string
+	MinAmount      int
+	MaxAmount      int
+	From           time.Time
+	To             time.Time
+	AfterTimestamp time.Time
+	AfterID        int64
+	Limit          int
 }
 
 type User struct {
@@ -81,6 +391,7 @@ type User struct {
 	Username     string `db:"username"`
 	PasswordHash string `db:"password_hash"`
 	Coins        int    `db:"coins"`
+	Role         string `db:"role"`
 }
 
 type Inventory struct {
@@ -90,6 +401,116 @@ type Inventory struct {
 	Quantity int    `db:"quantity"`
 }
 
+// @Description Публичные ключи для проверки токенов
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// @Description Публичный ключ в формате JWK
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// TokenClaims is the verified content of an access token
+type TokenClaims struct {
+	UserID    int
+	SessionID string
+	Role      string
+}
+
+type RefreshToken struct {
+	ID             int64        `db:"id"`
+	SessionID      string       `db:"session_id"`
+	UserID         int          `db:"user_id"`
+	Role           string       `db:"role"`
+	ExpiresAt      time.Time    `db:"expires_at"`
+	UsedAt         sql.NullTime `db:"used_at"`
+	SessionRevoked bool         `db:"session_revoked"`
+}
+
+// @Description Пользователь глазами администратора
+type AdminUserResponse struct {
+	ID       int    `json:"id" db:"id"`
+	Username string `json:"username" db:"username"`
+	Role     string `json:"role" db:"role"`
+	Coins    int    `json:"coins" db:"coins"`
+}
+
+// @Description Запрос на смену роли пользователя
+type SetRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// Kinds of coin_transactions rows. Everything but a transfer between users
+// has the system as the other side.
+const (
+	CoinTxTransfer   = "transfer"
+	CoinTxGrant      = "grant"
+	CoinTxDeduction  = "deduction"
+	CoinTxAdjustment = "adjustment"
+	CoinTxBonus      = "bonus"
+	CoinTxRefund     = "refund"
+)
+
+// @Description Начисление или списание коинов администратором
+type CoinAdjustmentRequest struct {
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+// @Description Установка баланса администратором
+type SetBalanceRequest struct {
+	Balance int    `json:"balance"`
+	Reason  string `json:"reason"`
+}
+
+// BalanceAdjustment is an admin change of a balance. Amount is the coins to
+// grant or deduct, or the new balance for an adjustment.
+type BalanceAdjustment struct {
+	UserID         int
+	ActorID        int
+	Kind           string
+	Amount         int
+	Reason         string
+	IdempotencyKey string
+}
+
+// @Description Результат изменения баланса
+type BalanceAdjustmentResponse struct {
+	TransactionID int64  `json:"transactionId" db:"id"`
+	UserID        int    `json:"userId" db:"user_id"`
+	Kind          string `json:"kind" db:"kind"`
+	Delta         int    `json:"delta" db:"delta"`
+	Balance       int    `json:"balance" db:"balance_after"`
+}
+
+// IdempotencyRecord is the stored outcome of a user request made with an
+// Idempotency-Key. StatusCode is not valid while the request is running.
+type IdempotencyRecord struct {
+	UserID      int           `db:"user_id"`
+	Key         string        `db:"key"`
+	Fingerprint string        `db:"fingerprint"`
+	StatusCode  sql.NullInt32 `db:"status_code"`
+	Response    []byte        `db:"response"`
+	CreatedAt   time.Time     `db:"created_at"`
+	ExpiresAt   time.Time     `db:"expires_at"`
+}
+
+// LoginAttempt is the failed login counter of a single key, either a
+// username or a client IP.
+type LoginAttempt struct {
+	Key         string       `db:"key"`
+	Failures    int          `db:"failures"`
+	LockedUntil sql.NullTime `db:"locked_until"`
+}
+
 type CoinTransaction struct {
 	ID         int64         `db:"id"`
 	SenderID   sql.NullInt64 `db:"sender_id"`
@@ -98,7 +519,25 @@ type CoinTransaction struct {
 	Timestamp  time.Time     `db:"timestamp"`
 }
 
-func (a *AuthRequest) HashPass() {
-	hash := sha256.Sum256([]byte(a.Password))
-	a.Password = hex.EncodeToString(hash[:])
+// BalanceDrift is a user whose cached balance in users.coins differs from
+// the sum of the postings of the user. Drift is Coins - Expected. Error
+// tells why a drift found with fixing turned on is not fixed.
+type BalanceDrift struct {
+	UserID   int    `json:"userId" db:"user_id"`
+	Username string `json:"username" db:"username"`
+	Coins    int    `json:"coins" db:"coins"`
+	Expected int    `json:"expected" db:"expected"`
+	Drift    int    `json:"drift"`
+	Fixed    bool   `json:"fixed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReconcileReport is the outcome of one reconciliation of the balances. In a
+// dry run the drifted balances are only reported.
+type ReconcileReport struct {
+	StartedAt time.Time      `json:"startedAt"`
+	DryRun    bool           `json:"dryRun"`
+	Checked   int            `json:"checked"`
+	Drifted   []BalanceDrift `json:"drifted"`
+	Fixed     int            `json:"fixed"`
 }
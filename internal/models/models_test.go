@@ -1,44 +1,119 @@
 package models
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestAuthRequest_HashPass(t *testing.T) {
-	tests := []struct {
-		name     string
-		password string
-	}{
-		{
-			name:     "normal password",
-			password: "secret123",
-		},
-		{
-			name:     "empty password",
-			password: "",
-		},
-		{
-			name:     "long password",
-			password: "this is a very long password for testing purposes",
-		},
-	}
+// The catalog exists three times: the items seed in the migrations, the
+// ItemForBuy constants and the enum swag generates from them. These tests
+// fail as soon as one of them changes without the others.
+
+var (
+	itemsInsert = regexp.MustCompile(`(?is)insert\s+into\s+items\s*\([^)]*\)\s*values(.*?);`)
+	itemsDelete = regexp.MustCompile(`(?is)delete\s+from\s+items\s+where\s+name\s*=\s*'([^']+)'`)
+	seedRow     = regexp.MustCompile(`\(\s*'([^']+)'`)
+)
+
+func seededItems(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	sort.Strings(files)
+
+	items := map[string]bool{}
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		require.NoError(t, err)
 
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			req := &AuthRequest{
-				Username: "testuser",
-				Password: tc.password,
+		for _, insert := range itemsInsert.FindAllStringSubmatch(string(sql), -1) {
+			for _, row := range seedRow.FindAllStringSubmatch(insert[1], -1) {
+				items[row[1]] = true
 			}
+		}
+		for _, deleted := range itemsDelete.FindAllStringSubmatch(string(sql), -1) {
+			delete(items, deleted[1])
+		}
+	}
+	return sortedKeys(items)
+}
+
+func itemConstants(t *testing.T) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "models.go", nil, 0)
+	require.NoError(t, err)
+
+	items := map[string]bool{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		if ident, ok := spec.Type.(*ast.Ident); !ok || ident.Name != "ItemForBuy" {
+			return true
+		}
+		for _, value := range spec.Values {
+			lit, ok := value.(*ast.BasicLit)
+			require.True(t, ok, "ItemForBuy constants must be string literals")
+			name, err := strconv.Unquote(lit.Value)
+			require.NoError(t, err)
+			items[name] = true
+		}
+		return true
+	})
+	return sortedKeys(items)
+}
 
-			hash := sha256.Sum256([]byte(tc.password))
-			expected := hex.EncodeToString(hash[:])
+func swaggerItems(t *testing.T) []string {
+	raw, err := os.ReadFile(filepath.Join("..", "..", "docs", "swagger.json"))
+	require.NoError(t, err)
 
-			req.HashPass()
-			assert.Equal(t, expected, req.Password)
-		})
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name string   `json:"name"`
+				Enum []string `json:"enum"`
+			} `json:"parameters"`
+		} `json:"paths"`
 	}
+	require.NoError(t, json.Unmarshal(raw, &doc))
+
+	items := map[string]bool{}
+	for _, param := range doc.Paths["/api/buy/{item}"]["post"].Parameters {
+		if param.Name == "item" {
+			for _, name := range param.Enum {
+				items[name] = true
+			}
+		}
+	}
+	return sortedKeys(items)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestItemForBuyMatchesSeed(t *testing.T) {
+	seeded := seededItems(t)
+	require.NotEmpty(t, seeded)
+	assert.Equal(t, seeded, itemConstants(t), "ItemForBuy constants differ from the items seeded by migrations")
+}
+
+func TestSwaggerEnumMatchesSeed(t *testing.T) {
+	assert.Equal(t, seededItems(t), swaggerItems(t), "swagger enum is stale, regenerate the docs with swag init")
 }
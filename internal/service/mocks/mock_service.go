@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 	models "testAlvtoShp/internal/models"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -50,6 +51,21 @@ func (mr *MockAuthMockRecorder) CheckPassword(ctx, req interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPassword", reflect.TypeOf((*MockAuth)(nil).CheckPassword), ctx, req)
 }
 
+// CreateInvite mocks base method.
+func (m *MockAuth) CreateInvite(ctx context.Context, userId int) (models.InviteResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateInvite", ctx, userId)
+	ret0, _ := ret[0].(models.InviteResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateInvite indicates an expected call of CreateInvite.
+func (mr *MockAuthMockRecorder) CreateInvite(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInvite", reflect.TypeOf((*MockAuth)(nil).CreateInvite), ctx, userId)
+}
+
 // CreateUser mocks base method.
 func (m *MockAuth) CreateUser(ctx context.Context, req models.AuthRequest) (int, error) {
 	m.ctrl.T.Helper()
@@ -65,49 +81,255 @@ func (mr *MockAuthMockRecorder) CreateUser(ctx, req interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockAuth)(nil).CreateUser), ctx, req)
 }
 
-// ExtractUserIDFromAccessToken mocks base method.
-func (m *MockAuth) ExtractUserIDFromAccessToken(ctx context.Context, accessToken string) (int, error) {
+// GenerateTokens mocks base method.
+func (m *MockAuth) GenerateTokens(ctx context.Context, id int) (models.AuthResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateTokens", ctx, id)
+	ret0, _ := ret[0].(models.AuthResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateTokens indicates an expected call of GenerateTokens.
+func (mr *MockAuthMockRecorder) GenerateTokens(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateTokens", reflect.TypeOf((*MockAuth)(nil).GenerateTokens), ctx, id)
+}
+
+// GetUserByUsername mocks base method.
+func (m *MockAuth) GetUserByUsername(ctx context.Context, username string) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ExtractUserIDFromAccessToken", ctx, accessToken)
+	ret := m.ctrl.Call(m, "GetUserByUsername", ctx, username)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ExtractUserIDFromAccessToken indicates an expected call of ExtractUserIDFromAccessToken.
-func (mr *MockAuthMockRecorder) ExtractUserIDFromAccessToken(ctx, accessToken interface{}) *gomock.Call {
+// GetUserByUsername indicates an expected call of GetUserByUsername.
+func (mr *MockAuthMockRecorder) GetUserByUsername(ctx, username interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractUserIDFromAccessToken", reflect.TypeOf((*MockAuth)(nil).ExtractUserIDFromAccessToken), ctx, accessToken)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockAuth)(nil).GetUserByUsername), ctx, username)
 }
 
-// GenerateToken mocks base method.
-func (m *MockAuth) GenerateToken(id int) (string, error) {
+// JWKS mocks base method.
+func (m *MockAuth) JWKS() models.JWKS {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenerateToken", id)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(models.JWKS)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockAuthMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockAuth)(nil).JWKS))
+}
+
+// Logout mocks base method.
+func (m *MockAuth) Logout(ctx context.Context, sessionId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Logout", ctx, sessionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Logout indicates an expected call of Logout.
+func (mr *MockAuthMockRecorder) Logout(ctx, sessionId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Logout", reflect.TypeOf((*MockAuth)(nil).Logout), ctx, sessionId)
+}
+
+// LogoutAll mocks base method.
+func (m *MockAuth) LogoutAll(ctx context.Context, userId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogoutAll", ctx, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LogoutAll indicates an expected call of LogoutAll.
+func (mr *MockAuthMockRecorder) LogoutAll(ctx, userId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogoutAll", reflect.TypeOf((*MockAuth)(nil).LogoutAll), ctx, userId)
+}
+
+// ParseAccessToken mocks base method.
+func (m *MockAuth) ParseAccessToken(ctx context.Context, accessToken string) (models.TokenClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseAccessToken", ctx, accessToken)
+	ret0, _ := ret[0].(models.TokenClaims)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GenerateToken indicates an expected call of GenerateToken.
-func (mr *MockAuthMockRecorder) GenerateToken(id interface{}) *gomock.Call {
+// ParseAccessToken indicates an expected call of ParseAccessToken.
+func (mr *MockAuthMockRecorder) ParseAccessToken(ctx, accessToken interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateToken", reflect.TypeOf((*MockAuth)(nil).GenerateToken), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseAccessToken", reflect.TypeOf((*MockAuth)(nil).ParseAccessToken), ctx, accessToken)
 }
 
-// GetUserByUsername mocks base method.
-func (m *MockAuth) GetUserByUsername(ctx context.Context, username string) (int, error) {
+// RefreshTokens mocks base method.
+func (m *MockAuth) RefreshTokens(ctx context.Context, refreshToken string) (models.AuthResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByUsername", ctx, username)
+	ret := m.ctrl.Call(m, "RefreshTokens", ctx, refreshToken)
+	ret0, _ := ret[0].(models.AuthResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockAuthMockRecorder) RefreshTokens(ctx, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockAuth)(nil).RefreshTokens), ctx, refreshToken)
+}
+
+// Register mocks base method.
+func (m *MockAuth) Register(ctx context.Context, req models.RegisterRequest) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Register", ctx, req)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByUsername indicates an expected call of GetUserByUsername.
-func (mr *MockAuthMockRecorder) GetUserByUsername(ctx, username interface{}) *gomock.Call {
+// Register indicates an expected call of Register.
+func (mr *MockAuthMockRecorder) Register(ctx, req interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockAuth)(nil).GetUserByUsername), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockAuth)(nil).Register), ctx, req)
+}
+
+// MockLoginGuard is a mock of LoginGuard interface.
+type MockLoginGuard struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginGuardMockRecorder
+}
+
+// MockLoginGuardMockRecorder is the mock recorder for MockLoginGuard.
+type MockLoginGuardMockRecorder struct {
+	mock *MockLoginGuard
+}
+
+// NewMockLoginGuard creates a new mock instance.
+func NewMockLoginGuard(ctrl *gomock.Controller) *MockLoginGuard {
+	mock := &MockLoginGuard{ctrl: ctrl}
+	mock.recorder = &MockLoginGuardMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginGuard) EXPECT() *MockLoginGuardMockRecorder {
+	return m.recorder
+}
+
+// CheckLogin mocks base method.
+func (m *MockLoginGuard) CheckLogin(ctx context.Context, username, ip string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckLogin", ctx, username, ip)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckLogin indicates an expected call of CheckLogin.
+func (mr *MockLoginGuardMockRecorder) CheckLogin(ctx, username, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckLogin", reflect.TypeOf((*MockLoginGuard)(nil).CheckLogin), ctx, username, ip)
+}
+
+// RecordLoginFailure mocks base method.
+func (m *MockLoginGuard) RecordLoginFailure(ctx context.Context, username, ip string) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, username, ip)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockLoginGuardMockRecorder) RecordLoginFailure(ctx, username, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockLoginGuard)(nil).RecordLoginFailure), ctx, username, ip)
+}
+
+// RecordLoginSuccess mocks base method.
+func (m *MockLoginGuard) RecordLoginSuccess(ctx context.Context, username, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginSuccess", ctx, username, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordLoginSuccess indicates an expected call of RecordLoginSuccess.
+func (mr *MockLoginGuardMockRecorder) RecordLoginSuccess(ctx, username, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginSuccess", reflect.TypeOf((*MockLoginGuard)(nil).RecordLoginSuccess), ctx, username, ip)
+}
+
+// MockAdmin is a mock of Admin interface.
+type MockAdmin struct {
+	ctrl     *gomock.Controller
+	recorder *MockAdminMockRecorder
+}
+
+// MockAdminMockRecorder is the mock recorder for MockAdmin.
+type MockAdminMockRecorder struct {
+	mock *MockAdmin
+}
+
+// NewMockAdmin creates a new mock instance.
+func NewMockAdmin(ctrl *gomock.Controller) *MockAdmin {
+	mock := &MockAdmin{ctrl: ctrl}
+	mock.recorder = &MockAdminMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAdmin) EXPECT() *MockAdminMockRecorder {
+	return m.recorder
+}
+
+// AdjustBalance mocks base method.
+func (m *MockAdmin) AdjustBalance(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustmentResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", ctx, adj)
+	ret0, _ := ret[0].(models.BalanceAdjustmentResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockAdminMockRecorder) AdjustBalance(ctx, adj interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockAdmin)(nil).AdjustBalance), ctx, adj)
+}
+
+// GetUser mocks base method.
+func (m *MockAdmin) GetUser(ctx context.Context, username string) (models.AdminUserResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, username)
+	ret0, _ := ret[0].(models.AdminUserResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockAdminMockRecorder) GetUser(ctx, username interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockAdmin)(nil).GetUser), ctx, username)
+}
+
+// SetUserRole mocks base method.
+func (m *MockAdmin) SetUserRole(ctx context.Context, actorId, userId int, role string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRole", ctx, actorId, userId, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserRole indicates an expected call of SetUserRole.
+func (mr *MockAdminMockRecorder) SetUserRole(ctx, actorId, userId, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRole", reflect.TypeOf((*MockAdmin)(nil).SetUserRole), ctx, actorId, userId, role)
 }
 
 // MockShop is a mock of Shop interface.
@@ -134,17 +356,62 @@ func (m *MockShop) EXPECT() *MockShopMockRecorder {
 }
 
 // BuyItem mocks base method.
-func (m *MockShop) BuyItem(ctx context.Context, userId int, item string) error {
+func (m *MockShop) BuyItem(ctx context.Context, userId int, item string, quantity int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BuyItem", ctx, userId, item)
+	ret := m.ctrl.Call(m, "BuyItem", ctx, userId, item, quantity)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // BuyItem indicates an expected call of BuyItem.
-func (mr *MockShopMockRecorder) BuyItem(ctx, userId, item interface{}) *gomock.Call {
+func (mr *MockShopMockRecorder) BuyItem(ctx, userId, item, quantity interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockShop)(nil).BuyItem), ctx, userId, item)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BuyItem", reflect.TypeOf((*MockShop)(nil).BuyItem), ctx, userId, item, quantity)
+}
+
+// Checkout mocks base method.
+func (m *MockShop) Checkout(ctx context.Context, userId int, req models.CheckoutRequest) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checkout", ctx, userId, req)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Checkout indicates an expected call of Checkout.
+func (mr *MockShopMockRecorder) Checkout(ctx, userId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checkout", reflect.TypeOf((*MockShop)(nil).Checkout), ctx, userId, req)
+}
+
+// GetOrders mocks base method.
+func (m *MockShop) GetOrders(ctx context.Context, userId int, query models.OrdersQuery) (models.OrdersResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOrders", ctx, userId, query)
+	ret0, _ := ret[0].(models.OrdersResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOrders indicates an expected call of GetOrders.
+func (mr *MockShopMockRecorder) GetOrders(ctx, userId, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrders", reflect.TypeOf((*MockShop)(nil).GetOrders), ctx, userId, query)
+}
+
+// GetTransactions mocks base method.
+func (m *MockShop) GetTransactions(ctx context.Context, userId int, query models.TransactionsQuery) (models.TransactionsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactions", ctx, userId, query)
+	ret0, _ := ret[0].(models.TransactionsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactions indicates an expected call of GetTransactions.
+func (mr *MockShopMockRecorder) GetTransactions(ctx, userId, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactions", reflect.TypeOf((*MockShop)(nil).GetTransactions), ctx, userId, query)
 }
 
 // GetUserInfo mocks base method.
@@ -162,6 +429,21 @@ func (mr *MockShopMockRecorder) GetUserInfo(ctx, userId interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserInfo", reflect.TypeOf((*MockShop)(nil).GetUserInfo), ctx, userId)
 }
 
+// Gift mocks base method.
+func (m *MockShop) Gift(ctx context.Context, userId int, req models.GiftRequest) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Gift", ctx, userId, req)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Gift indicates an expected call of Gift.
+func (mr *MockShopMockRecorder) Gift(ctx, userId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Gift", reflect.TypeOf((*MockShop)(nil).Gift), ctx, userId, req)
+}
+
 // SendCoin mocks base method.
 func (m *MockShop) SendCoin(ctx context.Context, userId int, req models.SendCoinRequest) error {
 	m.ctrl.T.Helper()
@@ -175,3 +457,397 @@ func (mr *MockShopMockRecorder) SendCoin(ctx, userId, req interface{}) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendCoin", reflect.TypeOf((*MockShop)(nil).SendCoin), ctx, userId, req)
 }
+
+// TransferItem mocks base method.
+func (m *MockShop) TransferItem(ctx context.Context, userId int, req models.ItemTransferRequest) (models.ItemTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferItem", ctx, userId, req)
+	ret0, _ := ret[0].(models.ItemTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferItem indicates an expected call of TransferItem.
+func (mr *MockShopMockRecorder) TransferItem(ctx, userId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferItem", reflect.TypeOf((*MockShop)(nil).TransferItem), ctx, userId, req)
+}
+
+// MockCatalog is a mock of Catalog interface.
+type MockCatalog struct {
+	ctrl     *gomock.Controller
+	recorder *MockCatalogMockRecorder
+}
+
+// MockCatalogMockRecorder is the mock recorder for MockCatalog.
+type MockCatalogMockRecorder struct {
+	mock *MockCatalog
+}
+
+// NewMockCatalog creates a new mock instance.
+func NewMockCatalog(ctrl *gomock.Controller) *MockCatalog {
+	mock := &MockCatalog{ctrl: ctrl}
+	mock.recorder = &MockCatalogMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCatalog) EXPECT() *MockCatalogMockRecorder {
+	return m.recorder
+}
+
+// CreateItem mocks base method.
+func (m *MockCatalog) CreateItem(ctx context.Context, actorId int, req models.CreateItemRequest) (models.CatalogItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateItem", ctx, actorId, req)
+	ret0, _ := ret[0].(models.CatalogItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateItem indicates an expected call of CreateItem.
+func (mr *MockCatalogMockRecorder) CreateItem(ctx, actorId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateItem", reflect.TypeOf((*MockCatalog)(nil).CreateItem), ctx, actorId, req)
+}
+
+// GetItems mocks base method.
+func (m *MockCatalog) GetItems(ctx context.Context) (models.CatalogResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetItems", ctx)
+	ret0, _ := ret[0].(models.CatalogResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetItems indicates an expected call of GetItems.
+func (mr *MockCatalogMockRecorder) GetItems(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetItems", reflect.TypeOf((*MockCatalog)(nil).GetItems), ctx)
+}
+
+// GetPriceHistory mocks base method.
+func (m *MockCatalog) GetPriceHistory(ctx context.Context, name string) ([]models.ItemPrice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPriceHistory", ctx, name)
+	ret0, _ := ret[0].([]models.ItemPrice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPriceHistory indicates an expected call of GetPriceHistory.
+func (mr *MockCatalogMockRecorder) GetPriceHistory(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPriceHistory", reflect.TypeOf((*MockCatalog)(nil).GetPriceHistory), ctx, name)
+}
+
+// RetireItem mocks base method.
+func (m *MockCatalog) RetireItem(ctx context.Context, actorId int, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetireItem", ctx, actorId, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetireItem indicates an expected call of RetireItem.
+func (mr *MockCatalogMockRecorder) RetireItem(ctx, actorId, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetireItem", reflect.TypeOf((*MockCatalog)(nil).RetireItem), ctx, actorId, name)
+}
+
+// SetItemLimits mocks base method.
+func (m *MockCatalog) SetItemLimits(ctx context.Context, actorId int, name string, req models.ItemLimitsRequest) (models.CatalogItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetItemLimits", ctx, actorId, name, req)
+	ret0, _ := ret[0].(models.CatalogItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetItemLimits indicates an expected call of SetItemLimits.
+func (mr *MockCatalogMockRecorder) SetItemLimits(ctx, actorId, name, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetItemLimits", reflect.TypeOf((*MockCatalog)(nil).SetItemLimits), ctx, actorId, name, req)
+}
+
+// SetItemPrice mocks base method.
+func (m *MockCatalog) SetItemPrice(ctx context.Context, actorId int, name string, price int) (models.CatalogItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetItemPrice", ctx, actorId, name, price)
+	ret0, _ := ret[0].(models.CatalogItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetItemPrice indicates an expected call of SetItemPrice.
+func (mr *MockCatalogMockRecorder) SetItemPrice(ctx, actorId, name, price interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetItemPrice", reflect.TypeOf((*MockCatalog)(nil).SetItemPrice), ctx, actorId, name, price)
+}
+
+// MockReturns is a mock of Returns interface.
+type MockReturns struct {
+	ctrl     *gomock.Controller
+	recorder *MockReturnsMockRecorder
+}
+
+// MockReturnsMockRecorder is the mock recorder for MockReturns.
+type MockReturnsMockRecorder struct {
+	mock *MockReturns
+}
+
+// NewMockReturns creates a new mock instance.
+func NewMockReturns(ctrl *gomock.Controller) *MockReturns {
+	mock := &MockReturns{ctrl: ctrl}
+	mock.recorder = &MockReturnsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReturns) EXPECT() *MockReturnsMockRecorder {
+	return m.recorder
+}
+
+// ApproveReturn mocks base method.
+func (m *MockReturns) ApproveReturn(ctx context.Context, actorId int, returnId int64, req models.ReturnDecisionRequest) (models.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveReturn", ctx, actorId, returnId, req)
+	ret0, _ := ret[0].(models.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveReturn indicates an expected call of ApproveReturn.
+func (mr *MockReturnsMockRecorder) ApproveReturn(ctx, actorId, returnId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveReturn", reflect.TypeOf((*MockReturns)(nil).ApproveReturn), ctx, actorId, returnId, req)
+}
+
+// GetReturns mocks base method.
+func (m *MockReturns) GetReturns(ctx context.Context, query models.ReturnsQuery) (models.ReturnsResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReturns", ctx, query)
+	ret0, _ := ret[0].(models.ReturnsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReturns indicates an expected call of GetReturns.
+func (mr *MockReturnsMockRecorder) GetReturns(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReturns", reflect.TypeOf((*MockReturns)(nil).GetReturns), ctx, query)
+}
+
+// RejectReturn mocks base method.
+func (m *MockReturns) RejectReturn(ctx context.Context, actorId int, returnId int64, req models.ReturnDecisionRequest) (models.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectReturn", ctx, actorId, returnId, req)
+	ret0, _ := ret[0].(models.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RejectReturn indicates an expected call of RejectReturn.
+func (mr *MockReturnsMockRecorder) RejectReturn(ctx, actorId, returnId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectReturn", reflect.TypeOf((*MockReturns)(nil).RejectReturn), ctx, actorId, returnId, req)
+}
+
+// RequestReturn mocks base method.
+func (m *MockReturns) RequestReturn(ctx context.Context, userId int, orderId int64, req models.ReturnRequest) (models.Return, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestReturn", ctx, userId, orderId, req)
+	ret0, _ := ret[0].(models.Return)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestReturn indicates an expected call of RequestReturn.
+func (mr *MockReturnsMockRecorder) RequestReturn(ctx, userId, orderId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestReturn", reflect.TypeOf((*MockReturns)(nil).RequestReturn), ctx, userId, orderId, req)
+}
+
+// MockCoinRequests is a mock of CoinRequests interface.
+type MockCoinRequests struct {
+	ctrl     *gomock.Controller
+	recorder *MockCoinRequestsMockRecorder
+}
+
+// MockCoinRequestsMockRecorder is the mock recorder for MockCoinRequests.
+type MockCoinRequestsMockRecorder struct {
+	mock *MockCoinRequests
+}
+
+// NewMockCoinRequests creates a new mock instance.
+func NewMockCoinRequests(ctrl *gomock.Controller) *MockCoinRequests {
+	mock := &MockCoinRequests{ctrl: ctrl}
+	mock.recorder = &MockCoinRequestsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCoinRequests) EXPECT() *MockCoinRequestsMockRecorder {
+	return m.recorder
+}
+
+// AcceptCoinRequest mocks base method.
+func (m *MockCoinRequests) AcceptCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptCoinRequest", ctx, userId, requestId)
+	ret0, _ := ret[0].(models.CoinRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AcceptCoinRequest indicates an expected call of AcceptCoinRequest.
+func (mr *MockCoinRequestsMockRecorder) AcceptCoinRequest(ctx, userId, requestId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptCoinRequest", reflect.TypeOf((*MockCoinRequests)(nil).AcceptCoinRequest), ctx, userId, requestId)
+}
+
+// DeclineCoinRequest mocks base method.
+func (m *MockCoinRequests) DeclineCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeclineCoinRequest", ctx, userId, requestId)
+	ret0, _ := ret[0].(models.CoinRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeclineCoinRequest indicates an expected call of DeclineCoinRequest.
+func (mr *MockCoinRequestsMockRecorder) DeclineCoinRequest(ctx, userId, requestId interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeclineCoinRequest", reflect.TypeOf((*MockCoinRequests)(nil).DeclineCoinRequest), ctx, userId, requestId)
+}
+
+// RequestCoins mocks base method.
+func (m *MockCoinRequests) RequestCoins(ctx context.Context, userId int, req models.CreateCoinRequest) (models.CoinRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestCoins", ctx, userId, req)
+	ret0, _ := ret[0].(models.CoinRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestCoins indicates an expected call of RequestCoins.
+func (mr *MockCoinRequestsMockRecorder) RequestCoins(ctx, userId, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestCoins", reflect.TypeOf((*MockCoinRequests)(nil).RequestCoins), ctx, userId, req)
+}
+
+// MockReconciler is a mock of Reconciler interface.
+type MockReconciler struct {
+	ctrl     *gomock.Controller
+	recorder *MockReconcilerMockRecorder
+}
+
+// MockReconcilerMockRecorder is the mock recorder for MockReconciler.
+type MockReconcilerMockRecorder struct {
+	mock *MockReconciler
+}
+
+// NewMockReconciler creates a new mock instance.
+func NewMockReconciler(ctrl *gomock.Controller) *MockReconciler {
+	mock := &MockReconciler{ctrl: ctrl}
+	mock.recorder = &MockReconcilerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReconciler) EXPECT() *MockReconcilerMockRecorder {
+	return m.recorder
+}
+
+// Reconcile mocks base method.
+func (m *MockReconciler) Reconcile(ctx context.Context, fix bool) (models.ReconcileReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reconcile", ctx, fix)
+	ret0, _ := ret[0].(models.ReconcileReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reconcile indicates an expected call of Reconcile.
+func (mr *MockReconcilerMockRecorder) Reconcile(ctx, fix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reconcile", reflect.TypeOf((*MockReconciler)(nil).Reconcile), ctx, fix)
+}
+
+// Run mocks base method.
+func (m *MockReconciler) Run(ctx context.Context, interval time.Duration, fix bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Run", ctx, interval, fix)
+}
+
+// Run indicates an expected call of Run.
+func (mr *MockReconcilerMockRecorder) Run(ctx, interval, fix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockReconciler)(nil).Run), ctx, interval, fix)
+}
+
+// MockIdempotency is a mock of Idempotency interface.
+type MockIdempotency struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyMockRecorder
+}
+
+// MockIdempotencyMockRecorder is the mock recorder for MockIdempotency.
+type MockIdempotencyMockRecorder struct {
+	mock *MockIdempotency
+}
+
+// NewMockIdempotency creates a new mock instance.
+func NewMockIdempotency(ctrl *gomock.Controller) *MockIdempotency {
+	mock := &MockIdempotency{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdempotency) EXPECT() *MockIdempotencyMockRecorder {
+	return m.recorder
+}
+
+// AbortIdempotent mocks base method.
+func (m *MockIdempotency) AbortIdempotent(ctx context.Context, userId int, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AbortIdempotent", ctx, userId, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AbortIdempotent indicates an expected call of AbortIdempotent.
+func (mr *MockIdempotencyMockRecorder) AbortIdempotent(ctx, userId, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbortIdempotent", reflect.TypeOf((*MockIdempotency)(nil).AbortIdempotent), ctx, userId, key)
+}
+
+// BeginIdempotent mocks base method.
+func (m *MockIdempotency) BeginIdempotent(ctx context.Context, userId int, key, fingerprint string) (*models.IdempotencyRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginIdempotent", ctx, userId, key, fingerprint)
+	ret0, _ := ret[0].(*models.IdempotencyRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginIdempotent indicates an expected call of BeginIdempotent.
+func (mr *MockIdempotencyMockRecorder) BeginIdempotent(ctx, userId, key, fingerprint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginIdempotent", reflect.TypeOf((*MockIdempotency)(nil).BeginIdempotent), ctx, userId, key, fingerprint)
+}
+
+// CompleteIdempotent mocks base method.
+func (m *MockIdempotency) CompleteIdempotent(ctx context.Context, userId int, key string, statusCode int, response []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotent", ctx, userId, key, statusCode, response)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotent indicates an expected call of CompleteIdempotent.
+func (mr *MockIdempotencyMockRecorder) CompleteIdempotent(ctx, userId, key, statusCode, response interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotent", reflect.TypeOf((*MockIdempotency)(nil).CompleteIdempotent), ctx, userId, key, statusCode, response)
+}
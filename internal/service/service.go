@@ -2,33 +2,148 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"testAlvtoShp/internal/config"
 	"testAlvtoShp/internal/models"
 	"testAlvtoShp/internal/store"
+	"time"
 )
 
 type Service struct {
 	Auth
+	LoginGuard
+	Admin
 	Shop
+	Catalog
+	Idempotency
+	Returns
+	CoinRequests
+	Reconciler
 }
 
-func NewService(store *store.Store, cfg *config.Config) *Service {
-	return &Service{
-		Auth: NewAuthService(store.Auth, cfg.JwtSecret),
-		Shop: NewShopService(store.Shop),
+func NewService(store *store.Store, cfg *config.Config) (*Service, error) {
+	switch cfg.RegistrationMode {
+	case RegistrationOpen, RegistrationExplicit, RegistrationInvite:
+	default:
+		return nil, fmt.Errorf("unknown registration mode %q", cfg.RegistrationMode)
+	}
+
+	if cfg.WelcomeBonus < 0 {
+		return nil, fmt.Errorf("welcome bonus must not be negative, got %d", cfg.WelcomeBonus)
+	}
+
+	switch cfg.LoginAttemptsStore {
+	case LoginAttemptsPostgres, LoginAttemptsMemory:
+	default:
+		return nil, fmt.Errorf("unknown login attempts store %q", cfg.LoginAttemptsStore)
+	}
+
+	if cfg.IdempotencyKeyTTL <= 0 {
+		return nil, fmt.Errorf("idempotency key ttl must be positive, got %s", cfg.IdempotencyKeyTTL)
+	}
+
+	if cfg.ReturnWindow <= 0 {
+		return nil, fmt.Errorf("return window must be positive, got %s", cfg.ReturnWindow)
+	}
+
+	if cfg.InfoHistoryLimit <= 0 {
+		return nil, fmt.Errorf("info history limit must be positive, got %d", cfg.InfoHistoryLimit)
+	}
+
+	if cfg.CoinRequestTTL <= 0 {
+		return nil, fmt.Errorf("coin request ttl must be positive, got %s", cfg.CoinRequestTTL)
+	}
+
+	if cfg.ReconcileInterval < 0 {
+		return nil, fmt.Errorf("reconcile interval must not be negative, got %s", cfg.ReconcileInterval)
+	}
+
+	keys, err := LoadKeySet(cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	return &Service{
+		Auth:         NewAuthService(store.Auth, store.Session, NewPasswordHasher(cfg.PasswordHashAlgo), keys, cfg),
+		LoginGuard:   NewLoginGuardService(store.LoginAttempts, cfg),
+		Admin:        NewAdminService(store.Admin),
+		Shop:         NewShopService(store.Shop, cfg),
+		Catalog:      NewCatalogService(store.Catalog),
+		Idempotency:  NewIdempotencyService(store.Idempotency, cfg),
+		Returns:      NewReturnService(store.Returns, cfg),
+		CoinRequests: NewCoinRequestService(store.CoinRequests, cfg),
+		Reconciler:   NewReconcileService(store.Ledger),
+	}, nil
 }
 
 type Auth interface {
 	GetUserByUsername(ctx context.Context, username string) (int, error)
-	GenerateToken(id int) (string, error)
+	GenerateTokens(ctx context.Context, id int) (models.AuthResponse, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (models.AuthResponse, error)
+	Logout(ctx context.Context, sessionId string) error
+	LogoutAll(ctx context.Context, userId int) error
 	CreateUser(ctx context.Context, req models.AuthRequest) (int, error)
+	Register(ctx context.Context, req models.RegisterRequest) (int, error)
+	CreateInvite(ctx context.Context, userId int) (models.InviteResponse, error)
 	CheckPassword(ctx context.Context, req models.AuthRequest) (bool, error)
-	ExtractUserIDFromAccessToken(ctx context.Context, accessToken string) (int, error)
+	ParseAccessToken(ctx context.Context, accessToken string) (models.TokenClaims, error)
+	JWKS() models.JWKS
+}
+
+type LoginGuard interface {
+	CheckLogin(ctx context.Context, username, ip string) (time.Duration, error)
+	RecordLoginFailure(ctx context.Context, username, ip string) (time.Duration, error)
+	RecordLoginSuccess(ctx context.Context, username, ip string) error
+}
+
+type Admin interface {
+	GetUser(ctx context.Context, username string) (models.AdminUserResponse, error)
+	SetUserRole(ctx context.Context, actorId, userId int, role string) error
+	AdjustBalance(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustmentResponse, error)
 }
 
 type Shop interface {
 	GetUserInfo(ctx context.Context, userId int) (models.InfoResponse, error)
-	BuyItem(ctx context.Context, userId int, item string) error
+	BuyItem(ctx context.Context, userId int, item string, quantity int) error
+	Checkout(ctx context.Context, userId int, req models.CheckoutRequest) (models.Order, error)
+	Gift(ctx context.Context, userId int, req models.GiftRequest) (models.Order, error)
 	SendCoin(ctx context.Context, userId int, req models.SendCoinRequest) error
+	TransferItem(ctx context.Context, userId int, req models.ItemTransferRequest) (models.ItemTransfer, error)
+	GetOrders(ctx context.Context, userId int, query models.OrdersQuery) (models.OrdersResponse, error)
+	GetTransactions(ctx context.Context, userId int, query models.TransactionsQuery) (models.TransactionsResponse, error)
+}
+
+type Catalog interface {
+	GetItems(ctx context.Context) (models.CatalogResponse, error)
+	CreateItem(ctx context.Context, actorId int, req models.CreateItemRequest) (models.CatalogItem, error)
+	SetItemPrice(ctx context.Context, actorId int, name string, price int) (models.CatalogItem, error)
+	SetItemLimits(ctx context.Context, actorId int, name string, req models.ItemLimitsRequest) (models.CatalogItem, error)
+	RetireItem(ctx context.Context, actorId int, name string) error
+	GetPriceHistory(ctx context.Context, name string) ([]models.ItemPrice, error)
+}
+
+type Returns interface {
+	RequestReturn(ctx context.Context, userId int, orderId int64, req models.ReturnRequest) (models.Return, error)
+	GetReturns(ctx context.Context, query models.ReturnsQuery) (models.ReturnsResponse, error)
+	ApproveReturn(ctx context.Context, actorId int, returnId int64, req models.ReturnDecisionRequest) (models.Return, error)
+	RejectReturn(ctx context.Context, actorId int, returnId int64, req models.ReturnDecisionRequest) (models.Return, error)
+}
+
+type CoinRequests interface {
+	RequestCoins(ctx context.Context, userId int, req models.CreateCoinRequest) (models.CoinRequest, error)
+	AcceptCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error)
+	DeclineCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error)
+}
+
+// Reconciler compares the cached balances with the ledger, either once or
+// every interval until the context is done.
+type Reconciler interface {
+	Reconcile(ctx context.Context, fix bool) (models.ReconcileReport, error)
+	Run(ctx context.Context, interval time.Duration, fix bool)
+}
+
+type Idempotency interface {
+	BeginIdempotent(ctx context.Context, userId int, key, fingerprint string) (*models.IdempotencyRecord, error)
+	CompleteIdempotent(ctx context.Context, userId int, key string, statusCode int, response []byte) error
+	AbortIdempotent(ctx context.Context, userId int, key string) error
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor"
+)
+
+func ValidateRole(role string) error {
+	switch role {
+	case RoleUser, RoleAdmin, RoleAuditor:
+		return nil
+	}
+	return internalErrors.InvalidRole
+}
+
+type AdminService struct {
+	store store.Admin
+}
+
+func NewAdminService(store store.Admin) *AdminService {
+	return &AdminService{store}
+}
+
+func (s *AdminService) GetUser(ctx context.Context, username string) (models.AdminUserResponse, error) {
+	return s.store.GetUser(ctx, username)
+}
+
+func (s *AdminService) SetUserRole(ctx context.Context, actorId, userId int, role string) error {
+	log := logger.LoggerFromContext(ctx)
+
+	if err := ValidateRole(role); err != nil {
+		return err
+	}
+
+	if err := s.store.SetUserRole(ctx, userId, role); err != nil {
+		return err
+	}
+
+	log.Infow("user role changed", "actor_id", actorId, "user_id", userId, "role", role)
+
+	return nil
+}
+
+// maxReasonLength keeps reasons readable in audits, longer texts belong in a ticket.
+const maxReasonLength = 500
+
+func (s *AdminService) AdjustBalance(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustmentResponse, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	switch adj.Kind {
+	case models.CoinTxGrant, models.CoinTxDeduction:
+		if adj.Amount <= 0 {
+			return models.BalanceAdjustmentResponse{}, internalErrors.InvalidAmount
+		}
+	case models.CoinTxAdjustment:
+		if adj.Amount < 0 {
+			return models.BalanceAdjustmentResponse{}, internalErrors.InvalidAmount
+		}
+	default:
+		return models.BalanceAdjustmentResponse{}, fmt.Errorf("unknown balance adjustment kind %q", adj.Kind)
+	}
+
+	adj.Reason = strings.TrimSpace(adj.Reason)
+	if adj.Reason == "" {
+		return models.BalanceAdjustmentResponse{}, fmt.Errorf("%w: reason is required", internalErrors.InvalidReason)
+	}
+	if len(adj.Reason) > maxReasonLength {
+		return models.BalanceAdjustmentResponse{}, fmt.Errorf("%w: at most %d characters", internalErrors.InvalidReason, maxReasonLength)
+	}
+
+	if adj.IdempotencyKey == "" {
+		return models.BalanceAdjustmentResponse{}, internalErrors.IdempotencyKeyRequired
+	}
+	if err := validateIdempotencyKey(adj.IdempotencyKey); err != nil {
+		return models.BalanceAdjustmentResponse{}, err
+	}
+
+	res, err := s.store.AdjustBalance(ctx, adj)
+	if err != nil {
+		return models.BalanceAdjustmentResponse{}, err
+	}
+
+	log.Infow("balance adjusted", "actor_id", adj.ActorID, "user_id", adj.UserID, "kind", adj.Kind,
+		"delta", res.Delta, "balance", res.Balance, "reason", adj.Reason)
+
+	return res, nil
+}
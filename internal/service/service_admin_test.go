@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+type fakeAdminStore struct {
+	store.Admin
+	adjusted *models.BalanceAdjustment
+}
+
+func (f *fakeAdminStore) AdjustBalance(_ context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustmentResponse, error) {
+	f.adjusted = &adj
+	return models.BalanceAdjustmentResponse{UserID: adj.UserID, Kind: adj.Kind}, nil
+}
+
+func TestAdminService_AdjustBalance(t *testing.T) {
+	valid := models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxGrant, Amount: 10, Reason: " prize ", IdempotencyKey: "key"}
+
+	tests := []struct {
+		name        string
+		modify      func(adj *models.BalanceAdjustment)
+		expectedErr error
+	}{
+		{name: "Valid grant", modify: func(adj *models.BalanceAdjustment) {}},
+		{name: "Zero grant", modify: func(adj *models.BalanceAdjustment) { adj.Amount = 0 }, expectedErr: internalErrors.InvalidAmount},
+		{name: "Negative deduction", modify: func(adj *models.BalanceAdjustment) {
+			adj.Kind = models.CoinTxDeduction
+			adj.Amount = -5
+		}, expectedErr: internalErrors.InvalidAmount},
+		{name: "Set to zero", modify: func(adj *models.BalanceAdjustment) {
+			adj.Kind = models.CoinTxAdjustment
+			adj.Amount = 0
+		}},
+		{name: "Set negative", modify: func(adj *models.BalanceAdjustment) {
+			adj.Kind = models.CoinTxAdjustment
+			adj.Amount = -1
+		}, expectedErr: internalErrors.InvalidAmount},
+		{name: "Blank reason", modify: func(adj *models.BalanceAdjustment) { adj.Reason = "   " }, expectedErr: internalErrors.InvalidReason},
+		{name: "Long reason", modify: func(adj *models.BalanceAdjustment) {
+			adj.Reason = strings.Repeat("a", maxReasonLength+1)
+		}, expectedErr: internalErrors.InvalidReason},
+		{name: "No idempotency key", modify: func(adj *models.BalanceAdjustment) { adj.IdempotencyKey = "" }, expectedErr: internalErrors.IdempotencyKeyRequired},
+		{name: "Long idempotency key", modify: func(adj *models.BalanceAdjustment) {
+			adj.IdempotencyKey = strings.Repeat("k", maxIdempotencyKeyLength+1)
+		}, expectedErr: internalErrors.InvalidIdempotencyKey},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeAdminStore{}
+			s := NewAdminService(fake)
+
+			adj := valid
+			tc.modify(&adj)
+
+			_, err := s.AdjustBalance(context.Background(), adj)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, fake.adjusted, "store must not be called")
+				return
+			}
+
+			assert.NoError(t, err)
+			if assert.NotNil(t, fake.adjusted) {
+				assert.Equal(t, strings.TrimSpace(adj.Reason), fake.adjusted.Reason)
+			}
+		})
+	}
+}
@@ -2,84 +2,397 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"go.uber.org/zap"
+	"regexp"
+	"strings"
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
 	"testAlvtoShp/internal/store"
 	"time"
 )
 
+const (
+	// RegistrationOpen creates unknown users on their first login
+	RegistrationOpen = "open"
+	// RegistrationExplicit requires POST /api/register
+	RegistrationExplicit = "explicit"
+	// RegistrationInvite requires POST /api/register with an invite code
+	RegistrationInvite = "invite"
+)
+
+const (
+	usernameMinLength = 3
+	usernameMaxLength = 32
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+	reservedUsernames = map[string]struct{}{
+		"admin": {}, "administrator": {}, "root": {}, "system": {}, "support": {},
+		"shop": {}, "treasury": {}, "unknown": {}, "null": {}, "api": {},
+	}
+)
+
 type AuthService struct {
-	store     store.Auth
-	jwtSecret string
+	store            store.Auth
+	sessions         store.Session
+	hasher           PasswordHasher
+	keys             *KeySet
+	accessTTL        time.Duration
+	refreshTTL       time.Duration
+	registrationMode string
+	inviteTTL        time.Duration
+	welcomeBonus     int
 }
 
-func NewAuthService(store store.Auth, jwtSecret string) *AuthService {
-	return &AuthService{store: store, jwtSecret: jwtSecret}
+func NewAuthService(store store.Auth, sessions store.Session, hasher PasswordHasher, keys *KeySet, cfg *config.Config) *AuthService {
+	return &AuthService{
+		store:      store,
+		sessions:   sessions,
+		hasher:     hasher,
+		keys:       keys,
+		accessTTL:  cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
+
+		registrationMode: cfg.RegistrationMode,
+		inviteTTL:        cfg.InviteTTL,
+		welcomeBonus:     cfg.WelcomeBonus,
+	}
 }
 
 func (s *AuthService) GetUserByUsername(ctx context.Context, username string) (int, error) {
 	return s.store.GetUserByUsername(ctx, username)
 }
 
-func (s *AuthService) GenerateToken(id int) (string, error) {
+// GenerateTokens starts a new session for the user and issues its first
+// access/refresh token pair.
+func (s *AuthService) GenerateTokens(ctx context.Context, id int) (models.AuthResponse, error) {
+	role, err := s.store.GetUserRole(ctx, id)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	sessionId, err := randomToken(16)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	if err = s.sessions.CreateSession(ctx, id, sessionId, hashToken(refreshToken), time.Now().Add(s.refreshTTL)); err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	return s.tokenResponse(id, role, sessionId, refreshToken)
+}
+
+// RefreshTokens exchanges a refresh token for a new pair. Every refresh token
+// can be used once, presenting it again revokes the whole session.
+func (s *AuthService) RefreshTokens(ctx context.Context, refreshToken string) (models.AuthResponse, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	stored, err := s.sessions.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AuthResponse{}, internalErrors.InvalidRefreshToken
+		}
+		return models.AuthResponse{}, err
+	}
+
+	if stored.SessionRevoked {
+		return models.AuthResponse{}, internalErrors.SessionRevoked
+	}
+
+	if stored.UsedAt.Valid {
+		return models.AuthResponse{}, s.revokeReusedSession(ctx, stored)
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return models.AuthResponse{}, internalErrors.InvalidRefreshToken
+	}
+
+	newRefreshToken, err := randomToken(32)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	rotated, err := s.sessions.RotateRefreshToken(ctx, stored.ID, stored.SessionID, hashToken(newRefreshToken), time.Now().Add(s.refreshTTL))
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	if !rotated {
+		return models.AuthResponse{}, s.revokeReusedSession(ctx, stored)
+	}
+
+	log.Infow("refresh token rotated", zap.Int("user_id", stored.UserID), zap.String("session_id", stored.SessionID))
+
+	return s.tokenResponse(stored.UserID, stored.Role, stored.SessionID, newRefreshToken)
+}
+
+func (s *AuthService) revokeReusedSession(ctx context.Context, stored models.RefreshToken) error {
+	log := logger.LoggerFromContext(ctx)
+	log.Warnw("refresh token reuse detected, revoking session",
+		zap.Int("user_id", stored.UserID), zap.String("session_id", stored.SessionID))
+
+	if err := s.sessions.RevokeSession(ctx, stored.SessionID); err != nil {
+		return err
+	}
+
+	return internalErrors.RefreshTokenReused
+}
+
+func (s *AuthService) Logout(ctx context.Context, sessionId string) error {
+	return s.sessions.RevokeSession(ctx, sessionId)
+}
+
+func (s *AuthService) LogoutAll(ctx context.Context, userId int) error {
+	return s.sessions.RevokeUserSessions(ctx, userId)
+}
+
+func (s *AuthService) tokenResponse(userId int, role, sessionId, refreshToken string) (models.AuthResponse, error) {
 	claims := jwt.MapClaims{
-		"userId": id,
-		"exp":    time.Now().Add(time.Hour * 24).Unix(),
+		"userId": userId,
+		"role":   role,
+		"sid":    sessionId,
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(s.accessTTL).Unix(),
+	}
+
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return models.AuthResponse{}, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return models.AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+	}, nil
 }
 
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is used for high-entropy random tokens only, so a plain digest is enough.
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateUser registers a user on the first login, which is only allowed in
+// the open registration mode.
 func (s *AuthService) CreateUser(ctx context.Context, req models.AuthRequest) (int, error) {
-	req.HashPass()
-	return s.store.CreateUser(ctx, req)
+	if s.registrationMode != RegistrationOpen {
+		return 0, internalErrors.UnknownUser
+	}
+
+	if err := ValidateUsername(req.Username); err != nil {
+		return 0, err
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return 0, err
+	}
+	req.Password = hash
+	return s.store.CreateUser(ctx, req, s.welcomeBonus)
 }
 
-func (s *AuthService) CheckPassword(ctx context.Context, req models.AuthRequest) (bool, error) {
-	req.HashPass()
-	return s.store.CheckPassword(ctx, req)
+func (s *AuthService) Register(ctx context.Context, req models.RegisterRequest) (int, error) {
+	log := logger.LoggerFromContext(ctx)
+	if err := ValidateUsername(req.Username); err != nil {
+		return 0, err
+	}
+
+	if s.registrationMode == RegistrationInvite && req.InviteCode == "" {
+		return 0, internalErrors.InvalidInvite
+	}
+
+	existing, err := s.store.GetUserByUsername(ctx, req.Username)
+	if err != nil {
+		return 0, err
+	}
+	if existing != 0 {
+		return 0, internalErrors.UserExists
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return 0, err
+	}
+	authReq := models.AuthRequest{Username: req.Username, Password: hash}
+
+	var id int
+	if s.registrationMode == RegistrationInvite {
+		id, err = s.store.CreateUserWithInvite(ctx, authReq, hashToken(req.InviteCode), s.welcomeBonus)
+	} else {
+		id, err = s.store.CreateUser(ctx, authReq, s.welcomeBonus)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	log.Infow("user registered", zap.Int("user_id", id), zap.String("mode", s.registrationMode))
+	return id, nil
+}
+
+func (s *AuthService) CreateInvite(ctx context.Context, userId int) (models.InviteResponse, error) {
+	code, err := randomToken(16)
+	if err != nil {
+		return models.InviteResponse{}, err
+	}
+
+	expiresAt := time.Now().Add(s.inviteTTL)
+	if err = s.store.CreateInvite(ctx, hashToken(code), userId, expiresAt); err != nil {
+		return models.InviteResponse{}, err
+	}
+
+	return models.InviteResponse{Code: code, ExpiresAt: expiresAt}, nil
+}
+
+// ValidateUsername enforces the rules for new accounts, existing usernames
+// are not checked against them.
+func ValidateUsername(username string) error {
+	if err := validateUsernameFormat(username); err != nil {
+		return err
+	}
+
+	if _, ok := reservedUsernames[strings.ToLower(username)]; ok {
+		return fmt.Errorf("%w: %q is reserved", internalErrors.InvalidUsername, username)
+	}
+
+	return nil
 }
 
-func (s *AuthService) ExtractUserIDFromAccessToken(ctx context.Context, accessToken string) (int, error) {
+// validateUsernameFormat checks the length and the characters of a username
+// but allows the reserved ones.
+func validateUsernameFormat(username string) error {
+	if len(username) < usernameMinLength || len(username) > usernameMaxLength {
+		return fmt.Errorf("%w: length must be between %d and %d", internalErrors.InvalidUsername, usernameMinLength, usernameMaxLength)
+	}
+
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("%w: only latin letters, digits, '.', '_' and '-' are allowed", internalErrors.InvalidUsername)
+	}
+
+	return nil
+}
+
+// dummyPasswordHash is verified for unknown usernames, so that they take as
+// long to reject as a wrong password and do not reveal which users exist.
+const dummyPasswordHash = "$argon2id$v=19$m=65536,t=3,p=4$B4TDVdCkHmmLMwiB8+rYwA$sauw39T7RF989JZQ/j0+tBNL5zIW4v+1sZk2tHO+lLY"
+
+// CheckPassword verifies the password and, on success, transparently upgrades
+// hashes made by an outdated algorithm (e.g. legacy unsalted sha256).
+func (s *AuthService) CheckPassword(ctx context.Context, req models.AuthRequest) (bool, error) {
 	log := logger.LoggerFromContext(ctx)
-	token, err := jwt.Parse(accessToken, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
+	userId, hash, err := s.store.GetPasswordHash(ctx, req.Username)
+	if err != nil {
+		return false, err
+	}
+
+	if userId == 0 {
+		_, _ = VerifyPassword(req.Password, dummyPasswordHash)
+		return false, nil
+	}
+
+	ok, err := VerifyPassword(req.Password, hash)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if s.hasher.NeedsRehash(hash) {
+		newHash, err := s.hasher.Hash(req.Password)
+		if err != nil {
+			log.Errorw("failed to rehash password", zap.Int("user_id", userId), zap.Error(err))
+			return true, nil
+		}
+
+		if err = s.store.UpdatePasswordHash(ctx, userId, newHash); err != nil {
+			log.Errorw("failed to upgrade password hash", zap.Int("user_id", userId), zap.Error(err))
+			return true, nil
 		}
-		return []byte(s.jwtSecret), nil
-	})
+		log.Infow("password hash upgraded", zap.Int("user_id", userId))
+	}
+
+	return true, nil
+}
+
+func (s *AuthService) ParseAccessToken(ctx context.Context, accessToken string) (models.TokenClaims, error) {
+	log := logger.LoggerFromContext(ctx)
+	token, err := jwt.Parse(accessToken, s.keys.Keyfunc)
 
 	if err != nil || !token.Valid {
 		log.Errorw("invalid access token", zap.Error(err))
-		return 0, err
+		return models.TokenClaims{}, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
 		log.Errorw("invalid access token", zap.Error(err))
-		return 0, jwt.ErrInvalidKey
+		return models.TokenClaims{}, jwt.ErrInvalidKey
 	}
 
 	userIDFloat, ok := claims["userId"].(float64)
 	if !ok {
 		log.Errorw("invalid access token, userId not found in token", zap.Error(err))
-		return 0, errors.New("userId not found in token")
+		return models.TokenClaims{}, errors.New("userId not found in token")
 	}
 
-	ok, err = s.store.CheckUserId(ctx, int(userIDFloat))
+	sessionId, ok := claims["sid"].(string)
+	if !ok {
+		log.Errorw("invalid access token, sid not found in token", zap.Error(err))
+		return models.TokenClaims{}, errors.New("sid not found in token")
+	}
+
+	role, ok := claims["role"].(string)
+	if !ok {
+		log.Errorw("invalid access token, role not found in token", zap.Error(err))
+		return models.TokenClaims{}, errors.New("role not found in token")
+	}
+
+	// The role is checked against the database on every request, so that a
+	// demoted user does not keep the old powers until the token expires.
+	currentRole, err := s.sessions.GetSessionRole(ctx, int(userIDFloat), sessionId)
 
 	if err != nil {
-		log.Errorw("userId not found", zap.Error(err))
-		return 0, err
+		log.Errorw("session not found", zap.Error(err))
+		return models.TokenClaims{}, err
 	}
 
-	if !ok {
-		return 0, errors.New("userId not found in token")
+	if currentRole == "" {
+		return models.TokenClaims{}, internalErrors.SessionRevoked
 	}
 
-	return int(userIDFloat), nil
+	if currentRole != role {
+		log.Infow("access token role is outdated", zap.Int("user_id", int(userIDFloat)),
+			zap.String("token_role", role), zap.String("role", currentRole))
+		return models.TokenClaims{}, internalErrors.RoleChanged
+	}
+
+	return models.TokenClaims{UserID: int(userIDFloat), SessionID: sessionId, Role: role}, nil
+}
+
+func (s *AuthService) JWKS() models.JWKS {
+	return s.keys.JWKS()
 }
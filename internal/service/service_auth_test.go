@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		valid    bool
+	}{
+		{name: "Plain", username: "ivan.petrov", valid: true},
+		{name: "Digits and dashes", username: "team-42_lead", valid: true},
+		{name: "Too short", username: "ab", valid: false},
+		{name: "Too long", username: "abcdefghijklmnopqrstuvwxyz1234567", valid: false},
+		{name: "Spaces", username: "ivan petrov", valid: false},
+		{name: "Cyrillic", username: "иван", valid: false},
+		{name: "Reserved", username: "Admin", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateUsername(tc.username)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, internalErrors.InvalidUsername)
+			}
+		})
+	}
+}
+
+type fakeRegisterStore struct {
+	store.Auth
+	existing   int
+	created    models.AuthRequest
+	inviteHash string
+	bonus      int
+}
+
+func (f *fakeRegisterStore) GetUserByUsername(_ context.Context, _ string) (int, error) {
+	return f.existing, nil
+}
+
+func (f *fakeRegisterStore) CreateUser(_ context.Context, req models.AuthRequest, welcomeBonus int) (int, error) {
+	f.created = req
+	f.bonus = welcomeBonus
+	return 42, nil
+}
+
+func (f *fakeRegisterStore) CreateUserWithInvite(_ context.Context, req models.AuthRequest, codeHash string, welcomeBonus int) (int, error) {
+	f.created = req
+	f.bonus = welcomeBonus
+	f.inviteHash = codeHash
+	return 42, nil
+}
+
+func TestAuthService_RegistrationModes(t *testing.T) {
+	tests := []struct {
+		name           string
+		mode           string
+		existing       int
+		register       *models.RegisterRequest
+		expectedErr    error
+		expectedInvite bool
+	}{
+		{name: "Auto registration in open mode", mode: RegistrationOpen},
+		{name: "Auto registration in explicit mode", mode: RegistrationExplicit, expectedErr: internalErrors.UnknownUser},
+		{name: "Auto registration in invite mode", mode: RegistrationInvite, expectedErr: internalErrors.UnknownUser},
+		{
+			name:     "Explicit registration",
+			mode:     RegistrationExplicit,
+			register: &models.RegisterRequest{Username: "newuser", Password: "pass"},
+		},
+		{
+			name:        "Explicit registration of existing user",
+			mode:        RegistrationExplicit,
+			existing:    7,
+			register:    &models.RegisterRequest{Username: "newuser", Password: "pass"},
+			expectedErr: internalErrors.UserExists,
+		},
+		{
+			name:        "Invite mode without code",
+			mode:        RegistrationInvite,
+			register:    &models.RegisterRequest{Username: "newuser", Password: "pass"},
+			expectedErr: internalErrors.InvalidInvite,
+		},
+		{
+			name:           "Invite mode with code",
+			mode:           RegistrationInvite,
+			register:       &models.RegisterRequest{Username: "newuser", Password: "pass", InviteCode: "code"},
+			expectedInvite: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeRegisterStore{existing: tc.existing}
+			s := NewAuthService(fake, nil, NewBcryptHasher(bcrypt.MinCost), nil, &config.Config{RegistrationMode: tc.mode, WelcomeBonus: 1000})
+
+			var (
+				id  int
+				err error
+			)
+			if tc.register != nil {
+				id, err = s.Register(context.Background(), *tc.register)
+			} else {
+				id, err = s.CreateUser(context.Background(), models.AuthRequest{Username: "newuser", Password: "pass"})
+			}
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, fake.created.Username)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, 42, id)
+			assert.Equal(t, "newuser", fake.created.Username)
+			assert.NotEqual(t, "pass", fake.created.Password, "password must be hashed")
+			assert.Equal(t, 1000, fake.bonus)
+			if tc.expectedInvite {
+				assert.Equal(t, hashToken("code"), fake.inviteHash)
+			}
+		})
+	}
+}
+
+type fakeSessionStore struct {
+	store.Session
+	role string
+}
+
+func (f *fakeSessionStore) GetSessionRole(_ context.Context, _ int, _ string) (string, error) {
+	return f.role, nil
+}
+
+func TestAuthService_ParseAccessToken_Role(t *testing.T) {
+	keys, err := NewKeySet(DefaultKeyID, NewHMACKey(DefaultKeyID, []byte("secret")))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		tokenRole   string
+		currentRole string
+		expectedErr error
+	}{
+		{name: "Role unchanged", tokenRole: RoleAdmin, currentRole: RoleAdmin},
+		{name: "Demoted user", tokenRole: RoleAdmin, currentRole: RoleUser, expectedErr: internalErrors.RoleChanged},
+		{name: "Revoked session", tokenRole: RoleUser, currentRole: "", expectedErr: internalErrors.SessionRevoked},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewAuthService(nil, &fakeSessionStore{role: tc.currentRole}, nil, keys, &config.Config{AccessTokenTTL: time.Minute})
+
+			tokens, err := s.tokenResponse(42, tc.tokenRole, "session", "refresh")
+			require.NoError(t, err)
+
+			claims, err := s.ParseAccessToken(context.Background(), tokens.Token)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, models.TokenClaims{UserID: 42, SessionID: "session", Role: tc.tokenRole}, claims)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testAlvtoShp/internal/config"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+// AdminBootstrap makes the first admins. Through the API only an admin can
+// hand out the admin role, so the first one is made by the operator.
+type AdminBootstrap struct {
+	auth         store.Auth
+	admin        store.Admin
+	hasher       PasswordHasher
+	welcomeBonus int
+}
+
+func NewAdminBootstrap(auth store.Auth, admin store.Admin, hasher PasswordHasher, cfg *config.Config) *AdminBootstrap {
+	return &AdminBootstrap{
+		auth:         auth,
+		admin:        admin,
+		hasher:       hasher,
+		welcomeBonus: cfg.WelcomeBonus,
+	}
+}
+
+// EnsureAdmin gives username the admin role and reports whether the user
+// had to be created. A missing user is created with password, the reserved
+// usernames such as "admin" are allowed because the operator chose them.
+// The password of an existing user is left as it is.
+func (b *AdminBootstrap) EnsureAdmin(ctx context.Context, username, password string) (int, bool, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	userId, err := b.auth.GetUserByUsername(ctx, username)
+	if err != nil {
+		return 0, false, err
+	}
+
+	created := userId == 0
+	if created {
+		if err = validateUsernameFormat(username); err != nil {
+			return 0, false, err
+		}
+		if password == "" {
+			return 0, false, fmt.Errorf("a password is required to create the admin %q", username)
+		}
+
+		hash, err := b.hasher.Hash(password)
+		if err != nil {
+			return 0, false, err
+		}
+		if userId, err = b.auth.CreateUser(ctx, models.AuthRequest{Username: username, Password: hash}, b.welcomeBonus); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if err = b.admin.SetUserRole(ctx, userId, RoleAdmin); err != nil {
+		return 0, false, err
+	}
+
+	log.Infow("admin bootstrapped", "user_id", userId, "username", username, "created", created)
+
+	return userId, created, nil
+}
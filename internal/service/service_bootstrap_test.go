@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+type fakeBootstrapAuthStore struct {
+	store.Auth
+	userId  int
+	created []models.AuthRequest
+}
+
+func (f *fakeBootstrapAuthStore) GetUserByUsername(_ context.Context, _ string) (int, error) {
+	return f.userId, nil
+}
+
+func (f *fakeBootstrapAuthStore) CreateUser(_ context.Context, req models.AuthRequest, _ int) (int, error) {
+	f.created = append(f.created, req)
+	return 9, nil
+}
+
+type fakeRoleStore struct {
+	store.Admin
+	roles map[int]string
+}
+
+func (f *fakeRoleStore) SetUserRole(_ context.Context, userId int, role string) error {
+	f.roles[userId] = role
+	return nil
+}
+
+func TestAdminBootstrap_EnsureAdmin(t *testing.T) {
+	tests := []struct {
+		name            string
+		existingId      int
+		username        string
+		password        string
+		expectedId      int
+		expectedCreated bool
+		expectedErr     error
+	}{
+		{name: "Reserved name is created", username: "admin", password: "secret123", expectedId: 9, expectedCreated: true},
+		{name: "Existing user is promoted", existingId: 4, username: "alice", expectedId: 4},
+		{name: "Missing user without a password", username: "admin", expectedErr: assert.AnError},
+		{name: "Malformed username", username: "a b", password: "secret123", expectedErr: internalErrors.InvalidUsername},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			auth := &fakeBootstrapAuthStore{userId: tc.existingId}
+			roles := &fakeRoleStore{roles: map[int]string{}}
+			b := NewAdminBootstrap(auth, roles, NewBcryptHasher(bcrypt.MinCost), &config.Config{})
+
+			userId, created, err := b.EnsureAdmin(context.Background(), tc.username, tc.password)
+			if tc.expectedErr != nil {
+				assert.Error(t, err)
+				if tc.expectedErr != assert.AnError {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				}
+				assert.Empty(t, roles.roles)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedId, userId)
+			assert.Equal(t, tc.expectedCreated, created)
+			assert.Equal(t, map[int]string{tc.expectedId: RoleAdmin}, roles.roles)
+			if created {
+				valid, err := VerifyPassword(tc.password, auth.created[0].Password)
+				assert.NoError(t, err)
+				assert.True(t, valid)
+			} else {
+				assert.Empty(t, auth.created)
+			}
+		})
+	}
+}
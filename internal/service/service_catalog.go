@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+// Item names end up in URLs such as /api/buy/{item}, so they are kept to
+// lowercase words joined by dashes.
+var itemNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+const (
+	maxItemNameLength        = 64
+	maxItemDescriptionLength = 1000
+)
+
+type CatalogService struct {
+	store store.Catalog
+}
+
+func NewCatalogService(store store.Catalog) *CatalogService {
+	return &CatalogService{store}
+}
+
+func (s *CatalogService) GetItems(ctx context.Context) (models.CatalogResponse, error) {
+	items, err := s.store.GetItems(ctx)
+	if err != nil {
+		return models.CatalogResponse{}, err
+	}
+	return models.CatalogResponse{Items: items}, nil
+}
+
+func (s *CatalogService) CreateItem(ctx context.Context, actorId int, req models.CreateItemRequest) (models.CatalogItem, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	item := models.CatalogItem{
+		Name:         strings.TrimSpace(req.Name),
+		Price:        req.Price,
+		Description:  strings.TrimSpace(req.Description),
+		ImageURL:     strings.TrimSpace(req.ImageURL),
+		Available:    req.Stock == nil || *req.Stock > 0,
+		Stock:        req.Stock,
+		PerUserLimit: req.PerUserLimit,
+	}
+
+	if len(item.Name) > maxItemNameLength || !itemNamePattern.MatchString(item.Name) {
+		return models.CatalogItem{}, fmt.Errorf("%w: name must be lowercase letters, digits and dashes, at most %d characters",
+			internalErrors.InvalidItem, maxItemNameLength)
+	}
+	if item.Price <= 0 {
+		return models.CatalogItem{}, internalErrors.InvalidPrice
+	}
+	if len(item.Description) > maxItemDescriptionLength {
+		return models.CatalogItem{}, fmt.Errorf("%w: description is longer than %d characters",
+			internalErrors.InvalidItem, maxItemDescriptionLength)
+	}
+	if err := validateLimits(item.Stock, item.PerUserLimit); err != nil {
+		return models.CatalogItem{}, err
+	}
+	if item.ImageURL != "" {
+		if u, err := url.Parse(item.ImageURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return models.CatalogItem{}, fmt.Errorf("%w: image url must be an absolute http(s) url", internalErrors.InvalidItem)
+		}
+	}
+
+	if err := s.store.CreateItem(ctx, item, actorId); err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	log.Infow("item created", "actor_id", actorId, "item", item.Name, "price", item.Price)
+
+	return item, nil
+}
+
+func (s *CatalogService) SetItemPrice(ctx context.Context, actorId int, name string, price int) (models.CatalogItem, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	if price <= 0 {
+		return models.CatalogItem{}, internalErrors.InvalidPrice
+	}
+
+	item, err := s.store.SetItemPrice(ctx, name, price, actorId)
+	if err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	log.Infow("item repriced", "actor_id", actorId, "item", name, "price", price)
+
+	return item, nil
+}
+
+func (s *CatalogService) SetItemLimits(ctx context.Context, actorId int, name string, req models.ItemLimitsRequest) (models.CatalogItem, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	if err := validateLimits(req.Stock, req.PerUserLimit); err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	item, err := s.store.SetItemLimits(ctx, name, req.Stock, req.PerUserLimit)
+	if err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	log.Infow("item limits changed", "actor_id", actorId, "item", name, "stock", req.Stock, "per_user_limit", req.PerUserLimit)
+
+	return item, nil
+}
+
+func validateLimits(stock, perUserLimit *int) error {
+	if stock != nil && *stock < 0 {
+		return fmt.Errorf("%w: stock must not be negative", internalErrors.InvalidItem)
+	}
+	if perUserLimit != nil && *perUserLimit <= 0 {
+		return fmt.Errorf("%w: per user limit must be positive", internalErrors.InvalidItem)
+	}
+	return nil
+}
+
+func (s *CatalogService) RetireItem(ctx context.Context, actorId int, name string) error {
+	log := logger.LoggerFromContext(ctx)
+
+	if err := s.store.RetireItem(ctx, name); err != nil {
+		return err
+	}
+
+	log.Infow("item retired", "actor_id", actorId, "item", name)
+
+	return nil
+}
+
+func (s *CatalogService) GetPriceHistory(ctx context.Context, name string) ([]models.ItemPrice, error) {
+	return s.store.GetPriceHistory(ctx, name)
+}
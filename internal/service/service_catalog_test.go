@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+type fakeCatalogStore struct {
+	store.Catalog
+	created *models.CatalogItem
+}
+
+func (f *fakeCatalogStore) CreateItem(_ context.Context, item models.CatalogItem, _ int) error {
+	f.created = &item
+	return nil
+}
+
+func TestCatalogService_CreateItem(t *testing.T) {
+	valid := models.CreateItemRequest{Name: "sticker-pack", Price: 15, Description: " Стикеры ", ImageURL: "https://example.com/s.png"}
+
+	tests := []struct {
+		name        string
+		modify      func(req *models.CreateItemRequest)
+		expectedErr error
+	}{
+		{name: "Valid item", modify: func(req *models.CreateItemRequest) {}},
+		{name: "No image", modify: func(req *models.CreateItemRequest) { req.ImageURL = "" }},
+		{name: "Uppercase name", modify: func(req *models.CreateItemRequest) { req.Name = "Sticker" }, expectedErr: internalErrors.InvalidItem},
+		{name: "Name with slash", modify: func(req *models.CreateItemRequest) { req.Name = "a/b" }, expectedErr: internalErrors.InvalidItem},
+		{name: "Trailing dash", modify: func(req *models.CreateItemRequest) { req.Name = "sticker-" }, expectedErr: internalErrors.InvalidItem},
+		{name: "Long name", modify: func(req *models.CreateItemRequest) {
+			req.Name = strings.Repeat("a", maxItemNameLength+1)
+		}, expectedErr: internalErrors.InvalidItem},
+		{name: "Free item", modify: func(req *models.CreateItemRequest) { req.Price = 0 }, expectedErr: internalErrors.InvalidPrice},
+		{name: "Relative image", modify: func(req *models.CreateItemRequest) { req.ImageURL = "/s.png" }, expectedErr: internalErrors.InvalidItem},
+		{name: "Negative stock", modify: func(req *models.CreateItemRequest) {
+			stock := -1
+			req.Stock = &stock
+		}, expectedErr: internalErrors.InvalidItem},
+		{name: "Zero per user limit", modify: func(req *models.CreateItemRequest) {
+			limit := 0
+			req.PerUserLimit = &limit
+		}, expectedErr: internalErrors.InvalidItem},
+		{name: "Script image", modify: func(req *models.CreateItemRequest) { req.ImageURL = "javascript:alert(1)" }, expectedErr: internalErrors.InvalidItem},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := valid
+			tc.modify(&req)
+
+			fake := &fakeCatalogStore{}
+			item, err := NewCatalogService(fake).CreateItem(context.Background(), 1, req)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, fake.created)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, item, *fake.created)
+			assert.Equal(t, "Стикеры", item.Description)
+			assert.True(t, item.Available)
+		})
+	}
+}
+
+func TestCatalogService_SetItemPriceRejectsNonPositive(t *testing.T) {
+	_, err := NewCatalogService(&fakeCatalogStore{}).SetItemPrice(context.Background(), 1, "cup", -5)
+	assert.ErrorIs(t, err, internalErrors.InvalidPrice)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+	"time"
+)
+
+type CoinRequestService struct {
+	store store.CoinRequests
+	ttl   time.Duration
+
+	now func() time.Time
+}
+
+func NewCoinRequestService(store store.CoinRequests, cfg *config.Config) *CoinRequestService {
+	return &CoinRequestService{
+		store: store,
+		ttl:   cfg.CoinRequestTTL,
+		now:   time.Now,
+	}
+}
+
+// RequestCoins asks req.FromUser to pay the user, the request can be
+// accepted until the coin request ttl passes. The memo is cleaned up as the
+// memo of a transfer, which it becomes on accept.
+func (s *CoinRequestService) RequestCoins(ctx context.Context, userId int, req models.CreateCoinRequest) (models.CoinRequest, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	if req.FromUser == "" {
+		return models.CoinRequest{}, fmt.Errorf("%w: fromUser is required", internalErrors.InvalidCoinRequest)
+	}
+	if req.Amount < 1 {
+		return models.CoinRequest{}, fmt.Errorf("%w: amount must be greater than zero", internalErrors.InvalidCoinRequest)
+	}
+	memo, err := sanitizeMemo(req.Memo)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	request, err := s.store.CreateCoinRequest(ctx, userId, req.FromUser, req.Amount, memo, s.now().Add(s.ttl))
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	log.Infow("coin request created", "user_id", userId, "from_user", req.FromUser, "amount", req.Amount, "request_id", request.ID)
+
+	return request, nil
+}
+
+func (s *CoinRequestService) AcceptCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	request, err := s.store.AcceptCoinRequest(ctx, userId, requestId)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	log.Infow("coin request accepted", "user_id", userId, "request_id", requestId, "amount", request.Amount)
+
+	return request, nil
+}
+
+func (s *CoinRequestService) DeclineCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	request, err := s.store.DeclineCoinRequest(ctx, userId, requestId)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	log.Infow("coin request declined", "user_id", userId, "request_id", requestId)
+
+	return request, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+// fakeCoinRequestStore records the requests that reach the store.
+type fakeCoinRequestStore struct {
+	store.CoinRequests
+	memos     []string
+	expiresAt []time.Time
+}
+
+func (f *fakeCoinRequestStore) CreateCoinRequest(_ context.Context, _ int, payer string, amount int, memo string, expiresAt time.Time) (models.CoinRequest, error) {
+	f.memos = append(f.memos, memo)
+	f.expiresAt = append(f.expiresAt, expiresAt)
+	return models.CoinRequest{ID: 1, Payer: payer, Amount: amount, Memo: memo, Status: models.CoinRequestPending, ExpiresAt: expiresAt}, nil
+}
+
+func TestCoinRequestService_RequestCoins(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fake := &fakeCoinRequestStore{}
+	s := NewCoinRequestService(fake, &config.Config{CoinRequestTTL: 72 * time.Hour})
+	s.now = func() time.Time { return now }
+
+	req, err := s.RequestCoins(context.Background(), 7, models.CreateCoinRequest{FromUser: "alice", Amount: 40, Memo: " pizza\n"})
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(72*time.Hour), req.ExpiresAt)
+	assert.Equal(t, []string{"pizza"}, fake.memos)
+
+	_, err = s.RequestCoins(context.Background(), 7, models.CreateCoinRequest{Amount: 40})
+	assert.ErrorIs(t, err, internalErrors.InvalidCoinRequest)
+	_, err = s.RequestCoins(context.Background(), 7, models.CreateCoinRequest{FromUser: "alice", Amount: 0})
+	assert.ErrorIs(t, err, internalErrors.InvalidCoinRequest)
+	_, err = s.RequestCoins(context.Background(), 7, models.CreateCoinRequest{FromUser: "alice", Amount: 1, Memo: "\xff"})
+	assert.ErrorIs(t, err, internalErrors.InvalidMemo)
+	assert.Len(t, fake.memos, 1)
+}
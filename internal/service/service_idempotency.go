@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 128
+
+// idempotencyLockTimeout is how long a request may hold its key before a
+// retry is allowed to take it over. A request that crashed after its
+// transaction committed but before its response was stored is run again
+// once this passes.
+const idempotencyLockTimeout = time.Minute
+
+// idempotencySweepInterval limits how often expired keys are deleted.
+const idempotencySweepInterval = time.Hour
+
+type IdempotencyService struct {
+	store store.Idempotency
+	ttl   time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+func NewIdempotencyService(store store.Idempotency, cfg *config.Config) *IdempotencyService {
+	return &IdempotencyService{
+		store: store,
+		ttl:   cfg.IdempotencyKeyTTL,
+		now:   time.Now,
+	}
+}
+
+// BeginIdempotent reserves key for a request with the given fingerprint.
+// It returns the stored outcome when the request has already been made, the
+// caller then replays it instead of running the request.
+func (s *IdempotencyService) BeginIdempotent(ctx context.Context, userId int, key, fingerprint string) (*models.IdempotencyRecord, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	if err := validateIdempotencyKey(key); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	s.sweep(ctx, now)
+
+	stored, reserved, err := s.store.ReserveIdempotencyKey(ctx, models.IdempotencyRecord{
+		UserID:      userId,
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}, now.Add(-idempotencyLockTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	if stored.Fingerprint != fingerprint {
+		log.Warnw("idempotency key reused", "user_id", userId, "idempotency_key", key)
+		return nil, internalErrors.IdempotencyKeyReused
+	}
+	if !stored.StatusCode.Valid {
+		return nil, internalErrors.IdempotencyKeyInProgress
+	}
+
+	log.Infow("replaying request", "user_id", userId, "idempotency_key", key, "status", stored.StatusCode.Int32)
+	return &stored, nil
+}
+
+func (s *IdempotencyService) CompleteIdempotent(ctx context.Context, userId int, key string, statusCode int, response []byte) error {
+	return s.store.CompleteIdempotencyKey(ctx, userId, key, statusCode, response)
+}
+
+func (s *IdempotencyService) AbortIdempotent(ctx context.Context, userId int, key string) error {
+	return s.store.ReleaseIdempotencyKey(ctx, userId, key)
+}
+
+func validateIdempotencyKey(key string) error {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: length must be between 1 and %d", internalErrors.InvalidIdempotencyKey, maxIdempotencyKeyLength)
+	}
+	for _, r := range key {
+		if r < '!' || r > '~' {
+			return fmt.Errorf("%w: only printable ASCII is allowed", internalErrors.InvalidIdempotencyKey)
+		}
+	}
+	return nil
+}
+
+// sweep deletes expired keys at most once per idempotencySweepInterval. A
+// failed sweep is only logged, expired keys are also taken over on reuse.
+func (s *IdempotencyService) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < idempotencySweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	log := logger.LoggerFromContext(ctx)
+	deleted, err := s.store.DeleteExpiredIdempotencyKeys(ctx, now)
+	if err != nil {
+		log.Warnw("failed to sweep idempotency keys", zap.Error(err))
+		return
+	}
+	if deleted > 0 {
+		log.Infow("swept idempotency keys", "deleted", deleted)
+	}
+}
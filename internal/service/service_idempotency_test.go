@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+)
+
+// fakeIdempotencyStore keeps records in a map keyed by user and key.
+type fakeIdempotencyStore struct {
+	records map[string]models.IdempotencyRecord
+	sweeps  int
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{records: make(map[string]models.IdempotencyRecord)}
+}
+
+func recordKey(userId int, key string) string {
+	return fmt.Sprintf("%d:%s", userId, key)
+}
+
+func (f *fakeIdempotencyStore) ReserveIdempotencyKey(_ context.Context, rec models.IdempotencyRecord, staleBefore time.Time) (models.IdempotencyRecord, bool, error) {
+	stored, ok := f.records[recordKey(rec.UserID, rec.Key)]
+	if ok && stored.ExpiresAt.After(rec.CreatedAt) && (stored.StatusCode.Valid || !stored.CreatedAt.Before(staleBefore)) {
+		return stored, false, nil
+	}
+	f.records[recordKey(rec.UserID, rec.Key)] = rec
+	return rec, true, nil
+}
+
+func (f *fakeIdempotencyStore) CompleteIdempotencyKey(_ context.Context, userId int, key string, statusCode int, response []byte) error {
+	rec := f.records[recordKey(userId, key)]
+	rec.StatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	rec.Response = response
+	f.records[recordKey(userId, key)] = rec
+	return nil
+}
+
+func (f *fakeIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, userId int, key string) error {
+	delete(f.records, recordKey(userId, key))
+	return nil
+}
+
+func (f *fakeIdempotencyStore) DeleteExpiredIdempotencyKeys(_ context.Context, _ time.Time) (int64, error) {
+	f.sweeps++
+	return 0, nil
+}
+
+func TestIdempotencyService_BeginIdempotent(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	fake := newFakeIdempotencyStore()
+	s := NewIdempotencyService(fake, &config.Config{IdempotencyKeyTTL: time.Hour})
+	s.now = func() time.Time { return now }
+
+	stored, err := s.BeginIdempotent(ctx, 1, "k1", "fp")
+	assert.NoError(t, err)
+	assert.Nil(t, stored, "a new key runs the request")
+
+	_, err = s.BeginIdempotent(ctx, 1, "k1", "fp")
+	assert.ErrorIs(t, err, internalErrors.IdempotencyKeyInProgress)
+
+	assert.NoError(t, s.CompleteIdempotent(ctx, 1, "k1", 201, []byte(`{"id":7}`)))
+	stored, err = s.BeginIdempotent(ctx, 1, "k1", "fp")
+	assert.NoError(t, err)
+	if assert.NotNil(t, stored) {
+		assert.Equal(t, int32(201), stored.StatusCode.Int32)
+		assert.Equal(t, []byte(`{"id":7}`), stored.Response)
+	}
+
+	_, err = s.BeginIdempotent(ctx, 1, "k1", "another")
+	assert.ErrorIs(t, err, internalErrors.IdempotencyKeyReused)
+
+	stored, err = s.BeginIdempotent(ctx, 2, "k1", "another")
+	assert.NoError(t, err)
+	assert.Nil(t, stored, "keys are scoped per user")
+
+	assert.NoError(t, s.AbortIdempotent(ctx, 2, "k1"))
+	stored, err = s.BeginIdempotent(ctx, 2, "k1", "another")
+	assert.NoError(t, err)
+	assert.Nil(t, stored, "an aborted request may be retried")
+
+	now = now.Add(2 * time.Hour)
+	stored, err = s.BeginIdempotent(ctx, 1, "k1", "another")
+	assert.NoError(t, err)
+	assert.Nil(t, stored, "an expired key may be reused")
+	assert.Equal(t, 2, fake.sweeps)
+}
+
+func TestIdempotencyService_InvalidKey(t *testing.T) {
+	fake := newFakeIdempotencyStore()
+	s := NewIdempotencyService(fake, &config.Config{IdempotencyKeyTTL: time.Hour})
+
+	for _, key := range []string{strings.Repeat("k", maxIdempotencyKeyLength+1), "with space", "ключ"} {
+		_, err := s.BeginIdempotent(context.Background(), 1, key, "fp")
+		assert.ErrorIs(t, err, internalErrors.InvalidIdempotencyKey, key)
+	}
+	assert.Empty(t, fake.records)
+}
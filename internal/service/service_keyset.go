@@ -0,0 +1,248 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"testAlvtoShp/internal/config"
+	"testAlvtoShp/internal/models"
+)
+
+// DefaultKeyID identifies the HMAC key derived from JWT_SECRET. Tokens
+// without a kid header are verified with it.
+const DefaultKeyID = "default"
+
+// SigningMethodEdDSA implements EdDSA over Ed25519 (RFC 8037), which
+// jwt-go v3 does not ship.
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+type signingMethodEdDSA struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// Key is a single JWT key. Keys loaded from a public PEM can only verify.
+type Key struct {
+	ID         string
+	Method     jwt.SigningMethod
+	signingKey interface{}
+	verifyKey  interface{}
+}
+
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, signingKey: secret, verifyKey: secret}
+}
+
+// ParsePEMKey reads a PKCS#8/PKCS#1 private key or a PKIX public key. The
+// algorithm is derived from the key type: RS256 for RSA, EdDSA for Ed25519.
+func ParsePEMKey(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM block found", id)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %q: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", id, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, signingKey: k, verifyKey: &k.PublicKey}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, verifyKey: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, Method: SigningMethodEdDSA, signingKey: k, verifyKey: k.Public()}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: id, Method: SigningMethodEdDSA, verifyKey: k}, nil
+	}
+
+	return nil, fmt.Errorf("key %q: unsupported key type %T", id, parsed)
+}
+
+// KeySet holds every key that is accepted for verification and the one key
+// new tokens are signed with.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+func NewKeySet(signingKid string, keys ...*Key) (*KeySet, error) {
+	set := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, key := range keys {
+		if _, ok := set.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+
+	signing, ok := set.keys[signingKid]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", signingKid)
+	}
+	if signing.signingKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private part", signingKid)
+	}
+	set.signing = signing
+
+	return set, nil
+}
+
+// LoadKeySet builds the key set from config. JWT_KEYS is a comma separated
+// list of kid=source entries, where source is a path to a PEM file or
+// env:VAR_NAME to read the PEM from an environment variable.
+func LoadKeySet(cfg *config.Config) (*KeySet, error) {
+	var keys []*Key
+	if cfg.JwtSecret != "" {
+		keys = append(keys, NewHMACKey(DefaultKeyID, []byte(cfg.JwtSecret)))
+	}
+
+	for _, entry := range strings.Split(cfg.JwtKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, source, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || source == "" {
+			return nil, fmt.Errorf("invalid JWT_KEYS entry %q", entry)
+		}
+
+		data, err := readKeySource(source)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+
+		key, err := ParsePEMKey(kid, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	signingKid := cfg.JwtSigningKid
+	if signingKid == "" {
+		signingKid = DefaultKeyID
+	}
+
+	return NewKeySet(signingKid, keys...)
+}
+
+func readKeySource(source string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(source, "env:"); ok {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", name)
+		}
+		return []byte(value), nil
+	}
+	return os.ReadFile(source)
+}
+
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.Method, claims)
+	token.Header["kid"] = k.signing.ID
+	return token.SignedString(k.signing.signingKey)
+}
+
+// Keyfunc resolves the verification key by the kid header and refuses
+// tokens whose alg does not match the key, so a public key can never be
+// used as an HMAC secret.
+func (k *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+	kid := DefaultKeyID
+	if v, ok := t.Header["kid"]; ok {
+		if kid, ok = v.(string); !ok {
+			return nil, errors.New("invalid kid header")
+		}
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	return key.verifyKey, nil
+}
+
+// JWKS publishes the asymmetric verification keys, HMAC secrets are never exposed.
+func (k *KeySet) JWKS() models.JWKS {
+	jwks := models.JWKS{Keys: []models.JWK{}}
+	for _, key := range k.keys {
+		jwk := models.JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool {
+		return jwks.Keys[i].Kid < jwks.Keys[j].Kid
+	})
+
+	return jwks
+}
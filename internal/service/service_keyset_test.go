@@ -0,0 +1,115 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"testAlvtoShp/internal/config"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+func parse(keys *KeySet, token string) error {
+	_, err := jwt.Parse(token, keys.Keyfunc)
+	return err
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaDer, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	require.NoError(t, err)
+	rsaPath := writePEM(t, "PRIVATE KEY", rsaDer)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edDer, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	t.Setenv("TEST_JWT_ED_KEY", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDer})))
+
+	oldKeys, err := LoadKeySet(&config.Config{JwtSecret: "secret", JwtKeys: "rsa-1=" + rsaPath, JwtSigningKid: "rsa-1"})
+	require.NoError(t, err)
+
+	oldToken, err := oldKeys.Sign(jwt.MapClaims{"userId": 1})
+	require.NoError(t, err)
+
+	newKeys, err := LoadKeySet(&config.Config{
+		JwtSecret:     "secret",
+		JwtKeys:       "rsa-1=" + rsaPath + ",ed-2=env:TEST_JWT_ED_KEY",
+		JwtSigningKid: "ed-2",
+	})
+	require.NoError(t, err)
+
+	newToken, err := newKeys.Sign(jwt.MapClaims{"userId": 1})
+	require.NoError(t, err)
+
+	assert.NoError(t, parse(newKeys, oldToken), "tokens of the previous key must stay valid")
+	assert.NoError(t, parse(newKeys, newToken))
+	assert.Error(t, parse(oldKeys, newToken), "old key set does not know ed-2")
+
+	token, err := jwt.Parse(newToken, newKeys.Keyfunc)
+	require.NoError(t, err)
+	assert.Equal(t, "ed-2", token.Header["kid"])
+	assert.Equal(t, "EdDSA", token.Header["alg"])
+
+	jwks := newKeys.JWKS()
+	require.Len(t, jwks.Keys, 2, "hmac secret must not be published")
+	assert.Equal(t, "ed-2", jwks.Keys[0].Kid)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "rsa-1", jwks.Keys[1].Kid)
+	assert.Equal(t, "RS256", jwks.Keys[1].Alg)
+}
+
+func TestKeySet_RejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pubDer, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	key, err := ParsePEMKey("rsa-1", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDer}))
+	require.NoError(t, err)
+
+	keys, err := NewKeySet(DefaultKeyID, NewHMACKey(DefaultKeyID, []byte("secret")), key)
+	require.NoError(t, err)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userId": 1})
+	forged.Header["kid"] = "rsa-1"
+	forgedToken, err := forged.SignedString(pubDer)
+	require.NoError(t, err)
+
+	assert.Error(t, parse(keys, forgedToken))
+}
+
+func TestLoadKeySet_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{name: "No signing key", cfg: &config.Config{}},
+		{name: "Unknown signing kid", cfg: &config.Config{JwtSecret: "secret", JwtSigningKid: "missing"}},
+		{name: "Malformed entry", cfg: &config.Config{JwtSecret: "secret", JwtKeys: "no-source"}},
+		{name: "Missing env", cfg: &config.Config{JwtSecret: "secret", JwtKeys: "k=env:TEST_JWT_MISSING"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadKeySet(tc.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
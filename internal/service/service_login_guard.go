@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testAlvtoShp/internal/config"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/store"
+	"time"
+)
+
+const (
+	LoginAttemptsPostgres = "postgres"
+	LoginAttemptsMemory   = "memory"
+)
+
+// maxLoginLockout caps the doubling of the lockout for keys that keep failing.
+const maxLoginLockout = 24 * time.Hour
+
+type LoginGuardService struct {
+	store store.LoginAttempts
+
+	maxFailures      int
+	maxFailuresPerIP int
+	backoffBase      time.Duration
+	lockout          time.Duration
+	window           time.Duration
+
+	now func() time.Time
+}
+
+func NewLoginGuardService(store store.LoginAttempts, cfg *config.Config) *LoginGuardService {
+	return &LoginGuardService{
+		store:            store,
+		maxFailures:      cfg.LoginMaxFailures,
+		maxFailuresPerIP: cfg.LoginMaxFailuresPerIP,
+		backoffBase:      cfg.LoginBackoffBase,
+		lockout:          cfg.LoginLockout,
+		window:           cfg.LoginFailureWindow,
+		now:              time.Now,
+	}
+}
+
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// CheckLogin returns how long the client has to wait before it may try to
+// log in as username again, zero if it may try right away.
+func (s *LoginGuardService) CheckLogin(ctx context.Context, username, ip string) (time.Duration, error) {
+	now := s.now()
+
+	var wait time.Duration
+	for _, key := range []string{usernameKey(username), ipKey(ip)} {
+		attempt, err := s.store.GetLoginAttempt(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if attempt.LockedUntil.Valid {
+			if left := attempt.LockedUntil.Time.Sub(now); left > wait {
+				wait = left
+			}
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordLoginFailure counts a wrong password against both the username and
+// the client IP and returns the delay imposed on the next attempt.
+func (s *LoginGuardService) RecordLoginFailure(ctx context.Context, username, ip string) (time.Duration, error) {
+	log := logger.LoggerFromContext(ctx)
+	now := s.now()
+
+	var wait time.Duration
+	// Many users can share one IP, so the IP only gets the lockout and
+	// not the per failure backoff.
+	for _, k := range []struct {
+		key     string
+		limit   int
+		backoff bool
+	}{
+		{key: usernameKey(username), limit: s.maxFailures, backoff: true},
+		{key: ipKey(ip), limit: s.maxFailuresPerIP},
+	} {
+		failures, err := s.store.RecordLoginFailure(ctx, k.key, now, now.Add(-s.window))
+		if err != nil {
+			return 0, err
+		}
+
+		delay := s.delay(failures, k.limit, k.backoff)
+		if delay <= 0 {
+			continue
+		}
+
+		if err = s.store.LockLogin(ctx, k.key, now.Add(delay)); err != nil {
+			return 0, err
+		}
+
+		if k.limit > 0 && failures >= k.limit {
+			log.Warnw("login locked", "key", k.key, "failures", failures, "until", now.Add(delay))
+		}
+
+		if delay > wait {
+			wait = delay
+		}
+	}
+
+	return wait, nil
+}
+
+// RecordLoginSuccess clears the username counter. The IP counter is left to
+// expire on its own, otherwise logging into an own account would reset it.
+func (s *LoginGuardService) RecordLoginSuccess(ctx context.Context, username, ip string) error {
+	log := logger.LoggerFromContext(ctx)
+	key := usernameKey(username)
+
+	attempt, err := s.store.GetLoginAttempt(ctx, key)
+	if err != nil {
+		return err
+	}
+	if attempt.Failures == 0 {
+		return nil
+	}
+
+	if err = s.store.ResetLoginAttempts(ctx, key); err != nil {
+		return err
+	}
+
+	if s.maxFailures > 0 && attempt.Failures >= s.maxFailures {
+		log.Infow("login unlocked", "key", key, "failures", attempt.Failures, "ip", ip)
+	}
+
+	return nil
+}
+
+// delay is the exponential backoff after the given number of failures:
+// backoffBase doubled per failure below the limit, lockout doubled per
+// failure from the limit on. A limit of zero disables the lockout.
+func (s *LoginGuardService) delay(failures, limit int, backoff bool) time.Duration {
+	if limit > 0 && failures >= limit {
+		return doubled(s.lockout, failures-limit, maxLoginLockout)
+	}
+	if !backoff {
+		return 0
+	}
+	return doubled(s.backoffBase, failures-1, s.lockout)
+}
+
+func doubled(base time.Duration, times int, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < times && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"testAlvtoShp/internal/config"
+	"testAlvtoShp/internal/store"
+)
+
+func newTestLoginGuard(now *time.Time) *LoginGuardService {
+	g := NewLoginGuardService(store.NewMemoryLoginAttemptStore(), &config.Config{
+		LoginMaxFailures:      3,
+		LoginMaxFailuresPerIP: 5,
+		LoginBackoffBase:      time.Second,
+		LoginLockout:          time.Minute,
+		LoginFailureWindow:    time.Hour,
+	})
+	g.now = func() time.Time { return *now }
+	return g
+}
+
+func TestLoginGuardService_BackoffAndLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := newTestLoginGuard(&now)
+
+	expected := []time.Duration{time.Second, 2 * time.Second, time.Minute, 2 * time.Minute}
+	for i, want := range expected {
+		wait, err := g.RecordLoginFailure(ctx, "User", "10.0.0.1")
+		require.NoError(t, err)
+		assert.Equal(t, want, wait, "failure %d", i+1)
+
+		wait, err = g.CheckLogin(ctx, "user", "10.0.0.2")
+		require.NoError(t, err)
+		assert.Equal(t, want, wait, "username is case insensitive")
+	}
+
+	now = now.Add(2 * time.Minute)
+	wait, err := g.CheckLogin(ctx, "user", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait, "lockout expires")
+
+	require.NoError(t, g.RecordLoginSuccess(ctx, "user", "10.0.0.1"))
+	wait, err = g.RecordLoginFailure(ctx, "user", "10.0.0.3")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, wait, "success resets the username counter")
+}
+
+func TestLoginGuardService_IPLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := newTestLoginGuard(&now)
+
+	for _, username := range []string{"a", "b", "c", "d"} {
+		_, err := g.RecordLoginFailure(ctx, username, "10.0.0.1")
+		require.NoError(t, err)
+	}
+
+	wait, err := g.CheckLogin(ctx, "fresh", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait, "ip gets no backoff below the limit")
+
+	wait, err = g.RecordLoginFailure(ctx, "e", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, wait)
+
+	wait, err = g.CheckLogin(ctx, "fresh", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, wait)
+
+	wait, err = g.CheckLogin(ctx, "fresh", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestLoginGuardService_WindowExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := newTestLoginGuard(&now)
+
+	for i := 0; i < 2; i++ {
+		_, err := g.RecordLoginFailure(ctx, "user", "10.0.0.1")
+		require.NoError(t, err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	wait, err := g.RecordLoginFailure(ctx, "user", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, wait, "failures outside the window are forgotten")
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+func legacyHash(password string) string {
+	hash := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestVerifyPassword(t *testing.T) {
+	argonHash, err := NewArgon2idHasher().Hash("secret123")
+	assert.NoError(t, err)
+	bcryptHash, err := NewBcryptHasher(bcrypt.MinCost).Hash("secret123")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		password  string
+		encoded   string
+		expected  bool
+		expectErr error
+	}{
+		{name: "argon2id match", password: "secret123", encoded: argonHash, expected: true},
+		{name: "argon2id mismatch", password: "wrong", encoded: argonHash, expected: false},
+		{name: "bcrypt match", password: "secret123", encoded: bcryptHash, expected: true},
+		{name: "bcrypt mismatch", password: "wrong", encoded: bcryptHash, expected: false},
+		{name: "legacy sha256 match", password: "secret123", encoded: legacyHash("secret123"), expected: true},
+		{name: "legacy sha256 mismatch", password: "wrong", encoded: legacyHash("secret123"), expected: false},
+		{name: "unknown format", password: "secret123", encoded: "plaintext", expectErr: internalErrors.UnknownHashFormat},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := VerifyPassword(tc.password, tc.encoded)
+			if tc.expectErr != nil {
+				assert.ErrorIs(t, err, tc.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, ok)
+		})
+	}
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	h := NewArgon2idHasher()
+
+	first, err := h.Hash("secret123")
+	assert.NoError(t, err)
+	second, err := h.Hash("secret123")
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(first, "$argon2id$v=19$m=65536,t=3,p=4$"))
+	assert.NotEqual(t, first, second, "hashes must be salted")
+	assert.False(t, h.NeedsRehash(first))
+
+	weaker := &Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	weakHash, err := weaker.Hash("secret123")
+	assert.NoError(t, err)
+	assert.True(t, h.NeedsRehash(weakHash))
+	assert.True(t, h.NeedsRehash(legacyHash("secret123")))
+}
+
+func TestBcryptHasher_NeedsRehash(t *testing.T) {
+	h := NewBcryptHasher(bcrypt.MinCost)
+
+	hash, err := h.Hash("secret123")
+	assert.NoError(t, err)
+
+	assert.False(t, h.NeedsRehash(hash))
+	assert.True(t, NewBcryptHasher(bcrypt.MinCost+1).NeedsRehash(hash))
+	assert.True(t, h.NeedsRehash(legacyHash("secret123")))
+}
+
+type fakePasswordStore struct {
+	store.Auth
+	userId  int
+	hash    string
+	updated string
+}
+
+func (f *fakePasswordStore) GetPasswordHash(_ context.Context, _ string) (int, string, error) {
+	return f.userId, f.hash, nil
+}
+
+func (f *fakePasswordStore) UpdatePasswordHash(_ context.Context, _ int, hash string) error {
+	f.updated = hash
+	return nil
+}
+
+func TestAuthService_CheckPassword(t *testing.T) {
+	tests := []struct {
+		name            string
+		storedHash      string
+		password        string
+		expectedValid   bool
+		expectedUpgrade bool
+	}{
+		{
+			name:            "Legacy hash is upgraded on success",
+			storedHash:      legacyHash("secret123"),
+			password:        "secret123",
+			expectedValid:   true,
+			expectedUpgrade: true,
+		},
+		{
+			name:            "Legacy hash is kept on wrong password",
+			storedHash:      legacyHash("secret123"),
+			password:        "wrong",
+			expectedValid:   false,
+			expectedUpgrade: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakePasswordStore{userId: 42, hash: tc.storedHash}
+			s := NewAuthService(fake, nil, NewBcryptHasher(bcrypt.MinCost), nil, &config.Config{})
+
+			ok, err := s.CheckPassword(context.Background(), models.AuthRequest{Username: "user", Password: tc.password})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedValid, ok)
+
+			if tc.expectedUpgrade {
+				assert.True(t, isBcryptHash(fake.updated))
+				valid, err := VerifyPassword(tc.password, fake.updated)
+				assert.NoError(t, err)
+				assert.True(t, valid)
+			} else {
+				assert.Empty(t, fake.updated)
+			}
+		})
+	}
+}
+
+func TestAuthService_CheckPassword_UnknownUser(t *testing.T) {
+	// the dummy hash must cost as much to verify as a hash of a real user
+	assert.False(t, NewArgon2idHasher().NeedsRehash(dummyPasswordHash))
+	ok, err := VerifyPassword("secret123", dummyPasswordHash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	s := NewAuthService(&fakePasswordStore{}, nil, NewArgon2idHasher(), nil, &config.Config{})
+	ok, err = s.CheckPassword(context.Background(), models.AuthRequest{Username: "nobody", Password: "secret123"})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+	"time"
+)
+
+// ReconcileService compares the balances cached in users.coins with the
+// ledger they are derived from.
+type ReconcileService struct {
+	store store.Ledger
+
+	now func() time.Time
+}
+
+func NewReconcileService(store store.Ledger) *ReconcileService {
+	return &ReconcileService{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Reconcile reports the users whose balance drifts from the ledger. With fix
+// the drifted balances are reset to the ledger and reported as they were
+// right before, a balance that can not be fixed is reported with the error
+// and does not stop the others.
+func (s *ReconcileService) Reconcile(ctx context.Context, fix bool) (models.ReconcileReport, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	report := models.ReconcileReport{StartedAt: s.now().UTC(), DryRun: !fix}
+	checked, drifted, err := s.store.GetBalanceDrift(ctx)
+	if err != nil {
+		return models.ReconcileReport{}, err
+	}
+	report.Checked, report.Drifted = checked, drifted
+
+	if !fix {
+		return report, nil
+	}
+
+	for i, drift := range report.Drifted {
+		fixed, err := s.store.FixBalance(ctx, drift.UserID)
+		if err != nil {
+			log.Errorw("failed to fix balance", "user_id", drift.UserID, zap.Error(err))
+			report.Drifted[i].Error = err.Error()
+			continue
+		}
+		report.Drifted[i] = fixed
+		if fixed.Fixed {
+			log.Infow("balance fixed", "user_id", fixed.UserID, "coins", fixed.Coins, "expected", fixed.Expected)
+			report.Fixed++
+		}
+	}
+
+	return report, nil
+}
+
+// Run reconciles the balances every interval until ctx is done. Drift is
+// logged with the whole report, a failed run is only logged and the next
+// tick tries again.
+func (s *ReconcileService) Run(ctx context.Context, interval time.Duration, fix bool) {
+	log := logger.LoggerFromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := s.Reconcile(ctx, fix)
+		if err != nil {
+			log.Errorw("failed to reconcile balances", zap.Error(err))
+			continue
+		}
+		if len(report.Drifted) > 0 {
+			log.Warnw("balances drift from the ledger", "checked", report.Checked,
+				"drifted", len(report.Drifted), "fixed", report.Fixed, "report", report)
+		}
+	}
+}
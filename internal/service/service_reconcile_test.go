@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+)
+
+// fakeLedgerStore reports drifted and fixes every user but the ones in
+// fixErrs.
+type fakeLedgerStore struct {
+	drifted []models.BalanceDrift
+	fixErrs map[int]error
+	fixed   []int
+}
+
+func (f *fakeLedgerStore) GetBalanceDrift(_ context.Context) (int, []models.BalanceDrift, error) {
+	return 10, append([]models.BalanceDrift(nil), f.drifted...), nil
+}
+
+func (f *fakeLedgerStore) FixBalance(_ context.Context, userId int) (models.BalanceDrift, error) {
+	if err := f.fixErrs[userId]; err != nil {
+		return models.BalanceDrift{}, err
+	}
+	f.fixed = append(f.fixed, userId)
+	for _, d := range f.drifted {
+		if d.UserID == userId {
+			d.Fixed = true
+			return d, nil
+		}
+	}
+	return models.BalanceDrift{UserID: userId}, nil
+}
+
+func TestReconcileService_Reconcile(t *testing.T) {
+	now := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	drifted := []models.BalanceDrift{
+		{UserID: 1, Username: "alice", Coins: 150, Expected: 100, Drift: 50},
+		{UserID: 2, Username: "bob", Coins: 0, Expected: -5, Drift: 5},
+	}
+
+	t.Run("Dry run only reports", func(t *testing.T) {
+		fake := &fakeLedgerStore{drifted: drifted}
+		s := NewReconcileService(fake)
+		s.now = func() time.Time { return now }
+
+		report, err := s.Reconcile(context.Background(), false)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ReconcileReport{StartedAt: now, DryRun: true, Checked: 10, Drifted: drifted}, report)
+		assert.Empty(t, fake.fixed)
+	})
+
+	t.Run("Fix keeps going after a failed user", func(t *testing.T) {
+		fixErr := fmt.Errorf("%w: -5", internalErrors.NegativeLedgerBalance)
+		fake := &fakeLedgerStore{drifted: drifted, fixErrs: map[int]error{2: fixErr}}
+		s := NewReconcileService(fake)
+		s.now = func() time.Time { return now }
+
+		report, err := s.Reconcile(context.Background(), true)
+		assert.NoError(t, err)
+		assert.False(t, report.DryRun)
+		assert.Equal(t, 1, report.Fixed)
+		assert.Equal(t, []int{1}, fake.fixed)
+		assert.True(t, report.Drifted[0].Fixed)
+		assert.False(t, report.Drifted[1].Fixed)
+		assert.Equal(t, fixErr.Error(), report.Drifted[1].Error)
+	})
+
+	t.Run("Nothing drifts", func(t *testing.T) {
+		fake := &fakeLedgerStore{}
+		report, err := NewReconcileService(fake).Reconcile(context.Background(), true)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, report.Checked)
+		assert.Empty(t, report.Drifted)
+		assert.Zero(t, report.Fixed)
+	})
+}
+
+func TestReconcileService_RunStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewReconcileService(&fakeLedgerStore{}).Run(ctx, time.Millisecond, false)
+		close(done)
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop")
+	}
+}
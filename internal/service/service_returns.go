@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+	"time"
+)
+
+// A page of returns an admin gets at once.
+const (
+	defaultReturnsLimit = 50
+	maxReturnsLimit     = 100
+)
+
+type ReturnService struct {
+	store  store.Returns
+	window time.Duration
+
+	now func() time.Time
+}
+
+func NewReturnService(store store.Returns, cfg *config.Config) *ReturnService {
+	return &ReturnService{
+		store:  store,
+		window: cfg.ReturnWindow,
+		now:    time.Now,
+	}
+}
+
+func (s *ReturnService) RequestReturn(ctx context.Context, userId int, orderId int64, req models.ReturnRequest) (models.Return, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return models.Return{}, fmt.Errorf("%w: reason is required", internalErrors.InvalidReason)
+	}
+	if len(reason) > maxReasonLength {
+		return models.Return{}, fmt.Errorf("%w: at most %d characters", internalErrors.InvalidReason, maxReasonLength)
+	}
+
+	ret, err := s.store.CreateReturn(ctx, userId, orderId, reason, s.now().Add(-s.window))
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	log.Infow("return requested", "user_id", userId, "order_id", orderId, "return_id", ret.ID, "amount", ret.Amount)
+
+	return ret, nil
+}
+
+func (s *ReturnService) GetReturns(ctx context.Context, query models.ReturnsQuery) (models.ReturnsResponse, error) {
+	switch query.Status {
+	case "", models.ReturnPending, models.ReturnApproved, models.ReturnRejected:
+	default:
+		return models.ReturnsResponse{}, fmt.Errorf("%w: unknown status %q", internalErrors.InvalidFilter, query.Status)
+	}
+
+	filter := models.ReturnsFilter{Status: query.Status, Limit: query.Limit}
+	if filter.Limit == 0 {
+		filter.Limit = defaultReturnsLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxReturnsLimit {
+		return models.ReturnsResponse{}, fmt.Errorf("%w: limit must be between 1 and %d", internalErrors.InvalidFilter, maxReturnsLimit)
+	}
+
+	if query.Cursor != "" {
+		createdAt, id, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return models.ReturnsResponse{}, err
+		}
+		filter.AfterCreatedAt, filter.AfterID = createdAt, id
+	}
+
+	limit := filter.Limit
+	// one more return tells whether there is a next page
+	filter.Limit++
+	returns, err := s.store.GetReturns(ctx, filter)
+	if err != nil {
+		return models.ReturnsResponse{}, err
+	}
+
+	response := models.ReturnsResponse{Returns: returns}
+	if len(returns) > limit {
+		response.Returns = returns[:limit]
+		last := response.Returns[limit-1]
+		response.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return response, nil
+}
+
+func (s *ReturnService) ApproveReturn(ctx context.Context, actorId int, returnId int64, req models.ReturnDecisionRequest) (models.Return, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	note, err := validateNote(req.Note)
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	ret, err := s.store.ApproveReturn(ctx, returnId, actorId, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	log.Infow("return approved", "actor_id", actorId, "return_id", returnId, "user_id", ret.UserID,
+		"order_id", ret.OrderID, "refund", ret.Amount)
+
+	return ret, nil
+}
+
+func (s *ReturnService) RejectReturn(ctx context.Context, actorId int, returnId int64, req models.ReturnDecisionRequest) (models.Return, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	note, err := validateNote(req.Note)
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	ret, err := s.store.RejectReturn(ctx, returnId, actorId, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	log.Infow("return rejected", "actor_id", actorId, "return_id", returnId, "user_id", ret.UserID, "order_id", ret.OrderID)
+
+	return ret, nil
+}
+
+// validateNote checks the optional note an admin leaves on a decision.
+func validateNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if len(note) > maxReasonLength {
+		return "", fmt.Errorf("%w: at most %d characters", internalErrors.InvalidReason, maxReasonLength)
+	}
+	return note, nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+// fakeReturnStore records what reaches the store.
+type fakeReturnStore struct {
+	store.Returns
+	orderedAfter []time.Time
+	reasons      []string
+	returns      []models.Return
+	filters      []models.ReturnsFilter
+	notes        []string
+}
+
+func (f *fakeReturnStore) CreateReturn(_ context.Context, userId int, orderId int64, reason string, orderedAfter time.Time) (models.Return, error) {
+	f.orderedAfter = append(f.orderedAfter, orderedAfter)
+	f.reasons = append(f.reasons, reason)
+	return models.Return{ID: 1, OrderID: orderId, UserID: userId, Status: models.ReturnPending, Reason: reason}, nil
+}
+
+func (f *fakeReturnStore) GetReturns(_ context.Context, filter models.ReturnsFilter) ([]models.Return, error) {
+	f.filters = append(f.filters, filter)
+
+	page := []models.Return{}
+	for _, ret := range f.returns {
+		if filter.AfterID != 0 && !ret.CreatedAt.After(filter.AfterCreatedAt) &&
+			!(ret.CreatedAt.Equal(filter.AfterCreatedAt) && ret.ID > filter.AfterID) {
+			continue
+		}
+		if len(page) == filter.Limit {
+			break
+		}
+		page = append(page, ret)
+	}
+	return page, nil
+}
+
+func (f *fakeReturnStore) ApproveReturn(_ context.Context, returnId int64, _ int, note string) (models.Return, error) {
+	f.notes = append(f.notes, note)
+	return models.Return{ID: returnId, Status: models.ReturnApproved, Note: note}, nil
+}
+
+func TestReturnService_RequestReturn(t *testing.T) {
+	now := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	fake := &fakeReturnStore{}
+	s := NewReturnService(fake, &config.Config{ReturnWindow: 14 * 24 * time.Hour})
+	s.now = func() time.Time { return now }
+
+	ret, err := s.RequestReturn(context.Background(), 42, 7, models.ReturnRequest{Reason: "  wrong size "})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), ret.OrderID)
+	assert.Equal(t, []string{"wrong size"}, fake.reasons)
+	assert.Equal(t, []time.Time{now.Add(-14 * 24 * time.Hour)}, fake.orderedAfter)
+
+	_, err = s.RequestReturn(context.Background(), 42, 7, models.ReturnRequest{Reason: " "})
+	assert.ErrorIs(t, err, internalErrors.InvalidReason)
+	_, err = s.RequestReturn(context.Background(), 42, 7, models.ReturnRequest{Reason: strings.Repeat("a", maxReasonLength+1)})
+	assert.ErrorIs(t, err, internalErrors.InvalidReason)
+	assert.Len(t, fake.reasons, 1)
+}
+
+func TestReturnService_Decisions(t *testing.T) {
+	fake := &fakeReturnStore{}
+	s := NewReturnService(fake, &config.Config{ReturnWindow: time.Hour})
+
+	ret, err := s.ApproveReturn(context.Background(), 1, 3, models.ReturnDecisionRequest{Note: " ok "})
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReturnApproved, ret.Status)
+	assert.Equal(t, []string{"ok"}, fake.notes)
+
+	_, err = s.ApproveReturn(context.Background(), 1, 3, models.ReturnDecisionRequest{Note: strings.Repeat("a", maxReasonLength+1)})
+	assert.ErrorIs(t, err, internalErrors.InvalidReason)
+
+}
+
+func TestReturnService_GetReturnsPages(t *testing.T) {
+	base := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	fake := &fakeReturnStore{returns: []models.Return{
+		{ID: 1, CreatedAt: base.Add(-2 * time.Hour)},
+		{ID: 2, CreatedAt: base.Add(-time.Hour)},
+		{ID: 3, CreatedAt: base},
+		{ID: 4, CreatedAt: base},
+		{ID: 5, CreatedAt: base.Add(time.Hour)},
+	}}
+	s := NewReturnService(fake, &config.Config{ReturnWindow: time.Hour})
+
+	var ids []int64
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		page, err := s.GetReturns(context.Background(), models.ReturnsQuery{Status: models.ReturnPending, Cursor: cursor, Limit: 2})
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Returns), 2)
+		for _, ret := range page.Returns {
+			ids = append(ids, ret.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, ids)
+	assert.Equal(t, 3, fake.filters[0].Limit, "one extra return is fetched to detect the next page")
+	assert.Equal(t, models.ReturnPending, fake.filters[0].Status)
+}
+
+func TestReturnService_GetReturnsValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       models.ReturnsQuery
+		expectedErr error
+	}{
+		{name: "All statuses", query: models.ReturnsQuery{}},
+		{name: "Unknown status", query: models.ReturnsQuery{Status: "lost"}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Negative limit", query: models.ReturnsQuery{Limit: -1}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Limit too large", query: models.ReturnsQuery{Limit: maxReturnsLimit + 1}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Garbage cursor", query: models.ReturnsQuery{Cursor: "Z2FyYmFnZQ"}, expectedErr: internalErrors.InvalidCursor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeReturnStore{}
+			s := NewReturnService(fake, &config.Config{ReturnWindow: time.Hour})
+
+			_, err := s.GetReturns(context.Background(), tc.query)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, fake.filters)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, defaultReturnsLimit+1, fake.filters[0].Limit)
+		})
+	}
+}
@@ -2,26 +2,312 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
 	"testAlvtoShp/internal/store"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultOrdersLimit = 20
+	maxOrdersLimit     = 100
+
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+
+	maxCartLines    = 50
+	maxLineQuantity = 100
+
+	// maxMemoLength is the size of the memo column of coin transactions
+	maxMemoLength = 200
 )
 
 type ShopService struct {
-	store store.Shop
+	store        store.Shop
+	historyLimit int
 }
 
-func NewShopService(store store.Shop) *ShopService {
-	return &ShopService{store}
+func NewShopService(store store.Shop, cfg *config.Config) *ShopService {
+	return &ShopService{
+		store:        store,
+		historyLimit: cfg.InfoHistoryLimit,
+	}
 }
 
 func (s *ShopService) GetUserInfo(ctx context.Context, userId int) (models.InfoResponse, error) {
-	return s.store.GetUserInfo(ctx, userId)
+	return s.store.GetUserInfo(ctx, userId, s.historyLimit)
+}
+
+func (s *ShopService) BuyItem(ctx context.Context, userId int, item string, quantity int) error {
+	cart, err := normalizeCart([]models.CartLine{{Item: item, Quantity: quantity}})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.store.Checkout(ctx, userId, cart)
+	return err
+}
+
+func (s *ShopService) Checkout(ctx context.Context, userId int, req models.CheckoutRequest) (models.Order, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	cart, err := normalizeCart(req.Lines)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	order, err := s.store.Checkout(ctx, userId, cart)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	log.Infow("checkout", "user_id", userId, "order_id", order.ID, "total", order.Total, "lines", len(order.Lines))
+
+	return order, nil
 }
 
-func (s *ShopService) BuyItem(ctx context.Context, userId int, item string) error {
-	return s.store.BuyItem(ctx, userId, item)
+func (s *ShopService) Gift(ctx context.Context, userId int, req models.GiftRequest) (models.Order, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	quantity := 1
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+
+	cart, err := normalizeCart([]models.CartLine{{Item: req.Item, Quantity: quantity}})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	order, err := s.store.Gift(ctx, userId, req.ToUser, cart)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	log.Infow("gift", "user_id", userId, "to_user", req.ToUser, "order_id", order.ID, "total", order.Total)
+
+	return order, nil
+}
+
+// normalizeCart validates the cart and merges lines of the same item. The
+// lines come back sorted by item, the order the store locks them in.
+func normalizeCart(lines []models.CartLine) ([]models.CartLine, error) {
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: cart is empty", internalErrors.InvalidCart)
+	}
+	if len(lines) > maxCartLines {
+		return nil, fmt.Errorf("%w: at most %d lines", internalErrors.InvalidCart, maxCartLines)
+	}
+
+	quantities := make(map[string]int, len(lines))
+	for _, line := range lines {
+		if line.Item == "" {
+			return nil, fmt.Errorf("%w: item is required", internalErrors.InvalidCart)
+		}
+		if line.Quantity < 1 || line.Quantity > maxLineQuantity {
+			return nil, fmt.Errorf("%w: quantity of %s must be between 1 and %d", internalErrors.InvalidCart, line.Item, maxLineQuantity)
+		}
+		quantities[line.Item] += line.Quantity
+	}
+
+	cart := make([]models.CartLine, 0, len(quantities))
+	for item, quantity := range quantities {
+		if quantity > maxLineQuantity {
+			return nil, fmt.Errorf("%w: quantity of %s must be between 1 and %d", internalErrors.InvalidCart, item, maxLineQuantity)
+		}
+		cart = append(cart, models.CartLine{Item: item, Quantity: quantity})
+	}
+	sort.Slice(cart, func(i, j int) bool { return cart[i].Item < cart[j].Item })
+
+	return cart, nil
 }
 
 func (s *ShopService) SendCoin(ctx context.Context, userId int, req models.SendCoinRequest) error {
+	memo, err := sanitizeMemo(req.Memo)
+	if err != nil {
+		return err
+	}
+	req.Memo = memo
+
 	return s.store.SendCoin(ctx, userId, req)
 }
+
+// sanitizeMemo makes a memo safe to show as a single line: control
+// characters, line breaks among them, become spaces and invisible formatting
+// characters such as bidi overrides are dropped.
+func sanitizeMemo(memo string) (string, error) {
+	if !utf8.ValidString(memo) {
+		return "", fmt.Errorf("%w: not valid UTF-8", internalErrors.InvalidMemo)
+	}
+
+	memo = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return ' '
+		case unicode.Is(unicode.Cf, r):
+			return -1
+		}
+		return r
+	}, memo)
+	memo = strings.TrimSpace(memo)
+
+	if utf8.RuneCountInString(memo) > maxMemoLength {
+		return "", fmt.Errorf("%w: at most %d characters", internalErrors.InvalidMemo, maxMemoLength)
+	}
+	return memo, nil
+}
+
+func (s *ShopService) TransferItem(ctx context.Context, userId int, req models.ItemTransferRequest) (models.ItemTransfer, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	if req.ToUser == "" {
+		return models.ItemTransfer{}, fmt.Errorf("%w: toUser is required", internalErrors.InvalidTransfer)
+	}
+	if req.Item == "" {
+		return models.ItemTransfer{}, fmt.Errorf("%w: item is required", internalErrors.InvalidTransfer)
+	}
+	if req.Quantity < 1 {
+		return models.ItemTransfer{}, fmt.Errorf("%w: quantity must be greater than zero", internalErrors.InvalidTransfer)
+	}
+
+	transfer, err := s.store.TransferItem(ctx, userId, req)
+	if err != nil {
+		return models.ItemTransfer{}, err
+	}
+
+	log.Infow("item transfer", "user_id", userId, "to_user", req.ToUser, "item", req.Item,
+		"quantity", req.Quantity, "transfer_id", transfer.ID)
+
+	return transfer, nil
+}
+
+func (s *ShopService) GetOrders(ctx context.Context, userId int, query models.OrdersQuery) (models.OrdersResponse, error) {
+	filter := models.OrdersFilter{
+		UserID: userId,
+		Item:   query.Item,
+		From:   query.From,
+		To:     query.To,
+		Limit:  query.Limit,
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultOrdersLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxOrdersLimit {
+		return models.OrdersResponse{}, fmt.Errorf("%w: limit must be between 1 and %d", internalErrors.InvalidFilter, maxOrdersLimit)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return models.OrdersResponse{}, fmt.Errorf("%w: from must be before to", internalErrors.InvalidFilter)
+	}
+
+	if query.Cursor != "" {
+		createdAt, id, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return models.OrdersResponse{}, err
+		}
+		filter.AfterCreatedAt, filter.AfterID = createdAt, id
+	}
+
+	limit := filter.Limit
+	// one more order tells whether there is a next page
+	filter.Limit++
+	orders, err := s.store.GetOrders(ctx, filter)
+	if err != nil {
+		return models.OrdersResponse{}, err
+	}
+
+	response := models.OrdersResponse{Orders: orders}
+	if len(orders) > limit {
+		response.Orders = orders[:limit]
+		last := response.Orders[limit-1]
+		response.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return response, nil
+}
+
+func (s *ShopService) GetTransactions(ctx context.Context, userId int, query models.TransactionsQuery) (models.TransactionsResponse, error) {
+	filter := models.TransactionsFilter{
+		UserID:       userId,
+		Direction:    query.Direction,
+		Counterparty: query.Counterparty,
+		MinAmount:    query.MinAmount,
+		MaxAmount:    query.MaxAmount,
+		From:         query.From,
+		To:           query.To,
+		Limit:        query.Limit,
+	}
+
+	switch filter.Direction {
+	case "", models.DirectionIn, models.DirectionOut:
+	default:
+		return models.TransactionsResponse{}, fmt.Errorf("%w: direction must be %q or %q", internalErrors.InvalidFilter,
+			models.DirectionIn, models.DirectionOut)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultTransactionsLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxTransactionsLimit {
+		return models.TransactionsResponse{}, fmt.Errorf("%w: limit must be between 1 and %d", internalErrors.InvalidFilter, maxTransactionsLimit)
+	}
+	if filter.MinAmount < 0 || filter.MaxAmount < 0 {
+		return models.TransactionsResponse{}, fmt.Errorf("%w: amounts must not be negative", internalErrors.InvalidFilter)
+	}
+	if filter.MaxAmount != 0 && filter.MinAmount > filter.MaxAmount {
+		return models.TransactionsResponse{}, fmt.Errorf("%w: minAmount must not be above maxAmount", internalErrors.InvalidFilter)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return models.TransactionsResponse{}, fmt.Errorf("%w: from must be before to", internalErrors.InvalidFilter)
+	}
+
+	if query.Cursor != "" {
+		timestamp, id, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return models.TransactionsResponse{}, err
+		}
+		filter.AfterTimestamp, filter.AfterID = timestamp, id
+	}
+
+	limit := filter.Limit
+	// one more transaction tells whether there is a next page
+	filter.Limit++
+	transactions, err := s.store.GetTransactions(ctx, filter)
+	if err != nil {
+		return models.TransactionsResponse{}, err
+	}
+
+	response := models.TransactionsResponse{Transactions: transactions}
+	if len(transactions) > limit {
+		response.Transactions = transactions[:limit]
+		last := response.Transactions[limit-1]
+		response.NextCursor = encodeCursor(last.Timestamp, last.ID)
+	}
+	return response, nil
+}
+
+// A cursor is the time in microseconds and the id of the last row of a page,
+// of an order or of a coin transaction.
+func encodeCursor(at time.Time, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", at.UnixMicro(), id)))
+}
+
+// decodeCursor returns the time in UTC.
+func decodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, internalErrors.InvalidCursor
+	}
+
+	var micros, id int64
+	if n, err := fmt.Sscanf(string(raw), "%d:%d", &micros, &id); err != nil || n != 2 || id <= 0 {
+		return time.Time{}, 0, internalErrors.InvalidCursor
+	}
+	return time.UnixMicro(micros).UTC(), id, nil
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"testAlvtoShp/internal/config"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/store"
+)
+
+// fakeShopStore serves orders and coin transactions newest first from fixed
+// lists.
+type fakeShopStore struct {
+	store.Shop
+	orders    []models.Order
+	filters   []models.OrdersFilter
+	carts     [][]models.CartLine
+	gifts     []string
+	transfers []models.ItemTransferRequest
+
+	sends        []models.SendCoinRequest
+	transactions []models.Transaction
+	txFilters    []models.TransactionsFilter
+	historyLimit int
+}
+
+func (f *fakeShopStore) Checkout(_ context.Context, _ int, cart []models.CartLine) (models.Order, error) {
+	f.carts = append(f.carts, cart)
+	return models.Order{ID: int64(len(f.carts))}, nil
+}
+
+func (f *fakeShopStore) SendCoin(_ context.Context, _ int, req models.SendCoinRequest) error {
+	f.sends = append(f.sends, req)
+	return nil
+}
+
+func (f *fakeShopStore) GetUserInfo(_ context.Context, _ int, historyLimit int) (models.InfoResponse, error) {
+	f.historyLimit = historyLimit
+	return models.InfoResponse{}, nil
+}
+
+func (f *fakeShopStore) GetTransactions(_ context.Context, filter models.TransactionsFilter) ([]models.Transaction, error) {
+	f.txFilters = append(f.txFilters, filter)
+
+	page := []models.Transaction{}
+	for _, tx := range f.transactions {
+		if filter.AfterID != 0 && !tx.Timestamp.Before(filter.AfterTimestamp) &&
+			!(tx.Timestamp.Equal(filter.AfterTimestamp) && tx.ID < filter.AfterID) {
+			continue
+		}
+		if len(page) == filter.Limit {
+			break
+		}
+		page = append(page, tx)
+	}
+	return page, nil
+}
+
+func (f *fakeShopStore) Gift(_ context.Context, _ int, toUser string, cart []models.CartLine) (models.Order, error) {
+	f.gifts = append(f.gifts, toUser)
+	f.carts = append(f.carts, cart)
+	return models.Order{ID: int64(len(f.carts)), GiftTo: toUser}, nil
+}
+
+func (f *fakeShopStore) TransferItem(_ context.Context, _ int, req models.ItemTransferRequest) (models.ItemTransfer, error) {
+	f.transfers = append(f.transfers, req)
+	return models.ItemTransfer{ID: int64(len(f.transfers)), ToUser: req.ToUser, Item: req.Item, Quantity: req.Quantity}, nil
+}
+
+func (f *fakeShopStore) GetOrders(_ context.Context, filter models.OrdersFilter) ([]models.Order, error) {
+	f.filters = append(f.filters, filter)
+
+	page := []models.Order{}
+	for _, order := range f.orders {
+		if filter.AfterID != 0 && !order.CreatedAt.Before(filter.AfterCreatedAt) &&
+			!(order.CreatedAt.Equal(filter.AfterCreatedAt) && order.ID < filter.AfterID) {
+			continue
+		}
+		if len(page) == filter.Limit {
+			break
+		}
+		page = append(page, order)
+	}
+	return page, nil
+}
+
+func TestShopService_GetOrdersPages(t *testing.T) {
+	base := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	fake := &fakeShopStore{orders: []models.Order{
+		{ID: 5, CreatedAt: base.Add(time.Hour)},
+		{ID: 4, CreatedAt: base},
+		{ID: 3, CreatedAt: base},
+		{ID: 2, CreatedAt: base.Add(-time.Hour)},
+		{ID: 1, CreatedAt: base.Add(-2 * time.Hour)},
+	}}
+	s := NewShopService(fake, &config.Config{InfoHistoryLimit: 10})
+
+	var ids []int64
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		page, err := s.GetOrders(context.Background(), 42, models.OrdersQuery{Cursor: cursor, Limit: 2})
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Orders), 2)
+		for _, order := range page.Orders {
+			ids = append(ids, order.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	assert.Equal(t, []int64{5, 4, 3, 2, 1}, ids)
+	assert.Equal(t, 3, fake.filters[0].Limit, "one extra order is fetched to detect the next page")
+	assert.Equal(t, 42, fake.filters[0].UserID)
+}
+
+func TestShopService_GetOrdersValidation(t *testing.T) {
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		query       models.OrdersQuery
+		expectedErr error
+	}{
+		{name: "Default limit", query: models.OrdersQuery{}},
+		{name: "Negative limit", query: models.OrdersQuery{Limit: -1}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Too large limit", query: models.OrdersQuery{Limit: maxOrdersLimit + 1}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Empty range", query: models.OrdersQuery{From: from, To: from}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Not base64", query: models.OrdersQuery{Cursor: "%%%"}, expectedErr: internalErrors.InvalidCursor},
+		{name: "Garbage cursor", query: models.OrdersQuery{Cursor: "Z2FyYmFnZQ"}, expectedErr: internalErrors.InvalidCursor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeShopStore{}
+			_, err := NewShopService(fake, &config.Config{InfoHistoryLimit: 10}).GetOrders(context.Background(), 42, tc.query)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, fake.filters)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, defaultOrdersLimit+1, fake.filters[0].Limit)
+		})
+	}
+}
+
+func TestShopService_GetUserInfoCapsHistory(t *testing.T) {
+	fake := &fakeShopStore{}
+	_, err := NewShopService(fake, &config.Config{InfoHistoryLimit: 7}).GetUserInfo(context.Background(), 42)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, fake.historyLimit)
+}
+
+func TestShopService_GetTransactionsPages(t *testing.T) {
+	base := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	fake := &fakeShopStore{transactions: []models.Transaction{
+		{ID: 9, Timestamp: base.Add(time.Hour)},
+		{ID: 8, Timestamp: base},
+		{ID: 6, Timestamp: base},
+		{ID: 3, Timestamp: base.Add(-time.Hour)},
+		{ID: 1, Timestamp: base.Add(-2 * time.Hour)},
+	}}
+	s := NewShopService(fake, &config.Config{InfoHistoryLimit: 10})
+
+	var ids []int64
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		page, err := s.GetTransactions(context.Background(), 42, models.TransactionsQuery{Cursor: cursor, Limit: 2})
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.Transactions), 2)
+		for _, tx := range page.Transactions {
+			ids = append(ids, tx.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	assert.Equal(t, []int64{9, 8, 6, 3, 1}, ids)
+	assert.Equal(t, 3, fake.txFilters[0].Limit, "one extra transaction is fetched to detect the next page")
+	assert.Equal(t, time.UTC, fake.txFilters[1].AfterTimestamp.Location())
+}
+
+func TestShopService_GetTransactionsValidation(t *testing.T) {
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.FixedZone("UTC+3", 3*60*60))
+
+	tests := []struct {
+		name        string
+		query       models.TransactionsQuery
+		expectedErr error
+	}{
+		{name: "Default limit", query: models.TransactionsQuery{}},
+		{name: "Direction", query: models.TransactionsQuery{Direction: models.DirectionOut}},
+		{name: "Unknown direction", query: models.TransactionsQuery{Direction: "sideways"}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Too large limit", query: models.TransactionsQuery{Limit: maxTransactionsLimit + 1}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Negative amount", query: models.TransactionsQuery{MinAmount: -1}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Min above max", query: models.TransactionsQuery{MinAmount: 10, MaxAmount: 5}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Only min", query: models.TransactionsQuery{MinAmount: 10}},
+		{name: "Empty range", query: models.TransactionsQuery{From: from, To: from}, expectedErr: internalErrors.InvalidFilter},
+		{name: "Garbage cursor", query: models.TransactionsQuery{Cursor: "Z2FyYmFnZQ"}, expectedErr: internalErrors.InvalidCursor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeShopStore{}
+			_, err := NewShopService(fake, &config.Config{InfoHistoryLimit: 10}).GetTransactions(context.Background(), 42, tc.query)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, fake.txFilters)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, defaultTransactionsLimit+1, fake.txFilters[0].Limit)
+		})
+	}
+
+	t.Run("Range passed as is", func(t *testing.T) {
+		fake := &fakeShopStore{}
+		_, err := NewShopService(fake, &config.Config{InfoHistoryLimit: 10}).
+			GetTransactions(context.Background(), 42, models.TransactionsQuery{From: from})
+		assert.NoError(t, err)
+		assert.Equal(t, from, fake.txFilters[0].From)
+	})
+}
+
+func TestShopService_Checkout(t *testing.T) {
+	tests := []struct {
+		name        string
+		lines       []models.CartLine
+		expected    []models.CartLine
+		expectedErr error
+	}{
+		{
+			name:     "Merged and sorted",
+			lines:    []models.CartLine{{Item: "pen", Quantity: 3}, {Item: "cup", Quantity: 1}, {Item: "pen", Quantity: 7}},
+			expected: []models.CartLine{{Item: "cup", Quantity: 1}, {Item: "pen", Quantity: 10}},
+		},
+		{name: "Empty cart", lines: nil, expectedErr: internalErrors.InvalidCart},
+		{name: "No item", lines: []models.CartLine{{Quantity: 1}}, expectedErr: internalErrors.InvalidCart},
+		{name: "Zero quantity", lines: []models.CartLine{{Item: "pen"}}, expectedErr: internalErrors.InvalidCart},
+		{
+			name:        "Too many units after merge",
+			lines:       []models.CartLine{{Item: "pen", Quantity: maxLineQuantity}, {Item: "pen", Quantity: 1}},
+			expectedErr: internalErrors.InvalidCart,
+		},
+		{
+			name:        "Too many lines",
+			lines:       make([]models.CartLine, maxCartLines+1),
+			expectedErr: internalErrors.InvalidCart,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeShopStore{}
+			_, err := NewShopService(fake, &config.Config{InfoHistoryLimit: 10}).Checkout(context.Background(), 42, models.CheckoutRequest{Lines: tc.lines})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, fake.carts)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, [][]models.CartLine{tc.expected}, fake.carts)
+		})
+	}
+}
+
+func TestShopService_BuyItemIsSingleLineCheckout(t *testing.T) {
+	fake := &fakeShopStore{}
+	s := NewShopService(fake, &config.Config{InfoHistoryLimit: 10})
+
+	assert.NoError(t, s.BuyItem(context.Background(), 42, "cup", 1))
+	assert.NoError(t, s.BuyItem(context.Background(), 42, "pen", 5))
+	assert.ErrorIs(t, s.BuyItem(context.Background(), 42, "pen", 0), internalErrors.InvalidCart)
+	assert.Equal(t, [][]models.CartLine{{{Item: "cup", Quantity: 1}}, {{Item: "pen", Quantity: 5}}}, fake.carts)
+}
+
+func TestShopService_Gift(t *testing.T) {
+	fake := &fakeShopStore{}
+	s := NewShopService(fake, &config.Config{InfoHistoryLimit: 10})
+	three, zero := 3, 0
+
+	order, err := s.Gift(context.Background(), 42, models.GiftRequest{ToUser: "bob", Item: "cup"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", order.GiftTo)
+
+	_, err = s.Gift(context.Background(), 42, models.GiftRequest{ToUser: "bob", Item: "pen", Quantity: &three})
+	assert.NoError(t, err)
+
+	_, err = s.Gift(context.Background(), 42, models.GiftRequest{ToUser: "bob", Item: "pen", Quantity: &zero})
+	assert.ErrorIs(t, err, internalErrors.InvalidCart)
+
+	assert.Equal(t, []string{"bob", "bob"}, fake.gifts)
+	assert.Equal(t, [][]models.CartLine{{{Item: "cup", Quantity: 1}}, {{Item: "pen", Quantity: 3}}}, fake.carts)
+}
+
+func TestShopService_TransferItem(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         models.ItemTransferRequest
+		expectedErr error
+	}{
+		{name: "Valid", req: models.ItemTransferRequest{ToUser: "bob", Item: "cup", Quantity: 2}},
+		{name: "No recipient", req: models.ItemTransferRequest{Item: "cup", Quantity: 2}, expectedErr: internalErrors.InvalidTransfer},
+		{name: "No item", req: models.ItemTransferRequest{ToUser: "bob", Quantity: 2}, expectedErr: internalErrors.InvalidTransfer},
+		{name: "Zero quantity", req: models.ItemTransferRequest{ToUser: "bob", Item: "cup"}, expectedErr: internalErrors.InvalidTransfer},
+		{name: "Negative quantity", req: models.ItemTransferRequest{ToUser: "bob", Item: "cup", Quantity: -1}, expectedErr: internalErrors.InvalidTransfer},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeShopStore{}
+			_, err := NewShopService(fake, &config.Config{InfoHistoryLimit: 10}).TransferItem(context.Background(), 42, tc.req)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, fake.transfers)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []models.ItemTransferRequest{tc.req}, fake.transfers)
+		})
+	}
+}
+
+func TestShopService_SendCoinMemo(t *testing.T) {
+	tests := []struct {
+		name        string
+		memo        string
+		expected    string
+		expectedErr error
+	}{
+		{name: "No memo", memo: "", expected: ""},
+		{name: "Trimmed", memo: "  for the pizza \n", expected: "for the pizza"},
+		{name: "Line breaks", memo: "line one\r\nline two", expected: "line one  line two"},
+		{name: "Bidi override", memo: "invoice \u202egnp.exe", expected: "invoice gnp.exe"},
+		{name: "Multibyte at the limit", memo: strings.Repeat("ж", maxMemoLength), expected: strings.Repeat("ж", maxMemoLength)},
+		{name: "Too long", memo: strings.Repeat("a", maxMemoLength+1), expectedErr: internalErrors.InvalidMemo},
+		{name: "Not UTF-8", memo: "\xff", expectedErr: internalErrors.InvalidMemo},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeShopStore{}
+			err := NewShopService(fake, &config.Config{InfoHistoryLimit: 10}).
+				SendCoin(context.Background(), 42, models.SendCoinRequest{ToUser: "bob", Amount: 5, Memo: tc.memo})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, fake.sends)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []models.SendCoinRequest{{ToUser: "bob", Amount: 5, Memo: tc.expected}}, fake.sends)
+		})
+	}
+}
@@ -2,13 +2,16 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 	"testAlvtoShp/internal/config"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
+	"time"
 )
 
 const (
@@ -16,6 +19,36 @@ const (
 	inventoryTable = "inventory"
 	coinTxTable    = "coin_transactions"
 	itemsTable     = "items"
+	ordersTable    = "orders"
+
+	orderLinesTable       = "order_lines"
+	itemPriceHistoryTable = "item_price_history"
+	returnsTable          = "returns"
+	itemTransfersTable    = "inventory_transfers"
+	coinRequestsTable     = "coin_requests"
+
+	journalEntriesTable = "journal_entries"
+	postingsTable       = "postings"
+
+	sessionsTable      = "sessions"
+	refreshTokensTable = "refresh_tokens"
+	invitesTable       = "invites"
+	loginAttemptsTable = "login_attempts"
+
+	idempotencyKeysTable = "idempotency_keys"
+)
+
+const (
+	uniqueViolationCode      = "23505"
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+)
+
+// maxTxAttempts bounds how often inTx runs a transaction that Postgres keeps
+// aborting, txRetryBackoff grows linearly with every attempt.
+const (
+	maxTxAttempts  = 5
+	txRetryBackoff = 10 * time.Millisecond
 )
 
 func NewDbConn(ctx context.Context, cfg *config.Config) (*sqlx.DB, error) {
@@ -31,6 +64,60 @@ func NewDbConn(ctx context.Context, cfg *config.Config) (*sqlx.DB, error) {
 	return conn, nil
 }
 
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode
+}
+
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == serializationFailureCode || pqErr.Code == deadlockDetectedCode
+}
+
+// inTx runs fn in a transaction and commits it. A transaction aborted by a
+// serialization failure or a deadlock is run again from the start, fn must
+// therefore not keep state between calls.
+func inTx(ctx context.Context, db *sqlx.DB, fn func(tx *sql.Tx) error) error {
+	log := logger.LoggerFromContext(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, fn)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			return err
+		}
+
+		log.Warnw("retrying transaction", zap.Int("attempt", attempt), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * txRetryBackoff):
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *sqlx.DB, fn func(tx *sql.Tx) error) error {
+	log := logger.LoggerFromContext(ctx)
+	tx, err := db.Begin()
+	if err != nil {
+		log.Errorw("failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func ShutDown(ctx context.Context, db *sqlx.DB) error {
 	log := logger.LoggerFromContext(ctx)
 	log.Debugw("shutting down database")
@@ -45,25 +132,114 @@ func ShutDown(ctx context.Context, db *sqlx.DB) error {
 
 type Store struct {
 	Auth
+	Session
+	LoginAttempts
+	Admin
 	Shop
+	Catalog
+	Idempotency
+	Returns
+	Ledger
+	CoinRequests
 }
 
 func NewStore(db *sqlx.DB) *Store {
 	return &Store{
-		Auth: NewAuthStore(db),
-		Shop: NewShopStore(db),
+		Auth:          NewAuthStore(db),
+		Session:       NewSessionStore(db),
+		LoginAttempts: NewLoginAttemptStore(db),
+		Admin:         NewAdminStore(db),
+		Shop:          NewShopStore(db),
+		Catalog:       NewCatalogStore(db),
+		Idempotency:   NewIdempotencyStore(db),
+		Returns:       NewReturnStore(db),
+		Ledger:        NewLedgerStore(db),
+		CoinRequests:  NewCoinRequestStore(db),
 	}
 }
 
 type Auth interface {
 	GetUserByUsername(ctx context.Context, username string) (int, error)
-	CreateUser(ctx context.Context, req models.AuthRequest) (int, error)
-	CheckPassword(ctx context.Context, req models.AuthRequest) (bool, error)
-	CheckUserId(ctx context.Context, userId int) (bool, error)
+	CreateUser(ctx context.Context, req models.AuthRequest, welcomeBonus int) (int, error)
+	GetPasswordHash(ctx context.Context, username string) (int, string, error)
+	UpdatePasswordHash(ctx context.Context, userId int, hash string) error
+	CreateInvite(ctx context.Context, codeHash string, createdBy int, expiresAt time.Time) error
+	CreateUserWithInvite(ctx context.Context, req models.AuthRequest, codeHash string, welcomeBonus int) (int, error)
+	GetUserRole(ctx context.Context, userId int) (string, error)
+}
+
+type Session interface {
+	CreateSession(ctx context.Context, userId int, sessionId, tokenHash string, expiresAt time.Time) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldTokenId int64, sessionId, tokenHash string, expiresAt time.Time) (bool, error)
+	RevokeSession(ctx context.Context, sessionId string) error
+	RevokeUserSessions(ctx context.Context, userId int) error
+	GetSessionRole(ctx context.Context, userId int, sessionId string) (string, error)
+}
+
+// LoginAttempts counts failed logins per key. RecordLoginFailure starts the
+// count over when the previous failure happened before windowStart.
+type LoginAttempts interface {
+	GetLoginAttempt(ctx context.Context, key string) (models.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, key string, at, windowStart time.Time) (int, error)
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, key string) error
+}
+
+type Admin interface {
+	GetUser(ctx context.Context, username string) (models.AdminUserResponse, error)
+	SetUserRole(ctx context.Context, userId int, role string) error
+	AdjustBalance(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustmentResponse, error)
 }
 
 type Shop interface {
-	GetUserInfo(ctx context.Context, userId int) (models.InfoResponse, error)
-	BuyItem(ctx context.Context, userId int, item string) error
+	GetUserInfo(ctx context.Context, userId int, historyLimit int) (models.InfoResponse, error)
+	Checkout(ctx context.Context, userId int, cart []models.CartLine) (models.Order, error)
+	Gift(ctx context.Context, userId int, toUser string, cart []models.CartLine) (models.Order, error)
 	SendCoin(ctx context.Context, userId int, req models.SendCoinRequest) error
+	TransferItem(ctx context.Context, userId int, req models.ItemTransferRequest) (models.ItemTransfer, error)
+	GetOrders(ctx context.Context, filter models.OrdersFilter) ([]models.Order, error)
+	GetTransactions(ctx context.Context, filter models.TransactionsFilter) ([]models.Transaction, error)
+}
+
+type Catalog interface {
+	GetItems(ctx context.Context) ([]models.CatalogItem, error)
+	CreateItem(ctx context.Context, item models.CatalogItem, actorId int) error
+	SetItemPrice(ctx context.Context, name string, price, actorId int) (models.CatalogItem, error)
+	SetItemLimits(ctx context.Context, name string, stock, perUserLimit *int) (models.CatalogItem, error)
+	RetireItem(ctx context.Context, name string) error
+	GetPriceHistory(ctx context.Context, name string) ([]models.ItemPrice, error)
+}
+
+// Returns keeps the requests to return an order. A pending return is either
+// approved, which refunds it, or rejected.
+type Returns interface {
+	CreateReturn(ctx context.Context, userId int, orderId int64, reason string, orderedAfter time.Time) (models.Return, error)
+	GetReturns(ctx context.Context, filter models.ReturnsFilter) ([]models.Return, error)
+	ApproveReturn(ctx context.Context, returnId int64, actorId int, note string) (models.Return, error)
+	RejectReturn(ctx context.Context, returnId int64, actorId int, note string) (models.Return, error)
+}
+
+// CoinRequests keeps the requests of users to be paid by another user. Only
+// the payer decides a pending request, accepting it transfers the coins.
+type CoinRequests interface {
+	CreateCoinRequest(ctx context.Context, userId int, payer string, amount int, memo string, expiresAt time.Time) (models.CoinRequest, error)
+	AcceptCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error)
+	DeclineCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error)
+}
+
+// Ledger compares the balances cached in users.coins with the postings of
+// the journal. FixBalance resets the cached balance of a user to the ledger.
+type Ledger interface {
+	GetBalanceDrift(ctx context.Context) (int, []models.BalanceDrift, error)
+	FixBalance(ctx context.Context, userId int) (models.BalanceDrift, error)
+}
+
+// Idempotency keeps the outcomes of user requests made with an
+// Idempotency-Key, scoped per user.
+type Idempotency interface {
+	ReserveIdempotencyKey(ctx context.Context, rec models.IdempotencyRecord, staleBefore time.Time) (models.IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userId int, key string, statusCode int, response []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userId int, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
 }
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+)
+
+type AdminStore struct {
+	Db *sqlx.DB
+}
+
+func NewAdminStore(db *sqlx.DB) *AdminStore {
+	return &AdminStore{
+		Db: db,
+	}
+}
+
+func (r *AdminStore) GetUser(ctx context.Context, username string) (models.AdminUserResponse, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select id, username, role, coalesce(coins, 0) as coins from %s where username = $1
+`, usersTable)
+
+	var user models.AdminUserResponse
+	if err := r.Db.Get(&user, query, username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AdminUserResponse{}, internalErrors.UserNotFound
+		}
+		log.Errorw("failed to get user", zap.Error(err))
+		return models.AdminUserResponse{}, err
+	}
+
+	return user, nil
+}
+
+func (r *AdminStore) SetUserRole(ctx context.Context, userId int, role string) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	update %s set role = $1 where id = $2
+`, usersTable)
+
+	res, err := r.Db.Exec(query, role, userId)
+	if err != nil {
+		log.Errorw("failed to set user role", zap.Error(err))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return internalErrors.UserNotFound
+	}
+
+	return nil
+}
+
+// AdjustBalance applies an admin balance change and writes its ledger row in
+// one transaction. An idempotency key repeated by the same actor returns the
+// row written by the first call instead of applying the change again.
+func (r *AdminStore) AdjustBalance(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustmentResponse, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var res models.BalanceAdjustmentResponse
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		firstQuery := fmt.Sprintf(`
+	select coalesce(coins, 0) from %s where id = $1 for update
+`, usersTable)
+
+		var coins int
+		if err := tx.QueryRow(firstQuery, adj.UserID).Scan(&coins); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return internalErrors.UserNotFound
+			}
+			log.Errorw("failed to scan row", zap.Error(err))
+			return err
+		}
+
+		balance := adj.Amount
+		switch adj.Kind {
+		case models.CoinTxGrant:
+			balance = coins + adj.Amount
+		case models.CoinTxDeduction:
+			balance = coins - adj.Amount
+		}
+
+		if balance < 0 {
+			return internalErrors.NoMoney
+		}
+
+		// the user is the receiver of a credit and the sender of a debit, the
+		// other side stays empty because it is the system
+		delta := balance - coins
+		var senderId, receiverId sql.NullInt64
+		if delta < 0 {
+			senderId = sql.NullInt64{Int64: int64(adj.UserID), Valid: true}
+		} else {
+			receiverId = sql.NullInt64{Int64: int64(adj.UserID), Valid: true}
+		}
+
+		secondQuery := fmt.Sprintf(`
+	insert into %s (sender_id, receiver_id, amount, kind, reason, actor_id, idempotency_key, balance_after)
+	values ($1, $2, $3, $4, $5, $6, $7, $8) returning id
+`, coinTxTable)
+
+		var id int64
+		err := tx.QueryRow(secondQuery, senderId, receiverId, abs(delta), adj.Kind, adj.Reason, adj.ActorID, adj.IdempotencyKey, balance).Scan(&id)
+		if err != nil {
+			if !isUniqueViolation(err) {
+				log.Errorw("failed to insert coin transaction", zap.Error(err))
+			}
+			return err
+		}
+
+		// on the system side of the entry is the treasury, an adjustment to the
+		// current balance moves nothing
+		if delta != 0 {
+			err = postEntry(tx, journalEntry{
+				kind:     adj.Kind,
+				coinTxId: id,
+				postings: []posting{accountPosting(ledgerTreasury, -delta), userPosting(adj.UserID, delta)},
+			})
+			if err != nil {
+				log.Errorw("failed to update balance", zap.Error(err))
+				return err
+			}
+		}
+
+		res = models.BalanceAdjustmentResponse{
+			TransactionID: id,
+			UserID:        adj.UserID,
+			Kind:          adj.Kind,
+			Delta:         delta,
+			Balance:       balance,
+		}
+		return nil
+	})
+	if isUniqueViolation(err) {
+		return r.replayAdjustment(ctx, adj)
+	}
+	if err != nil {
+		return models.BalanceAdjustmentResponse{}, err
+	}
+
+	return res, nil
+}
+
+func (r *AdminStore) replayAdjustment(ctx context.Context, adj models.BalanceAdjustment) (models.BalanceAdjustmentResponse, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select id, coalesce(receiver_id, sender_id) as user_id, kind,
+		case when sender_id is null then amount else -amount end as delta, balance_after
+	from %s where actor_id = $1 and idempotency_key = $2
+`, coinTxTable)
+
+	var stored models.BalanceAdjustmentResponse
+	if err := r.Db.Get(&stored, query, adj.ActorID, adj.IdempotencyKey); err != nil {
+		log.Errorw("failed to get coin transaction by idempotency key", zap.Error(err))
+		return models.BalanceAdjustmentResponse{}, err
+	}
+
+	if stored.UserID != adj.UserID || stored.Kind != adj.Kind || !sameAdjustment(stored, adj) {
+		return models.BalanceAdjustmentResponse{}, internalErrors.IdempotencyKeyReused
+	}
+
+	log.Infow("replayed balance adjustment", "transaction_id", stored.TransactionID, "idempotency_key", adj.IdempotencyKey)
+
+	return stored, nil
+}
+
+func sameAdjustment(stored models.BalanceAdjustmentResponse, adj models.BalanceAdjustment) bool {
+	switch adj.Kind {
+	case models.CoinTxGrant:
+		return stored.Delta == adj.Amount
+	case models.CoinTxDeduction:
+		return stored.Delta == -adj.Amount
+	}
+	return stored.Balance == adj.Amount
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
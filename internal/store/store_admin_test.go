@@ -0,0 +1,248 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+)
+
+func TestAdminStore_GetUser(t *testing.T) {
+	query := regexp.QuoteMeta("select id, username, role, coalesce(coins, 0) as coins from " + usersTable + " where username = $1")
+
+	tests := []struct {
+		name         string
+		setupMock    func(mock sqlmock.Sqlmock)
+		expectedUser models.AdminUserResponse
+		expectedErr  error
+	}{
+		{
+			name: "Success",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id", "username", "role", "coins"}).AddRow(7, "test", "auditor", 1000)
+				mock.ExpectQuery(query).WithArgs("test").WillReturnRows(rows)
+			},
+			expectedUser: models.AdminUserResponse{ID: 7, Username: "test", Role: "auditor", Coins: 1000},
+		},
+		{
+			name: "Not found",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("test").WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: internalErrors.UserNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			adminStore := NewAdminStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			user, err := adminStore.GetUser(context.Background(), "test")
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedUser, user)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAdminStore_SetUserRole(t *testing.T) {
+	query := regexp.QuoteMeta("update " + usersTable + " set role = $1 where id = $2")
+
+	tests := []struct {
+		name        string
+		affected    int64
+		expectedErr error
+	}{
+		{name: "Success", affected: 1},
+		{name: "Not found", affected: 0, expectedErr: internalErrors.UserNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			adminStore := NewAdminStore(sqlx.NewDb(db, "sqlmock"))
+
+			mock.ExpectExec(query).WithArgs("admin", 7).WillReturnResult(sqlmock.NewResult(0, tc.affected))
+
+			err = adminStore.SetUserRole(context.Background(), 7, "admin")
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAdminStore_AdjustBalance(t *testing.T) {
+	lockUser := regexp.QuoteMeta("select coalesce(coins, 0) from " + usersTable + " where id = $1 for update")
+	insertTx := regexp.QuoteMeta("insert into " + coinTxTable + " (sender_id, receiver_id, amount, kind, reason, actor_id, idempotency_key, balance_after)")
+	selectByKey := regexp.QuoteMeta("from " + coinTxTable + " where actor_id = $1 and idempotency_key = $2")
+
+	tests := []struct {
+		name        string
+		adj         models.BalanceAdjustment
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.BalanceAdjustmentResponse
+		expectedErr error
+	}{
+		{
+			name: "Grant",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxGrant, Amount: 100, Reason: "prize", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(50))
+				mock.ExpectQuery(insertTx).
+					WithArgs(sql.NullInt64{}, sql.NullInt64{Int64: 7, Valid: true}, 100, models.CoinTxGrant, "prize", 1, "key", 150).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				expectEntry(mock, 11, journalEntry{
+					kind:     models.CoinTxGrant,
+					coinTxId: 3,
+					postings: []posting{accountPosting(ledgerTreasury, -100), userPosting(7, 100)},
+				})
+				mock.ExpectCommit()
+			},
+			expected: models.BalanceAdjustmentResponse{TransactionID: 3, UserID: 7, Kind: models.CoinTxGrant, Delta: 100, Balance: 150},
+		},
+		{
+			name: "Set lower balance",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxAdjustment, Amount: 20, Reason: "fix", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(50))
+				mock.ExpectQuery(insertTx).
+					WithArgs(sql.NullInt64{Int64: 7, Valid: true}, sql.NullInt64{}, 30, models.CoinTxAdjustment, "fix", 1, "key", 20).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+				expectEntry(mock, 12, journalEntry{
+					kind:     models.CoinTxAdjustment,
+					coinTxId: 4,
+					postings: []posting{accountPosting(ledgerTreasury, 30), userPosting(7, -30)},
+				})
+				mock.ExpectCommit()
+			},
+			expected: models.BalanceAdjustmentResponse{TransactionID: 4, UserID: 7, Kind: models.CoinTxAdjustment, Delta: -30, Balance: 20},
+		},
+		{
+			name: "Set the current balance",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxAdjustment, Amount: 50, Reason: "fix", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(50))
+				mock.ExpectQuery(insertTx).
+					WithArgs(sql.NullInt64{}, sql.NullInt64{Int64: 7, Valid: true}, 0, models.CoinTxAdjustment, "fix", 1, "key", 50).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				mock.ExpectCommit()
+			},
+			expected: models.BalanceAdjustmentResponse{TransactionID: 5, UserID: 7, Kind: models.CoinTxAdjustment, Delta: 0, Balance: 50},
+		},
+		{
+			name: "Retry after a deadlock",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxGrant, Amount: 100, Reason: "prize", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnError(&pq.Error{Code: deadlockDetectedCode})
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(50))
+				mock.ExpectQuery(insertTx).
+					WithArgs(sql.NullInt64{}, sql.NullInt64{Int64: 7, Valid: true}, 100, models.CoinTxGrant, "prize", 1, "key", 150).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				expectEntry(mock, 11, journalEntry{
+					kind:     models.CoinTxGrant,
+					coinTxId: 3,
+					postings: []posting{accountPosting(ledgerTreasury, -100), userPosting(7, 100)},
+				})
+				mock.ExpectCommit()
+			},
+			expected: models.BalanceAdjustmentResponse{TransactionID: 3, UserID: 7, Kind: models.CoinTxGrant, Delta: 100, Balance: 150},
+		},
+		{
+			name: "Deduct more than balance",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxDeduction, Amount: 100, Reason: "chargeback", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(50))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.NoMoney,
+		},
+		{
+			name: "Unknown user",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxGrant, Amount: 100, Reason: "prize", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.UserNotFound,
+		},
+		{
+			name: "Replayed key",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxGrant, Amount: 100, Reason: "prize", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(150))
+				mock.ExpectQuery(insertTx).WillReturnError(&pq.Error{Code: uniqueViolationCode})
+				mock.ExpectRollback()
+				mock.ExpectQuery(selectByKey).WithArgs(1, "key").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "delta", "balance_after"}).
+						AddRow(3, 7, models.CoinTxGrant, 100, 150))
+			},
+			expected: models.BalanceAdjustmentResponse{TransactionID: 3, UserID: 7, Kind: models.CoinTxGrant, Delta: 100, Balance: 150},
+		},
+		{
+			name: "Key reused for another amount",
+			adj:  models.BalanceAdjustment{UserID: 7, ActorID: 1, Kind: models.CoinTxGrant, Amount: 200, Reason: "prize", IdempotencyKey: "key"},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(7).WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(150))
+				mock.ExpectQuery(insertTx).WillReturnError(&pq.Error{Code: uniqueViolationCode})
+				mock.ExpectRollback()
+				mock.ExpectQuery(selectByKey).WithArgs(1, "key").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "kind", "delta", "balance_after"}).
+						AddRow(3, 7, models.CoinTxGrant, 100, 150))
+			},
+			expectedErr: internalErrors.IdempotencyKeyReused,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			adminStore := NewAdminStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			res, err := adminStore.AdjustBalance(context.Background(), tc.adj)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, res)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
+	"time"
 )
 
 type AuthStore struct {
@@ -41,66 +43,205 @@ func (r *AuthStore) GetUserByUsername(ctx context.Context, username string) (int
 	return id, nil
 }
 
-func (r *AuthStore) CreateUser(ctx context.Context, req models.AuthRequest) (int, error) {
+// CreateUser inserts the user together with the welcome bonus, which is paid
+// out of the treasury. The bonus ledger row is skipped when the bonus is zero.
+func (r *AuthStore) CreateUser(ctx context.Context, req models.AuthRequest, welcomeBonus int) (int, error) {
 	log := logger.LoggerFromContext(ctx)
+	tx, err := r.Db.Begin()
+	if err != nil {
+		log.Errorw("failed to begin transaction", zap.Error(err))
+		return 0, err
+	}
+
 	query := fmt.Sprintf(`
 
-insert into %s (username, password_hash) values ($1, $2) returning id;`, usersTable)
+insert into %s (username, password_hash, coins) values ($1, $2, $3) returning id;`, usersTable)
 
 	var id int
 
-	err := r.Db.QueryRow(query, req.Username, req.Password).Scan(&id)
+	// the balance starts at zero, the bonus is posted to it
+	err = tx.QueryRow(query, req.Username, req.Password, 0).Scan(&id)
 
 	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		if isUniqueViolation(err) {
+			return 0, internalErrors.UserExists
+		}
 		log.Errorw("error with inserting row", zap.Error(err))
 		return 0, err
 	}
 
-	return id, nil
+	if err = insertWelcomeBonus(tx, id, welcomeBonus); err != nil {
+		log.Errorw("failed to insert welcome bonus", zap.Error(err))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-func (r *AuthStore) CheckPassword(ctx context.Context, req models.AuthRequest) (bool, error) {
+func insertWelcomeBonus(tx *sql.Tx, userId, welcomeBonus int) error {
+	if welcomeBonus <= 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(`
+	insert into %s (receiver_id, amount, kind, reason, balance_after) values ($1, $2, $3, $4, $2) returning id
+`, coinTxTable)
+
+	var coinTxId int64
+	if err := tx.QueryRow(query, userId, welcomeBonus, models.CoinTxBonus, "welcome bonus").Scan(&coinTxId); err != nil {
+		return err
+	}
+
+	return postEntry(tx, journalEntry{
+		kind:     models.CoinTxBonus,
+		coinTxId: coinTxId,
+		postings: []posting{accountPosting(ledgerTreasury, -welcomeBonus), userPosting(userId, welcomeBonus)},
+	})
+}
+
+func (r *AuthStore) CreateInvite(ctx context.Context, codeHash string, createdBy int, expiresAt time.Time) error {
 	log := logger.LoggerFromContext(ctx)
 	query := fmt.Sprintf(`
+	insert into %s (code_hash, created_by, expires_at) values ($1, $2, $3)
+`, invitesTable)
 
-select password_hash from %s where username = $1
-`, usersTable)
+	if _, err := r.Db.Exec(query, codeHash, createdBy, expiresAt); err != nil {
+		log.Errorw("error with inserting invite", zap.Error(err))
+		return err
+	}
 
-	var password string
+	return nil
+}
+
+// CreateUserWithInvite creates the user and burns the invite in one
+// transaction, so an invite can never be redeemed twice.
+func (r *AuthStore) CreateUserWithInvite(ctx context.Context, req models.AuthRequest, codeHash string, welcomeBonus int) (int, error) {
+	log := logger.LoggerFromContext(ctx)
+	tx, err := r.Db.Begin()
+	if err != nil {
+		log.Errorw("failed to begin transaction", zap.Error(err))
+		return 0, err
+	}
 
-	row := r.Db.QueryRow(query, req.Username)
+	firstQuery := fmt.Sprintf(`
+	select id from %s where code_hash = $1 and used_at is null and expires_at > now() for update
+`, invitesTable)
 
-	if err := row.Scan(&password); err != nil {
+	var inviteId int
+	if err = tx.QueryRow(firstQuery, codeHash).Scan(&inviteId); err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
 		if errors.Is(err, sql.ErrNoRows) {
-			return false, nil
+			return 0, internalErrors.InvalidInvite
 		}
+		log.Errorw("failed to scan row", zap.Error(err))
+		return 0, err
+	}
 
-		log.Errorw("error with scanning row", zap.Error(err))
-		return false, err
+	secondQuery := fmt.Sprintf(`
+	insert into %s (username, password_hash, coins) values ($1, $2, $3) returning id
+`, usersTable)
+
+	var id int
+	// the balance starts at zero, the bonus is posted to it
+	if err = tx.QueryRow(secondQuery, req.Username, req.Password, 0).Scan(&id); err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		if isUniqueViolation(err) {
+			return 0, internalErrors.UserExists
+		}
+		log.Errorw("error with inserting row", zap.Error(err))
+		return 0, err
 	}
 
-	if password != req.Password {
-		return false, nil
+	if err = insertWelcomeBonus(tx, id, welcomeBonus); err != nil {
+		log.Errorw("failed to insert welcome bonus", zap.Error(err))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return 0, err
 	}
 
-	return true, nil
+	thirdQuery := fmt.Sprintf(`
+	update %s set used_by = $1, used_at = now() where id = $2
+`, invitesTable)
+
+	if _, err = tx.Exec(thirdQuery, id, inviteId); err != nil {
+		log.Errorw("failed to redeem invite", zap.Error(err))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-func (r *AuthStore) CheckUserId(ctx context.Context, userId int) (bool, error) {
+func (r *AuthStore) GetPasswordHash(ctx context.Context, username string) (int, string, error) {
 	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
 
+select id, password_hash from %s where username = $1
+`, usersTable)
+
+	var (
+		id   int
+		hash string
+	)
+
+	row := r.Db.QueryRow(query, username)
+
+	if err := row.Scan(&id, &hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", nil
+		}
+
+		log.Errorw("error with scanning row", zap.Error(err))
+		return 0, "", err
+	}
+
+	return id, hash, nil
+}
+
+func (r *AuthStore) UpdatePasswordHash(ctx context.Context, userId int, hash string) error {
+	log := logger.LoggerFromContext(ctx)
 	query := fmt.Sprintf(`
-	select count(*) from %s where id = $1
+	update %s set password_hash = $1 where id = $2
 `, usersTable)
 
-	var count int
+	if _, err := r.Db.Exec(query, hash, userId); err != nil {
+		log.Errorw("error with updating password hash", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
 
-	row := r.Db.QueryRow(query, userId)
+func (r *AuthStore) GetUserRole(ctx context.Context, userId int) (string, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select role from %s where id = $1
+`, usersTable)
 
-	if err := row.Scan(&count); err != nil {
+	var role string
+	if err := r.Db.QueryRow(query, userId).Scan(&role); err != nil {
 		log.Errorw("error with scanning row", zap.Error(err))
-		return false, err
+		return "", err
 	}
 
-	return count == 1, nil
+	return role, nil
 }
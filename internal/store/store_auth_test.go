@@ -2,14 +2,17 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 
+	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/models"
 )
 
@@ -78,12 +81,16 @@ func TestAuthStore_GetUserByUsername(t *testing.T) {
 }
 
 func TestAuthStore_CreateUser(t *testing.T) {
+	insertUser := regexp.MustCompile(`(?i)^.*insert into\s+` + usersTable + `\s+\(username, password_hash, coins\)\s+values\s+\(\$1, \$2, \$3\)\s+returning id;.*$`).String()
+	insertBonus := regexp.QuoteMeta("insert into " + coinTxTable + " (receiver_id, amount, kind, reason, balance_after) values ($1, $2, $3, $4, $2) returning id")
+
 	tests := []struct {
-		name       string
-		req        models.AuthRequest
-		setupMock  func(mock sqlmock.Sqlmock)
-		expectedID int
-		expectErr  bool
+		name         string
+		req          models.AuthRequest
+		welcomeBonus int
+		setupMock    func(mock sqlmock.Sqlmock)
+		expectedID   int
+		expectErr    bool
 	}{
 		{
 			name: "Success",
@@ -91,10 +98,35 @@ func TestAuthStore_CreateUser(t *testing.T) {
 				Username: "testuser",
 				Password: "hashedpass",
 			},
+			welcomeBonus: 1000,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).AddRow(42)
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertUser).WithArgs("testuser", "hashedpass", 0).WillReturnRows(rows)
+				mock.ExpectQuery(insertBonus).WithArgs(42, 1000, models.CoinTxBonus, "welcome bonus").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				expectEntry(mock, 8, journalEntry{
+					kind:     models.CoinTxBonus,
+					coinTxId: 5,
+					postings: []posting{accountPosting(ledgerTreasury, -1000), userPosting(42, 1000)},
+				})
+				mock.ExpectCommit()
+			},
+			expectedID: 42,
+			expectErr:  false,
+		},
+		{
+			name: "Success without bonus",
+			req: models.AuthRequest{
+				Username: "testuser",
+				Password: "hashedpass",
+			},
+			welcomeBonus: 0,
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*insert into\s+` + usersTable + `\s+\(username, password_hash\)\s+values\s+\(\$1, \$2\)\s+returning id;.*$`)
 				rows := sqlmock.NewRows([]string{"id"}).AddRow(42)
-				mock.ExpectQuery(queryRegex.String()).WithArgs("testuser", "hashedpass").WillReturnRows(rows)
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertUser).WithArgs("testuser", "hashedpass", 0).WillReturnRows(rows)
+				mock.ExpectCommit()
 			},
 			expectedID: 42,
 			expectErr:  false,
@@ -105,9 +137,28 @@ func TestAuthStore_CreateUser(t *testing.T) {
 				Username: "testuser",
 				Password: "hashedpass",
 			},
+			welcomeBonus: 1000,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertUser).WithArgs("testuser", "hashedpass", 0).WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			expectedID: 0,
+			expectErr:  true,
+		},
+		{
+			name: "Bonus error rolls back the user",
+			req: models.AuthRequest{
+				Username: "testuser",
+				Password: "hashedpass",
+			},
+			welcomeBonus: 1000,
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*insert into\s+` + usersTable + `\s+\(username, password_hash\)\s+values\s+\(\$1, \$2\)\s+returning id;.*$`)
-				mock.ExpectQuery(queryRegex.String()).WithArgs("testuser", "hashedpass").WillReturnError(errors.New("insert error"))
+				rows := sqlmock.NewRows([]string{"id"}).AddRow(42)
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertUser).WithArgs("testuser", "hashedpass", 0).WillReturnRows(rows)
+				mock.ExpectQuery(insertBonus).WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
 			},
 			expectedID: 0,
 			expectErr:  true,
@@ -124,7 +175,7 @@ func TestAuthStore_CreateUser(t *testing.T) {
 
 			tc.setupMock(mock)
 
-			id, err := authStore.CreateUser(context.Background(), tc.req)
+			id, err := authStore.CreateUser(context.Background(), tc.req, tc.welcomeBonus)
 			if tc.expectErr {
 				assert.Error(t, err)
 			} else {
@@ -136,68 +187,102 @@ func TestAuthStore_CreateUser(t *testing.T) {
 	}
 }
 
-func TestAuthStore_CheckPassword(t *testing.T) {
+func TestAuthStore_GetPasswordHash(t *testing.T) {
 	tests := []struct {
-		name          string
-		req           models.AuthRequest
-		setupMock     func(mock sqlmock.Sqlmock)
-		expectedMatch bool
-		expectErr     bool
+		name         string
+		username     string
+		setupMock    func(mock sqlmock.Sqlmock)
+		expectedID   int
+		expectedHash string
+		expectErr    bool
 	}{
 		{
-			name: "Password match",
-			req: models.AuthRequest{
-				Username: "testuser",
-				Password: "hashedpass",
-			},
+			name:     "Success",
+			username: "testuser",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*select password_hash from\s+` + usersTable + `\s+where username = \$1.*$`)
-				rows := sqlmock.NewRows([]string{"password_hash"}).AddRow("hashedpass")
+				queryRegex := regexp.MustCompile(`(?i)^.*select id, password_hash from\s+` + usersTable + `\s+where username = \$1.*$`)
+				rows := sqlmock.NewRows([]string{"id", "password_hash"}).AddRow(42, "hashedpass")
 				mock.ExpectQuery(queryRegex.String()).WithArgs("testuser").WillReturnRows(rows)
 			},
-			expectedMatch: true,
-			expectErr:     false,
+			expectedID:   42,
+			expectedHash: "hashedpass",
+			expectErr:    false,
 		},
 		{
-			name: "Password mismatch",
-			req: models.AuthRequest{
-				Username: "testuser",
-				Password: "hashedpass",
-			},
+			name:     "No rows found",
+			username: "testuser",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*select password_hash from\s+` + usersTable + `\s+where username = \$1.*$`)
-				rows := sqlmock.NewRows([]string{"password_hash"}).AddRow("differentpass")
+				queryRegex := regexp.MustCompile(`(?i)^.*select id, password_hash from\s+` + usersTable + `\s+where username = \$1.*$`)
+				rows := sqlmock.NewRows([]string{"id", "password_hash"})
 				mock.ExpectQuery(queryRegex.String()).WithArgs("testuser").WillReturnRows(rows)
 			},
-			expectedMatch: false,
-			expectErr:     false,
+			expectedID:   0,
+			expectedHash: "",
+			expectErr:    false,
 		},
 		{
-			name: "No rows found",
-			req: models.AuthRequest{
-				Username: "testuser",
-				Password: "hashedpass",
-			},
+			name:     "Query error",
+			username: "testuser",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*select password_hash from\s+` + usersTable + `\s+where username = \$1.*$`)
-				rows := sqlmock.NewRows([]string{"password_hash"})
-				mock.ExpectQuery(queryRegex.String()).WithArgs("testuser").WillReturnRows(rows)
+				queryRegex := regexp.MustCompile(`(?i)^.*select id, password_hash from\s+` + usersTable + `\s+where username = \$1.*$`)
+				mock.ExpectQuery(queryRegex.String()).WithArgs("testuser").WillReturnError(errors.New("db error"))
 			},
-			expectedMatch: false,
-			expectErr:     false,
+			expectedID:   0,
+			expectedHash: "",
+			expectErr:    true,
 		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			sqlxDB := sqlx.NewDb(db, "sqlmock")
+			authStore := NewAuthStore(sqlxDB)
+
+			tc.setupMock(mock)
+
+			id, hash, err := authStore.GetPasswordHash(context.Background(), tc.username)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedID, id)
+			assert.Equal(t, tc.expectedHash, hash)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestAuthStore_UpdatePasswordHash(t *testing.T) {
+	tests := []struct {
+		name      string
+		userId    int
+		hash      string
+		setupMock func(mock sqlmock.Sqlmock)
+		expectErr bool
+	}{
 		{
-			name: "Query error",
-			req: models.AuthRequest{
-				Username: "testuser",
-				Password: "hashedpass",
+			name:   "Success",
+			userId: 42,
+			hash:   "newhash",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				queryRegex := regexp.MustCompile(`(?i)^.*update\s+` + usersTable + `\s+set password_hash = \$1 where id = \$2.*$`)
+				mock.ExpectExec(queryRegex.String()).WithArgs("newhash", 42).WillReturnResult(sqlmock.NewResult(0, 1))
 			},
+			expectErr: false,
+		},
+		{
+			name:   "Update error",
+			userId: 42,
+			hash:   "newhash",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*select password_hash from\s+` + usersTable + `\s+where username = \$1.*$`)
-				mock.ExpectQuery(queryRegex.String()).WithArgs("testuser").WillReturnError(errors.New("db error"))
+				queryRegex := regexp.MustCompile(`(?i)^.*update\s+` + usersTable + `\s+set password_hash = \$1 where id = \$2.*$`)
+				mock.ExpectExec(queryRegex.String()).WithArgs("newhash", 42).WillReturnError(errors.New("db error"))
 			},
-			expectedMatch: false,
-			expectErr:     true,
+			expectErr: true,
 		},
 	}
 
@@ -211,57 +296,69 @@ func TestAuthStore_CheckPassword(t *testing.T) {
 
 			tc.setupMock(mock)
 
-			match, err := authStore.CheckPassword(context.Background(), tc.req)
+			err = authStore.UpdatePasswordHash(context.Background(), tc.userId, tc.hash)
 			if tc.expectErr {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
 			}
-			assert.Equal(t, tc.expectedMatch, match)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
-func TestAuthStore_CheckUserId(t *testing.T) {
+func TestAuthStore_CreateUserWithInvite(t *testing.T) {
+	selectInvite := regexp.QuoteMeta("select id from " + invitesTable + " where code_hash = $1 and used_at is null and expires_at > now() for update")
+	insertUser := regexp.QuoteMeta("insert into " + usersTable + " (username, password_hash, coins) values ($1, $2, $3) returning id")
+	insertBonus := regexp.QuoteMeta("insert into " + coinTxTable + " (receiver_id, amount, kind, reason, balance_after)")
+	redeemInvite := regexp.QuoteMeta("update " + invitesTable + " set used_by = $1, used_at = now() where id = $2")
+
 	tests := []struct {
-		name         string
-		userId       int
-		setupMock    func(mock sqlmock.Sqlmock)
-		expectedBool bool
-		expectErr    bool
+		name        string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expectedID  int
+		expectedErr error
 	}{
 		{
-			name:   "User exists",
-			userId: 42,
+			name: "Success",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*select count\(\*\) from\s+` + usersTable + `\s+where id = \$1.*$`)
-				rows := sqlmock.NewRows([]string{"count"}).AddRow(1)
-				mock.ExpectQuery(queryRegex.String()).WithArgs(42).WillReturnRows(rows)
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectInvite).WithArgs("codehash").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				mock.ExpectQuery(insertUser).WithArgs("newuser", "hash", 0).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+				mock.ExpectQuery(insertBonus).WithArgs(42, 1000, models.CoinTxBonus, "welcome bonus").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				expectEntry(mock, 8, journalEntry{
+					kind:     models.CoinTxBonus,
+					coinTxId: 5,
+					postings: []posting{accountPosting(ledgerTreasury, -1000), userPosting(42, 1000)},
+				})
+				mock.ExpectExec(redeemInvite).WithArgs(42, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
 			},
-			expectedBool: true,
-			expectErr:    false,
+			expectedID: 42,
 		},
 		{
-			name:   "User does not exist",
-			userId: 42,
+			name: "Used or expired invite",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*select count\(\*\) from\s+` + usersTable + `\s+where id = \$1.*$`)
-				rows := sqlmock.NewRows([]string{"count"}).AddRow(0)
-				mock.ExpectQuery(queryRegex.String()).WithArgs(42).WillReturnRows(rows)
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectInvite).WithArgs("codehash").WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
 			},
-			expectedBool: false,
-			expectErr:    false,
+			expectedErr: internalErrors.InvalidInvite,
 		},
 		{
-			name:   "Query error",
-			userId: 42,
+			name: "Username taken",
 			setupMock: func(mock sqlmock.Sqlmock) {
-				queryRegex := regexp.MustCompile(`(?i)^.*select count\(\*\) from\s+` + usersTable + `\s+where id = \$1.*$`)
-				mock.ExpectQuery(queryRegex.String()).WithArgs(42).WillReturnError(errors.New("db error"))
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectInvite).WithArgs("codehash").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				mock.ExpectQuery(insertUser).WithArgs("newuser", "hash", 0).
+					WillReturnError(&pq.Error{Code: uniqueViolationCode})
+				mock.ExpectRollback()
 			},
-			expectedBool: false,
-			expectErr:    true,
+			expectedErr: internalErrors.UserExists,
 		},
 	}
 
@@ -275,13 +372,13 @@ func TestAuthStore_CheckUserId(t *testing.T) {
 
 			tc.setupMock(mock)
 
-			ok, err := authStore.CheckUserId(context.Background(), tc.userId)
-			if tc.expectErr {
-				assert.Error(t, err)
+			id, err := authStore.CreateUserWithInvite(context.Background(), models.AuthRequest{Username: "newuser", Password: "hash"}, "codehash", 1000)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
 			} else {
 				assert.NoError(t, err)
 			}
-			assert.Equal(t, tc.expectedBool, ok)
+			assert.Equal(t, tc.expectedID, id)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+)
+
+// catalogItemColumns are the items columns of models.CatalogItem, in the
+// order of catalogItemFields. A sold out item is not available.
+const catalogItemColumns = `name, price, description, available and coalesce(stock, 1) > 0 as available,
+	coalesce(image_url, '') as image_url, stock, per_user_limit`
+
+func catalogItemFields(item *models.CatalogItem) []interface{} {
+	return []interface{}{&item.Name, &item.Price, &item.Description, &item.Available, &item.ImageURL, &item.Stock, &item.PerUserLimit}
+}
+
+type CatalogStore struct {
+	Db *sqlx.DB
+}
+
+func NewCatalogStore(db *sqlx.DB) *CatalogStore {
+	return &CatalogStore{
+		Db: db,
+	}
+}
+
+func (r *CatalogStore) GetItems(ctx context.Context) ([]models.CatalogItem, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select %s from %s where retired_at is null order by id
+`, catalogItemColumns, itemsTable)
+
+	items := []models.CatalogItem{}
+	if err := r.Db.Select(&items, query); err != nil {
+		log.Errorw("failed to get items", zap.Error(err))
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// CreateItem adds an item to the catalog together with its first price.
+func (r *CatalogStore) CreateItem(ctx context.Context, item models.CatalogItem, actorId int) error {
+	log := logger.LoggerFromContext(ctx)
+
+	return inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		firstQuery := fmt.Sprintf(`
+	insert into %s (name, price, description, image_url, available, stock, per_user_limit)
+	values ($1, $2, $3, nullif($4, ''), $5, $6, $7) returning id
+`, itemsTable)
+
+		var itemId int
+		err := tx.QueryRow(firstQuery, item.Name, item.Price, item.Description, item.ImageURL, item.Available,
+			item.Stock, item.PerUserLimit).Scan(&itemId)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return internalErrors.ItemExists
+			}
+			log.Errorw("failed to create item", zap.Error(err))
+			return err
+		}
+
+		return insertPrice(tx, itemId, item.Price, actorId)
+	})
+}
+
+// SetItemPrice changes the price of an item on sale and records it in the
+// price history, the previous price stays there.
+func (r *CatalogStore) SetItemPrice(ctx context.Context, name string, price, actorId int) (models.CatalogItem, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var item models.CatalogItem
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		firstQuery := fmt.Sprintf(`
+	update %s set price = $1 where name = $2 and retired_at is null
+	returning id, %s
+`, itemsTable, catalogItemColumns)
+
+		var itemId int
+		err := tx.QueryRow(firstQuery, price, name).
+			Scan(append([]interface{}{&itemId}, catalogItemFields(&item)...)...)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return internalErrors.ItemNotFound
+			}
+			log.Errorw("failed to set item price", zap.Error(err))
+			return err
+		}
+
+		return insertPrice(tx, itemId, price, actorId)
+	})
+	if err != nil {
+		return models.CatalogItem{}, err
+	}
+
+	return item, nil
+}
+
+// SetItemLimits replaces the stock and the per user limit of an item on sale,
+// nil lifts a limit.
+func (r *CatalogStore) SetItemLimits(ctx context.Context, name string, stock, perUserLimit *int) (models.CatalogItem, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	update %s set stock = $1, per_user_limit = $2 where name = $3 and retired_at is null
+	returning %s
+`, itemsTable, catalogItemColumns)
+
+	var item models.CatalogItem
+	if err := r.Db.QueryRow(query, stock, perUserLimit, name).Scan(catalogItemFields(&item)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.CatalogItem{}, internalErrors.ItemNotFound
+		}
+		log.Errorw("failed to set item limits", zap.Error(err))
+		return models.CatalogItem{}, err
+	}
+
+	return item, nil
+}
+
+func insertPrice(tx *sql.Tx, itemId, price, actorId int) error {
+	query := fmt.Sprintf(`
+	insert into %s (item_id, price, changed_by) values ($1, $2, $3)
+`, itemPriceHistoryTable)
+
+	_, err := tx.Exec(query, itemId, price, actorId)
+	return err
+}
+
+// RetireItem takes an item off sale. Inventories and orders keep referring to
+// it by name, so the row itself stays.
+func (r *CatalogStore) RetireItem(ctx context.Context, name string) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	update %s set retired_at = now() where name = $1 and retired_at is null
+`, itemsTable)
+
+	res, err := r.Db.Exec(query, name)
+	if err != nil {
+		log.Errorw("failed to retire item", zap.Error(err))
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return internalErrors.ItemNotFound
+	}
+
+	return nil
+}
+
+// GetPriceHistory lists the prices of an item, retired or not, oldest first.
+func (r *CatalogStore) GetPriceHistory(ctx context.Context, name string) ([]models.ItemPrice, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select h.price, h.changed_by, h.changed_at
+	from %s h
+	join %s i on i.id = h.item_id
+	where i.name = $1
+	order by h.changed_at, h.id
+`, itemPriceHistoryTable, itemsTable)
+
+	prices := []models.ItemPrice{}
+	if err := r.Db.Select(&prices, query, name); err != nil {
+		log.Errorw("failed to get price history", zap.Error(err))
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return nil, internalErrors.ItemNotFound
+	}
+
+	return prices, nil
+}
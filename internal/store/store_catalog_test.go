@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+)
+
+var catalogColumnsPattern = regexp.QuoteMeta("name, price, description, available and coalesce(stock, 1) > 0 as available, " +
+	"coalesce(image_url, '') as image_url, stock, per_user_limit")
+
+var catalogColumnNames = []string{"name", "price", "description", "available", "image_url", "stock", "per_user_limit"}
+
+func TestCatalogStore_GetItems(t *testing.T) {
+	query := `(?i)^select ` + catalogColumnsPattern + ` from ` + itemsTable + ` where retired_at is null order by id$`
+	stock := 0
+
+	tests := []struct {
+		name      string
+		setupMock func(mock sqlmock.Sqlmock)
+		expected  []models.CatalogItem
+		expectErr bool
+	}{
+		{
+			name: "Items in catalog order",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(catalogColumnNames).
+					AddRow("t-shirt", 80, "Футболка", true, "", nil, nil).
+					AddRow("cup", 20, "", false, "https://example.com/cup.png", 0, nil))
+			},
+			expected: []models.CatalogItem{
+				{Name: "t-shirt", Price: 80, Description: "Футболка", Available: true},
+				{Name: "cup", Price: 20, ImageURL: "https://example.com/cup.png", Stock: &stock},
+			},
+		},
+		{
+			name: "Empty catalog",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(catalogColumnNames))
+			},
+			expected: []models.CatalogItem{},
+		},
+		{
+			name: "Database error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnError(errors.New("db error"))
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.setupMock(mock)
+
+			items, err := NewCatalogStore(sqlx.NewDb(db, "sqlmock")).GetItems(context.Background())
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, items)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCatalogStore_CreateItem(t *testing.T) {
+	insertItem := `(?i)^insert into ` + itemsTable + ` \(name, price, description, image_url, available, stock, per_user_limit\) ` +
+		`values \(\$1, \$2, \$3, nullif\(\$4, ''\), \$5, \$6, \$7\) returning id$`
+	limit := 2
+	insertPrice := `(?i)^insert into ` + itemPriceHistoryTable + ` \(item_id, price, changed_by\) values \(\$1, \$2, \$3\)$`
+	item := models.CatalogItem{Name: "sticker", Price: 5, Description: "Стикер", Available: true, PerUserLimit: &limit}
+
+	tests := []struct {
+		name        string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Item with its first price",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertItem).WithArgs("sticker", 5, "Стикер", "", true, nil, &limit).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+				mock.ExpectExec(insertPrice).WithArgs(11, 5, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Name taken",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(insertItem).WillReturnError(&pq.Error{Code: uniqueViolationCode})
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ItemExists,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.setupMock(mock)
+
+			err = NewCatalogStore(sqlx.NewDb(db, "sqlmock")).CreateItem(context.Background(), item, 1)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCatalogStore_SetItemPrice(t *testing.T) {
+	update := `(?i)^update ` + itemsTable + ` set price = \$1 where name = \$2 and retired_at is null returning id, ` + catalogColumnsPattern + `$`
+	insertPrice := `(?i)^insert into ` + itemPriceHistoryTable + ` \(item_id, price, changed_by\) values \(\$1, \$2, \$3\)$`
+
+	tests := []struct {
+		name        string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.CatalogItem
+		expectedErr error
+	}{
+		{
+			name: "New price is recorded",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WithArgs(25, "cup").
+					WillReturnRows(sqlmock.NewRows(append([]string{"id"}, catalogColumnNames...)).
+						AddRow(2, "cup", 25, "Кружка", true, "", nil, nil))
+				mock.ExpectExec(insertPrice).WithArgs(2, 25, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+			expected: models.CatalogItem{Name: "cup", Price: 25, Description: "Кружка", Available: true},
+		},
+		{
+			name: "Unknown or retired item",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(update).WithArgs(25, "cup").WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ItemNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.setupMock(mock)
+
+			item, err := NewCatalogStore(sqlx.NewDb(db, "sqlmock")).SetItemPrice(context.Background(), "cup", 25, 1)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, item)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCatalogStore_SetItemLimits(t *testing.T) {
+	query := `(?i)^update ` + itemsTable + ` set stock = \$1, per_user_limit = \$2 where name = \$3 and retired_at is null returning ` +
+		catalogColumnsPattern + `$`
+	stock := 50
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	catalogStore := NewCatalogStore(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectQuery(query).WithArgs(&stock, nil, "pink-hoody").
+		WillReturnRows(sqlmock.NewRows(catalogColumnNames).AddRow("pink-hoody", 500, "", true, "", 50, nil))
+	mock.ExpectQuery(query).WithArgs(nil, nil, "mug").WillReturnError(sql.ErrNoRows)
+
+	item, err := catalogStore.SetItemLimits(context.Background(), "pink-hoody", &stock, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CatalogItem{Name: "pink-hoody", Price: 500, Available: true, Stock: &stock}, item)
+
+	_, err = catalogStore.SetItemLimits(context.Background(), "mug", nil, nil)
+	assert.ErrorIs(t, err, internalErrors.ItemNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatalogStore_RetireItem(t *testing.T) {
+	query := `(?i)^update ` + itemsTable + ` set retired_at = now\(\) where name = \$1 and retired_at is null$`
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	catalogStore := NewCatalogStore(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectExec(query).WithArgs("cup").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("cup").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, catalogStore.RetireItem(context.Background(), "cup"))
+	assert.ErrorIs(t, catalogStore.RetireItem(context.Background(), "cup"), internalErrors.ItemNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatalogStore_GetPriceHistory(t *testing.T) {
+	query := `(?i)^select h.price, h.changed_by, h.changed_at from ` + itemPriceHistoryTable + ` h join ` + itemsTable +
+		` i on i.id = h.item_id where i.name = \$1 order by h.changed_at, h.id$`
+	seeded := time.Unix(0, 0).UTC()
+	changed := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	admin := 1
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	catalogStore := NewCatalogStore(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectQuery(query).WithArgs("cup").WillReturnRows(sqlmock.NewRows([]string{"price", "changed_by", "changed_at"}).
+		AddRow(20, nil, seeded).
+		AddRow(25, 1, changed))
+	mock.ExpectQuery(query).WithArgs("mug").WillReturnRows(sqlmock.NewRows([]string{"price", "changed_by", "changed_at"}))
+
+	prices, err := catalogStore.GetPriceHistory(context.Background(), "cup")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ItemPrice{
+		{Price: 20, ChangedAt: seeded},
+		{Price: 25, ChangedBy: &admin, ChangedAt: changed},
+	}, prices)
+
+	_, err = catalogStore.GetPriceHistory(context.Background(), "mug")
+	assert.ErrorIs(t, err, internalErrors.ItemNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
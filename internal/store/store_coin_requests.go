@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"time"
+)
+
+// coinRequestColumns selects a coin request from coinRequestTables with the
+// names of both users. A pending request past its expiry is shown as expired.
+var coinRequestColumns = fmt.Sprintf(`cr.id, rq.username AS requester, p.username AS payer, cr.amount,
+	COALESCE(cr.memo, '') AS memo,
+	CASE WHEN cr.status = '%s' AND cr.expires_at <= now() THEN '%s' ELSE cr.status END AS status,
+	cr.created_at, cr.expires_at, cr.decided_at`, models.CoinRequestPending, models.CoinRequestExpired)
+
+var coinRequestTables = fmt.Sprintf(`%s cr
+	JOIN %s rq ON rq.id = cr.requester_id
+	JOIN %[2]s p ON p.id = cr.payer_id`, coinRequestsTable, usersTable)
+
+type CoinRequestStore struct {
+	Db *sqlx.DB
+}
+
+func NewCoinRequestStore(db *sqlx.DB) *CoinRequestStore {
+	return &CoinRequestStore{
+		Db: db,
+	}
+}
+
+// CreateCoinRequest asks payer to pay amount coins to the user until
+// expiresAt. An unknown payer is sql.ErrNoRows, as in SendCoin.
+func (r *CoinRequestStore) CreateCoinRequest(ctx context.Context, userId int, payer string, amount int, memo string, expiresAt time.Time) (models.CoinRequest, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var req models.CoinRequest
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		firstQuery := fmt.Sprintf(`
+	select id from %s where username = $1
+`, usersTable)
+
+		var payerId int
+		if err := tx.QueryRow(firstQuery, payer).Scan(&payerId); err != nil {
+			log.Errorw("failed to scan row", zap.Error(err))
+			return err
+		}
+		if payerId == userId {
+			return internalErrors.CoinRequestToSelf
+		}
+
+		secondQuery := fmt.Sprintf(`
+	insert into %s (requester_id, payer_id, amount, memo, expires_at) values ($1, $2, $3, nullif($4, ''), $5)
+	returning id
+`, coinRequestsTable)
+
+		var id int64
+		if err := tx.QueryRow(secondQuery, userId, payerId, amount, memo, expiresAt).Scan(&id); err != nil {
+			log.Errorw("failed to create coin request", zap.Error(err))
+			return err
+		}
+
+		var err error
+		req, err = getCoinRequest(tx, id)
+		return err
+	})
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	return req, nil
+}
+
+// AcceptCoinRequest pays a pending request made to the user with a transfer
+// that carries the memo of the request.
+func (r *CoinRequestStore) AcceptCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var req models.CoinRequest
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		requesterId, amount, memo, err := lockPendingCoinRequest(tx, requestId, userId)
+		if err != nil {
+			return err
+		}
+
+		coinTxId, err := transferCoins(ctx, tx, userId, requesterId, amount, memo)
+		if err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+	update %s set status = $1, decided_at = now(), coin_tx_id = $2 where id = $3
+`, coinRequestsTable)
+
+		if _, err = tx.Exec(query, models.CoinRequestAccepted, coinTxId, requestId); err != nil {
+			log.Errorw("failed to accept coin request", zap.Error(err))
+			return err
+		}
+
+		req, err = getCoinRequest(tx, requestId)
+		return err
+	})
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	return req, nil
+}
+
+func (r *CoinRequestStore) DeclineCoinRequest(ctx context.Context, userId int, requestId int64) (models.CoinRequest, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var req models.CoinRequest
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		if _, _, _, err := lockPendingCoinRequest(tx, requestId, userId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+	update %s set status = $1, decided_at = now() where id = $2
+`, coinRequestsTable)
+
+		if _, err := tx.Exec(query, models.CoinRequestDeclined, requestId); err != nil {
+			log.Errorw("failed to decline coin request", zap.Error(err))
+			return err
+		}
+
+		var err error
+		req, err = getCoinRequest(tx, requestId)
+		return err
+	})
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	return req, nil
+}
+
+// lockPendingCoinRequest locks a request made to payerId and returns who
+// asked for how much. Requests made to somebody else are not found.
+func lockPendingCoinRequest(tx *sql.Tx, requestId int64, payerId int) (int, int, string, error) {
+	query := fmt.Sprintf(`
+	select requester_id, amount, coalesce(memo, ''), status, expires_at <= now()
+	from %s where id = $1 and payer_id = $2 for update
+`, coinRequestsTable)
+
+	var (
+		requesterId, amount int
+		memo, status        string
+		expired             bool
+	)
+	if err := tx.QueryRow(query, requestId, payerId).Scan(&requesterId, &amount, &memo, &status, &expired); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, "", internalErrors.CoinRequestNotFound
+		}
+		return 0, 0, "", err
+	}
+	if status != models.CoinRequestPending {
+		return 0, 0, "", internalErrors.CoinRequestDecided
+	}
+	if expired {
+		return 0, 0, "", internalErrors.CoinRequestExpired
+	}
+
+	return requesterId, amount, memo, nil
+}
+
+func getCoinRequest(tx *sql.Tx, requestId int64) (models.CoinRequest, error) {
+	query := fmt.Sprintf(`
+	select %s from %s where cr.id = $1
+`, coinRequestColumns, coinRequestTables)
+
+	var req models.CoinRequest
+	err := tx.QueryRow(query, requestId).Scan(&req.ID, &req.Requester, &req.Payer, &req.Amount,
+		&req.Memo, &req.Status, &req.CreatedAt, &req.ExpiresAt, &req.DecidedAt)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+
+	return req, nil
+}
+
+// pendingCoinRequests returns the newest pending requests of the user that
+// have not expired, column is either requester_id or payer_id.
+func pendingCoinRequests(db *sqlx.DB, column string, userId, limit int) ([]models.CoinRequest, error) {
+	query := fmt.Sprintf(`
+	select %s from %s
+	where cr.%s = $1 and cr.status = $2 and cr.expires_at > now()
+	order by cr.created_at desc, cr.id desc
+	limit $3
+`, coinRequestColumns, coinRequestTables, column)
+
+	requests := make([]models.CoinRequest, 0)
+	if err := db.Select(&requests, query, userId, models.CoinRequestPending, limit); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
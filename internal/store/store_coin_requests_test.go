@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+)
+
+var coinRequestColumnNames = []string{"id", "requester", "payer", "amount", "memo", "status", "created_at", "expires_at", "decided_at"}
+
+var (
+	requestCreatedAt = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	requestExpiresAt = requestCreatedAt.Add(72 * time.Hour)
+	requestDecidedAt = requestCreatedAt.Add(time.Hour)
+)
+
+func expectGetCoinRequest(mock sqlmock.Sqlmock, id int64, status string, decidedAt interface{}) {
+	mock.ExpectQuery(regexp.QuoteMeta("where cr.id = $1")).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(coinRequestColumnNames).
+			AddRow(id, "bob", "alice", 40, "pizza", status, requestCreatedAt, requestExpiresAt, decidedAt))
+}
+
+func TestCoinRequestStore_CreateCoinRequest(t *testing.T) {
+	selectPayer := regexp.QuoteMeta("select id from " + usersTable + " where username = $1")
+	insertRequest := regexp.QuoteMeta("insert into " + coinRequestsTable + " (requester_id, payer_id, amount, memo, expires_at) values ($1, $2, $3, nullif($4, ''), $5) returning id")
+
+	tests := []struct {
+		name        string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.CoinRequest
+		expectedErr error
+	}{
+		{
+			name: "Success",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectPayer).WithArgs("alice").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+				mock.ExpectQuery(insertRequest).WithArgs(7, 42, 40, "pizza", requestExpiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+				expectGetCoinRequest(mock, 5, models.CoinRequestPending, nil)
+				mock.ExpectCommit()
+			},
+			expected: models.CoinRequest{
+				ID: 5, Requester: "bob", Payer: "alice", Amount: 40, Memo: "pizza", Status: models.CoinRequestPending,
+				CreatedAt: requestCreatedAt, ExpiresAt: requestExpiresAt,
+			},
+		},
+		{
+			name: "Unknown payer",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectPayer).WithArgs("alice").WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: sql.ErrNoRows,
+		},
+		{
+			name: "Request from yourself",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(selectPayer).WithArgs("alice").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.CoinRequestToSelf,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			requestStore := NewCoinRequestStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			req, err := requestStore.CreateCoinRequest(context.Background(), 7, "alice", 40, "pizza", requestExpiresAt)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, req)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCoinRequestStore_DecideCoinRequest(t *testing.T) {
+	lockRequest := regexp.QuoteMeta("from " + coinRequestsTable + " where id = $1 and payer_id = $2 for update")
+	lockedRequest := func(status string, expired bool) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"requester_id", "amount", "memo", "status", "expired"}).AddRow(7, 40, "pizza", status, expired)
+	}
+	lockBalances := `(?i)^select id, coins from\s+` + usersTable + `\s+where id in \(\$1, \$2\) order by id for update$`
+	insertTransfer := regexp.QuoteMeta("insert into " + coinTxTable + "(sender_id, receiver_id, amount, memo) values($1, $2, $3, nullif($4, '')) returning id")
+	acceptRequest := regexp.QuoteMeta("update " + coinRequestsTable + " set status = $1, decided_at = now(), coin_tx_id = $2 where id = $3")
+	declineRequest := regexp.QuoteMeta("update " + coinRequestsTable + " set status = $1, decided_at = now() where id = $2")
+
+	tests := []struct {
+		name        string
+		accept      bool
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.CoinRequest
+		expectedErr error
+	}{
+		{
+			name:   "Accept transfers the coins",
+			accept: true,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockRequest).WithArgs(int64(5), 42).WillReturnRows(lockedRequest(models.CoinRequestPending, false))
+				mock.ExpectQuery(lockBalances).WithArgs(42, 7).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "coins"}).AddRow(7, 0).AddRow(42, 100))
+				mock.ExpectQuery(insertTransfer).WithArgs(42, 7, 40, "pizza").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+				expectEntry(mock, 40, journalEntry{
+					kind:     models.CoinTxTransfer,
+					coinTxId: 31,
+					postings: []posting{userPosting(42, -40), userPosting(7, 40)},
+				})
+				mock.ExpectExec(acceptRequest).WithArgs(models.CoinRequestAccepted, int64(31), int64(5)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectGetCoinRequest(mock, 5, models.CoinRequestAccepted, requestDecidedAt)
+				mock.ExpectCommit()
+			},
+			expected: models.CoinRequest{
+				ID: 5, Requester: "bob", Payer: "alice", Amount: 40, Memo: "pizza", Status: models.CoinRequestAccepted,
+				CreatedAt: requestCreatedAt, ExpiresAt: requestExpiresAt, DecidedAt: &requestDecidedAt,
+			},
+		},
+		{
+			name:   "Accept without enough coins",
+			accept: true,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockRequest).WithArgs(int64(5), 42).WillReturnRows(lockedRequest(models.CoinRequestPending, false))
+				mock.ExpectQuery(lockBalances).WithArgs(42, 7).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "coins"}).AddRow(7, 0).AddRow(42, 10))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.NoMoney,
+		},
+		{
+			name:   "Accept an expired request",
+			accept: true,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockRequest).WithArgs(int64(5), 42).WillReturnRows(lockedRequest(models.CoinRequestPending, true))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.CoinRequestExpired,
+		},
+		{
+			name: "Decline",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockRequest).WithArgs(int64(5), 42).WillReturnRows(lockedRequest(models.CoinRequestPending, false))
+				mock.ExpectExec(declineRequest).WithArgs(models.CoinRequestDeclined, int64(5)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				expectGetCoinRequest(mock, 5, models.CoinRequestDeclined, requestDecidedAt)
+				mock.ExpectCommit()
+			},
+			expected: models.CoinRequest{
+				ID: 5, Requester: "bob", Payer: "alice", Amount: 40, Memo: "pizza", Status: models.CoinRequestDeclined,
+				CreatedAt: requestCreatedAt, ExpiresAt: requestExpiresAt, DecidedAt: &requestDecidedAt,
+			},
+		},
+		{
+			name: "Decline a decided request",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockRequest).WithArgs(int64(5), 42).WillReturnRows(lockedRequest(models.CoinRequestAccepted, false))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.CoinRequestDecided,
+		},
+		{
+			name: "Request made to somebody else",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockRequest).WithArgs(int64(5), 42).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.CoinRequestNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			requestStore := NewCoinRequestStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			decide := requestStore.DeclineCoinRequest
+			if tc.accept {
+				decide = requestStore.AcceptCoinRequest
+			}
+			req, err := decide(context.Background(), 42, 5)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, req)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
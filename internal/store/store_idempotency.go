@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"time"
+)
+
+const idempotencyRecordColumns = `user_id, key, fingerprint, status_code, response, created_at, expires_at`
+
+type IdempotencyStore struct {
+	Db *sqlx.DB
+}
+
+func NewIdempotencyStore(db *sqlx.DB) *IdempotencyStore {
+	return &IdempotencyStore{
+		Db: db,
+	}
+}
+
+// ReserveIdempotencyKey inserts rec as a running request. A row left by an
+// expired key or by a request that started before staleBefore and never
+// finished is taken over. Otherwise the existing row is returned and the
+// reservation fails.
+func (r *IdempotencyStore) ReserveIdempotencyKey(ctx context.Context, rec models.IdempotencyRecord, staleBefore time.Time) (models.IdempotencyRecord, bool, error) {
+	log := logger.LoggerFromContext(ctx)
+	reserveQuery := fmt.Sprintf(`
+	insert into %[1]s (user_id, key, fingerprint, created_at, expires_at) values ($1, $2, $3, $4, $5)
+	on conflict (user_id, key) do update set
+		fingerprint = excluded.fingerprint, status_code = null, response = null,
+		created_at = excluded.created_at, expires_at = excluded.expires_at
+	where %[1]s.expires_at <= excluded.created_at or (%[1]s.status_code is null and %[1]s.created_at < $6)
+	returning %[2]s
+`, idempotencyKeysTable, idempotencyRecordColumns)
+	getQuery := fmt.Sprintf(`
+	select %s from %s where user_id = $1 and key = $2
+`, idempotencyRecordColumns, idempotencyKeysTable)
+
+	// the row found by the conflict may be deleted before it is read, the
+	// insert is then tried again
+	for attempt := 1; ; attempt++ {
+		var reserved models.IdempotencyRecord
+		err := r.Db.Get(&reserved, reserveQuery, rec.UserID, rec.Key, rec.Fingerprint, rec.CreatedAt, rec.ExpiresAt, staleBefore)
+		if err == nil {
+			return reserved, true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Errorw("failed to reserve idempotency key", zap.Error(err))
+			return models.IdempotencyRecord{}, false, err
+		}
+
+		var stored models.IdempotencyRecord
+		err = r.Db.Get(&stored, getQuery, rec.UserID, rec.Key)
+		if err == nil {
+			return stored, false, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) || attempt == maxTxAttempts {
+			log.Errorw("failed to get idempotency key", zap.Error(err))
+			return models.IdempotencyRecord{}, false, err
+		}
+	}
+}
+
+func (r *IdempotencyStore) CompleteIdempotencyKey(ctx context.Context, userId int, key string, statusCode int, response []byte) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	update %s set status_code = $3, response = $4 where user_id = $1 and key = $2
+`, idempotencyKeysTable)
+
+	if _, err := r.Db.Exec(query, userId, key, statusCode, response); err != nil {
+		log.Errorw("failed to complete idempotency key", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey drops the reservation of a request that failed
+// without an outcome worth replaying, so that a retry runs it again.
+func (r *IdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, userId int, key string) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	delete from %s where user_id = $1 and key = $2 and status_code is null
+`, idempotencyKeysTable)
+
+	if _, err := r.Db.Exec(query, userId, key); err != nil {
+		log.Errorw("failed to release idempotency key", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *IdempotencyStore) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	delete from %s where expires_at <= $1
+`, idempotencyKeysTable)
+
+	res, err := r.Db.Exec(query, now)
+	if err != nil {
+		log.Errorw("failed to delete expired idempotency keys", zap.Error(err))
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
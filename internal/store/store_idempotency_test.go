@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	"testAlvtoShp/internal/models"
+)
+
+func TestIdempotencyStore_ReserveIdempotencyKey(t *testing.T) {
+	now := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	staleBefore := now.Add(-time.Minute)
+	rec := models.IdempotencyRecord{UserID: 42, Key: "k1", Fingerprint: "fp", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	columns := []string{"user_id", "key", "fingerprint", "status_code", "response", "created_at", "expires_at"}
+	reserve := regexp.QuoteMeta("insert into " + idempotencyKeysTable + " (user_id, key, fingerprint, created_at, expires_at) values ($1, $2, $3, $4, $5)")
+	get := regexp.QuoteMeta("select " + idempotencyRecordColumns + " from " + idempotencyKeysTable + " where user_id = $1 and key = $2")
+
+	tests := []struct {
+		name             string
+		setupMock        func(mock sqlmock.Sqlmock)
+		expected         models.IdempotencyRecord
+		expectedReserved bool
+		expectErr        bool
+	}{
+		{
+			name: "New key",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(reserve).WithArgs(42, "k1", "fp", now, rec.ExpiresAt, staleBefore).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(42, "k1", "fp", nil, nil, now, rec.ExpiresAt))
+			},
+			expected:         rec,
+			expectedReserved: true,
+		},
+		{
+			name: "Key already used",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(reserve).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(get).WithArgs(42, "k1").
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(42, "k1", "other", 201, []byte(`{}`), now, rec.ExpiresAt))
+			},
+			expected: models.IdempotencyRecord{
+				UserID: 42, Key: "k1", Fingerprint: "other",
+				StatusCode: sql.NullInt32{Int32: 201, Valid: true}, Response: []byte(`{}`),
+				CreatedAt: now, ExpiresAt: rec.ExpiresAt,
+			},
+		},
+		{
+			name: "Key deleted between insert and read",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(reserve).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(get).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(reserve).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(42, "k1", "fp", nil, nil, now, rec.ExpiresAt))
+			},
+			expected:         rec,
+			expectedReserved: true,
+		},
+		{
+			name: "Query error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(reserve).WillReturnError(errors.New("db error"))
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			idempotencyStore := NewIdempotencyStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			stored, reserved, err := idempotencyStore.ReserveIdempotencyKey(context.Background(), rec, staleBefore)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, stored)
+			assert.Equal(t, tc.expectedReserved, reserved)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestIdempotencyStore_CompleteAndRelease(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	idempotencyStore := NewIdempotencyStore(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectExec(regexp.QuoteMeta("update "+idempotencyKeysTable+" set status_code = $3, response = $4 where user_id = $1 and key = $2")).
+		WithArgs(42, "k1", 201, []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("delete from "+idempotencyKeysTable+" where user_id = $1 and key = $2 and status_code is null")).
+		WithArgs(42, "k2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("delete from " + idempotencyKeysTable + " where expires_at <= $1")).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	assert.NoError(t, idempotencyStore.CompleteIdempotencyKey(context.Background(), 42, "k1", 201, []byte(`{}`)))
+	assert.NoError(t, idempotencyStore.ReleaseIdempotencyKey(context.Background(), 42, "k2"))
+	deleted, err := idempotencyStore.DeleteExpiredIdempotencyKeys(context.Background(), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+)
+
+// System accounts of the ledger. The treasury issues coins to users and takes
+// them back, shop_revenue receives what users spend on merch.
+const (
+	ledgerTreasury    = "treasury"
+	ledgerShopRevenue = "shop_revenue"
+)
+
+// entryPurchase is the kind of the journal entry of an order, the other kinds
+// are the kinds of the coin transaction the entry belongs to.
+const entryPurchase = "purchase"
+
+// posting moves amount coins to a user or to a system account, a negative
+// amount moves them away. Exactly one of userId and account is set.
+type posting struct {
+	userId  int
+	account string
+	amount  int
+}
+
+func userPosting(userId, amount int) posting {
+	return posting{userId: userId, amount: amount}
+}
+
+func accountPosting(account string, amount int) posting {
+	return posting{account: account, amount: amount}
+}
+
+// journalEntry is one movement of coins. coinTxId and orderId link it to the
+// row the movement is shown as, zero when there is none.
+type journalEntry struct {
+	kind     string
+	coinTxId int64
+	orderId  int64
+	postings []posting
+}
+
+// postEntry writes a balanced journal entry and applies its user postings to
+// users.coins, which is only ever changed here and by FixBalance. The caller
+// has to lock the users beforehand when it checks their balance.
+func postEntry(tx *sql.Tx, entry journalEntry) error {
+	sum := 0
+	for _, p := range entry.postings {
+		if p.amount == 0 || (p.userId == 0) == (p.account == "") {
+			return fmt.Errorf("%w: invalid posting", internalErrors.UnbalancedEntry)
+		}
+		sum += p.amount
+	}
+	if len(entry.postings) < 2 || sum != 0 {
+		return fmt.Errorf("%w: %s sums to %d", internalErrors.UnbalancedEntry, entry.kind, sum)
+	}
+
+	entryQuery := fmt.Sprintf(`
+	insert into %s (kind, coin_tx_id, order_id) values ($1, nullif($2, 0), nullif($3, 0)) returning id
+`, journalEntriesTable)
+
+	var entryId int64
+	if err := tx.QueryRow(entryQuery, entry.kind, entry.coinTxId, entry.orderId).Scan(&entryId); err != nil {
+		return err
+	}
+
+	postingQuery := fmt.Sprintf(`
+	insert into %s (entry_id, user_id, account, amount) values ($1, nullif($2, 0), nullif($3, ''), $4)
+`, postingsTable)
+
+	balanceQuery := fmt.Sprintf(`
+	update %s set coins = coins + $1 where id = $2
+`, usersTable)
+
+	for _, p := range entry.postings {
+		if _, err := tx.Exec(postingQuery, entryId, p.userId, p.account, p.amount); err != nil {
+			return err
+		}
+		if p.userId == 0 {
+			continue
+		}
+		if _, err := tx.Exec(balanceQuery, p.amount, p.userId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type LedgerStore struct {
+	Db *sqlx.DB
+}
+
+func NewLedgerStore(db *sqlx.DB) *LedgerStore {
+	return &LedgerStore{
+		Db: db,
+	}
+}
+
+// GetBalanceDrift returns how many users there are and the ones whose
+// users.coins differs from the sum of their postings. Both sides are read in
+// one statement, and postEntry changes them in one transaction, so a running
+// transfer does not show up as drift.
+func (r *LedgerStore) GetBalanceDrift(ctx context.Context) (int, []models.BalanceDrift, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	countQuery := fmt.Sprintf(`
+	select count(*) from %s
+`, usersTable)
+
+	var checked int
+	if err := r.Db.Get(&checked, countQuery); err != nil {
+		log.Errorw("failed to count users", zap.Error(err))
+		return 0, nil, err
+	}
+
+	driftQuery := fmt.Sprintf(`
+	select u.id as user_id, u.username, u.coins, coalesce(p.amount, 0) as expected
+	from %s u
+	left join (select user_id, sum(amount) as amount from %s where user_id is not null group by user_id) p on p.user_id = u.id
+	where u.coins <> coalesce(p.amount, 0)
+	order by u.id
+`, usersTable, postingsTable)
+
+	drifted := []models.BalanceDrift{}
+	if err := r.Db.Select(&drifted, driftQuery); err != nil {
+		log.Errorw("failed to get balance drift", zap.Error(err))
+		return 0, nil, err
+	}
+	for i := range drifted {
+		drifted[i].Drift = drifted[i].Coins - drifted[i].Expected
+	}
+
+	return checked, drifted, nil
+}
+
+// FixBalance sets users.coins of the user to the sum of the postings of the
+// user. The user row is locked before the postings are summed, so a
+// transfer that is still running is counted by neither side. A balance that
+// no longer drifts is returned unchanged with Fixed unset.
+func (r *LedgerStore) FixBalance(ctx context.Context, userId int) (models.BalanceDrift, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var drift models.BalanceDrift
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		drift = models.BalanceDrift{UserID: userId}
+
+		firstQuery := fmt.Sprintf(`
+	select username, coins from %s where id = $1 for update
+`, usersTable)
+
+		if err := tx.QueryRow(firstQuery, userId).Scan(&drift.Username, &drift.Coins); err != nil {
+			log.Errorw("failed to lock user", zap.Error(err))
+			return err
+		}
+
+		secondQuery := fmt.Sprintf(`
+	select coalesce(sum(amount), 0) from %s where user_id = $1
+`, postingsTable)
+
+		if err := tx.QueryRow(secondQuery, userId).Scan(&drift.Expected); err != nil {
+			log.Errorw("failed to sum postings", zap.Error(err))
+			return err
+		}
+		drift.Drift = drift.Coins - drift.Expected
+
+		if drift.Drift == 0 {
+			return nil
+		}
+		if drift.Expected < 0 {
+			return fmt.Errorf("%w: %d", internalErrors.NegativeLedgerBalance, drift.Expected)
+		}
+
+		thirdQuery := fmt.Sprintf(`
+	update %s set coins = $1 where id = $2
+`, usersTable)
+
+		if _, err := tx.Exec(thirdQuery, drift.Expected, userId); err != nil {
+			log.Errorw("failed to fix balance", zap.Error(err))
+			return err
+		}
+		drift.Fixed = true
+
+		return nil
+	})
+	if err != nil {
+		return models.BalanceDrift{}, err
+	}
+
+	return drift, nil
+}
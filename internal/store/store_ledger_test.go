@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+)
+
+var (
+	insertEntry   = regexp.QuoteMeta("insert into " + journalEntriesTable + " (kind, coin_tx_id, order_id) values ($1, nullif($2, 0), nullif($3, 0)) returning id")
+	insertPosting = regexp.QuoteMeta("insert into " + postingsTable + " (entry_id, user_id, account, amount) values ($1, nullif($2, 0), nullif($3, ''), $4)")
+	postBalance   = regexp.QuoteMeta("update " + usersTable + " set coins = coins + $1 where id = $2")
+)
+
+// expectEntry expects postEntry to write the entry with the id entryId and
+// to apply its user postings to users.coins.
+func expectEntry(mock sqlmock.Sqlmock, entryId int64, entry journalEntry) {
+	mock.ExpectQuery(insertEntry).WithArgs(entry.kind, entry.coinTxId, entry.orderId).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(entryId))
+	for _, p := range entry.postings {
+		mock.ExpectExec(insertPosting).WithArgs(entryId, p.userId, p.account, p.amount).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		if p.userId != 0 {
+			mock.ExpectExec(postBalance).WithArgs(p.amount, p.userId).WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+	}
+}
+
+func TestPostEntry(t *testing.T) {
+	tests := []struct {
+		name        string
+		entry       journalEntry
+		setupMock   func(mock sqlmock.Sqlmock, entry journalEntry)
+		expectedErr error
+	}{
+		{
+			name: "Transfer between users",
+			entry: journalEntry{
+				kind:     models.CoinTxTransfer,
+				coinTxId: 5,
+				postings: []posting{userPosting(1, -30), userPosting(2, 30)},
+			},
+			setupMock: func(mock sqlmock.Sqlmock, entry journalEntry) {
+				expectEntry(mock, 9, entry)
+			},
+		},
+		{
+			name: "Purchase moves coins to the shop revenue",
+			entry: journalEntry{
+				kind:     entryPurchase,
+				orderId:  4,
+				postings: []posting{userPosting(1, -80), accountPosting(ledgerShopRevenue, 80)},
+			},
+			setupMock: func(mock sqlmock.Sqlmock, entry journalEntry) {
+				expectEntry(mock, 9, entry)
+			},
+		},
+		{
+			name: "Unbalanced entry is not written",
+			entry: journalEntry{
+				kind:     models.CoinTxGrant,
+				postings: []posting{accountPosting(ledgerTreasury, -100), userPosting(1, 90)},
+			},
+			setupMock:   func(mock sqlmock.Sqlmock, entry journalEntry) {},
+			expectedErr: internalErrors.UnbalancedEntry,
+		},
+		{
+			name: "Single posting",
+			entry: journalEntry{
+				kind:     models.CoinTxGrant,
+				postings: []posting{userPosting(1, 0)},
+			},
+			setupMock:   func(mock sqlmock.Sqlmock, entry journalEntry) {},
+			expectedErr: internalErrors.UnbalancedEntry,
+		},
+		{
+			name: "Posting to a user and an account",
+			entry: journalEntry{
+				kind:     models.CoinTxGrant,
+				postings: []posting{{userId: 1, account: ledgerTreasury, amount: 10}, userPosting(2, -10)},
+			},
+			setupMock:   func(mock sqlmock.Sqlmock, entry journalEntry) {},
+			expectedErr: internalErrors.UnbalancedEntry,
+		},
+		{
+			name: "Insert error",
+			entry: journalEntry{
+				kind:     models.CoinTxTransfer,
+				postings: []posting{userPosting(1, -30), userPosting(2, 30)},
+			},
+			setupMock: func(mock sqlmock.Sqlmock, entry journalEntry) {
+				mock.ExpectQuery(insertEntry).WillReturnError(errors.New("insert error"))
+			},
+			expectedErr: errors.New("insert error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			mock.ExpectBegin()
+			tc.setupMock(mock, tc.entry)
+			tx, err := db.Begin()
+			assert.NoError(t, err)
+
+			err = postEntry(tx, tc.entry)
+			switch {
+			case errors.Is(tc.expectedErr, internalErrors.UnbalancedEntry):
+				assert.ErrorIs(t, err, tc.expectedErr)
+			case tc.expectedErr != nil:
+				assert.EqualError(t, err, tc.expectedErr.Error())
+			default:
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLedgerStore_GetBalanceDrift(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	ledgerStore := NewLedgerStore(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectQuery(regexp.QuoteMeta("select count(*) from " + usersTable)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("where u.coins <> coalesce(p.amount, 0) order by u.id")).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "username", "coins", "expected"}).
+			AddRow(2, "bob", 150, 100).
+			AddRow(3, "carol", 0, 20))
+
+	checked, drifted, err := ledgerStore.GetBalanceDrift(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, checked)
+	assert.Equal(t, []models.BalanceDrift{
+		{UserID: 2, Username: "bob", Coins: 150, Expected: 100, Drift: 50},
+		{UserID: 3, Username: "carol", Coins: 0, Expected: 20, Drift: -20},
+	}, drifted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLedgerStore_FixBalance(t *testing.T) {
+	lockUser := regexp.QuoteMeta("select username, coins from " + usersTable + " where id = $1 for update")
+	sumPostings := regexp.QuoteMeta("select coalesce(sum(amount), 0) from " + postingsTable + " where user_id = $1")
+	setCoins := regexp.QuoteMeta("update " + usersTable + " set coins = $1 where id = $2")
+
+	tests := []struct {
+		name        string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.BalanceDrift
+		expectedErr error
+	}{
+		{
+			name: "Drift is fixed",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"username", "coins"}).AddRow("bob", 150))
+				mock.ExpectQuery(sumPostings).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+				mock.ExpectExec(setCoins).WithArgs(100, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			expected: models.BalanceDrift{UserID: 2, Username: "bob", Coins: 150, Expected: 100, Drift: 50, Fixed: true},
+		},
+		{
+			name: "Drift is gone",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"username", "coins"}).AddRow("bob", 100))
+				mock.ExpectQuery(sumPostings).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+				mock.ExpectCommit()
+			},
+			expected: models.BalanceDrift{UserID: 2, Username: "bob", Coins: 100, Expected: 100},
+		},
+		{
+			name: "Negative ledger balance",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"username", "coins"}).AddRow("bob", 0))
+				mock.ExpectQuery(sumPostings).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(-5))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.NegativeLedgerBalance,
+		},
+		{
+			name: "Unknown user",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockUser).WithArgs(2).WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: sql.ErrNoRows,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			ledgerStore := NewLedgerStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			drift, err := ledgerStore.FixBalance(context.Background(), 2)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, drift)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"sync"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"time"
+)
+
+// LoginAttemptStore keeps the counters in Postgres so that every replica
+// sees the same failures and lockouts.
+type LoginAttemptStore struct {
+	Db *sqlx.DB
+}
+
+func NewLoginAttemptStore(db *sqlx.DB) *LoginAttemptStore {
+	return &LoginAttemptStore{
+		Db: db,
+	}
+}
+
+// GetLoginAttempt returns an empty attempt for a key without failures.
+func (r *LoginAttemptStore) GetLoginAttempt(ctx context.Context, key string) (models.LoginAttempt, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select key, failures, locked_until from %s where key = $1
+`, loginAttemptsTable)
+
+	var attempt models.LoginAttempt
+	if err := r.Db.Get(&attempt, query, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.LoginAttempt{Key: key}, nil
+		}
+		log.Errorw("failed to get login attempt", zap.Error(err))
+		return models.LoginAttempt{}, err
+	}
+
+	return attempt, nil
+}
+
+func (r *LoginAttemptStore) RecordLoginFailure(ctx context.Context, key string, at, windowStart time.Time) (int, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	insert into %[1]s (key, failures, last_failure_at) values ($1, 1, $2)
+	on conflict (key) do update set
+		failures = case when %[1]s.last_failure_at < $3 then 1 else %[1]s.failures + 1 end,
+		last_failure_at = $2
+	returning failures
+`, loginAttemptsTable)
+
+	var failures int
+	if err := r.Db.QueryRow(query, key, at, windowStart).Scan(&failures); err != nil {
+		log.Errorw("failed to record login failure", zap.Error(err))
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func (r *LoginAttemptStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	update %s set locked_until = $2 where key = $1
+`, loginAttemptsTable)
+
+	if _, err := r.Db.Exec(query, key, until); err != nil {
+		log.Errorw("failed to lock login", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *LoginAttemptStore) ResetLoginAttempts(ctx context.Context, key string) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	delete from %s where key = $1
+`, loginAttemptsTable)
+
+	if _, err := r.Db.Exec(query, key); err != nil {
+		log.Errorw("failed to reset login attempts", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// MemoryLoginAttemptStore keeps the counters in process memory. It is meant
+// for a single replica, counters are lost on restart.
+type MemoryLoginAttemptStore struct {
+	mu        sync.Mutex
+	attempts  map[string]*memoryLoginAttempt
+	lastSweep time.Time
+}
+
+const memorySweepInterval = time.Minute
+
+type memoryLoginAttempt struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+func NewMemoryLoginAttemptStore() *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts: make(map[string]*memoryLoginAttempt),
+	}
+}
+
+func (m *MemoryLoginAttemptStore) GetLoginAttempt(_ context.Context, key string) (models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt := models.LoginAttempt{Key: key}
+	if a, ok := m.attempts[key]; ok {
+		attempt.Failures = a.failures
+		if !a.lockedUntil.IsZero() {
+			attempt.LockedUntil = sql.NullTime{Time: a.lockedUntil, Valid: true}
+		}
+	}
+
+	return attempt, nil
+}
+
+func (m *MemoryLoginAttemptStore) RecordLoginFailure(_ context.Context, key string, at, windowStart time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweep(at, windowStart)
+
+	a, ok := m.attempts[key]
+	if !ok {
+		a = &memoryLoginAttempt{}
+		m.attempts[key] = a
+	}
+	if a.lastFailureAt.Before(windowStart) {
+		a.failures = 0
+	}
+	a.failures++
+	a.lastFailureAt = at
+
+	return a.failures, nil
+}
+
+func (m *MemoryLoginAttemptStore) LockLogin(_ context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if a, ok := m.attempts[key]; ok {
+		a.lockedUntil = until
+	}
+
+	return nil
+}
+
+func (m *MemoryLoginAttemptStore) ResetLoginAttempts(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+
+	return nil
+}
+
+// sweep drops counters that are out of the window and no longer locked, so
+// the map does not grow with every IP that has ever failed a login.
+func (m *MemoryLoginAttemptStore) sweep(now, windowStart time.Time) {
+	if now.Sub(m.lastSweep) < memorySweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, a := range m.attempts {
+		if a.lastFailureAt.Before(windowStart) && a.lockedUntil.Before(now) {
+			delete(m.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginAttemptStore_RecordLoginFailure(t *testing.T) {
+	at := time.Now()
+	windowStart := at.Add(-time.Hour)
+	query := regexp.QuoteMeta("insert into " + loginAttemptsTable + " (key, failures, last_failure_at) values ($1, 1, $2)")
+
+	tests := []struct {
+		name             string
+		setupMock        func(mock sqlmock.Sqlmock)
+		expectedFailures int
+		expectErr        bool
+	}{
+		{
+			name: "Success",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"failures"}).AddRow(3)
+				mock.ExpectQuery(query).WithArgs("user:test", at, windowStart).WillReturnRows(rows)
+			},
+			expectedFailures: 3,
+		},
+		{
+			name: "Query error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("user:test", at, windowStart).WillReturnError(errors.New("db error"))
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			attemptStore := NewLoginAttemptStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			failures, err := attemptStore.RecordLoginFailure(context.Background(), "user:test", at, windowStart)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedFailures, failures)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLoginAttemptStore_GetLoginAttempt(t *testing.T) {
+	query := regexp.QuoteMeta("select key, failures, locked_until from " + loginAttemptsTable + " where key = $1")
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	attemptStore := NewLoginAttemptStore(sqlx.NewDb(db, "sqlmock"))
+
+	mock.ExpectQuery(query).WithArgs("ip:10.0.0.1").WillReturnError(sql.ErrNoRows)
+
+	attempt, err := attemptStore.GetLoginAttempt(context.Background(), "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ip:10.0.0.1", attempt.Key)
+	assert.Zero(t, attempt.Failures)
+	assert.False(t, attempt.LockedUntil.Valid)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMemoryLoginAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryLoginAttemptStore()
+	now := time.Now()
+
+	failures, err := m.RecordLoginFailure(ctx, "user:test", now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	failures, err = m.RecordLoginFailure(ctx, "user:test", now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, failures)
+
+	assert.NoError(t, m.LockLogin(ctx, "user:test", now.Add(time.Minute)))
+	attempt, err := m.GetLoginAttempt(ctx, "user:test")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempt.Failures)
+	assert.True(t, attempt.LockedUntil.Valid)
+
+	later := now.Add(2 * time.Hour)
+	failures, err = m.RecordLoginFailure(ctx, "user:test", later, later.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failures, "counter restarts outside the window")
+
+	assert.NoError(t, m.ResetLoginAttempts(ctx, "user:test"))
+	attempt, err = m.GetLoginAttempt(ctx, "user:test")
+	assert.NoError(t, err)
+	assert.Zero(t, attempt.Failures)
+}
@@ -0,0 +1,261 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"time"
+)
+
+const returnColumns = `id, order_id, user_id, status, amount, reason, note, created_at, decided_at, decided_by`
+
+type ReturnStore struct {
+	Db *sqlx.DB
+}
+
+func NewReturnStore(db *sqlx.DB) *ReturnStore {
+	return &ReturnStore{
+		Db: db,
+	}
+}
+
+// CreateReturn asks to return an order of the user that was made at or after
+// orderedAfter. The return is for the whole order and refunds its total.
+// Gifts can not be returned.
+func (r *ReturnStore) CreateReturn(ctx context.Context, userId int, orderId int64, reason string, orderedAfter time.Time) (models.Return, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var ret models.Return
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		firstQuery := fmt.Sprintf(`
+	select total, created_at, recipient_id is not null from %s where id = $1 and user_id = $2
+`, ordersTable)
+
+		var (
+			total     int
+			createdAt time.Time
+			gift      bool
+		)
+		if err := tx.QueryRow(firstQuery, orderId, userId).Scan(&total, &createdAt, &gift); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return internalErrors.OrderNotFound
+			}
+			log.Errorw("failed to get order", zap.Error(err))
+			return err
+		}
+		// the items of a gift are in the inventory of the recipient
+		if gift {
+			return internalErrors.GiftNotReturnable
+		}
+		if createdAt.Before(orderedAfter) {
+			return internalErrors.ReturnWindowClosed
+		}
+
+		secondQuery := fmt.Sprintf(`
+	insert into %s (order_id, user_id, amount, reason) values ($1, $2, $3, $4)
+	returning %s
+`, returnsTable, returnColumns)
+
+		if err := tx.QueryRow(secondQuery, orderId, userId, total, reason).Scan(returnFields(&ret)...); err != nil {
+			if isUniqueViolation(err) {
+				return internalErrors.ReturnExists
+			}
+			log.Errorw("failed to create return", zap.Error(err))
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	return ret, nil
+}
+
+// GetReturns returns a page of the returns with the given status, all of
+// them for an empty status, oldest first.
+func (r *ReturnStore) GetReturns(ctx context.Context, filter models.ReturnsFilter) ([]models.Return, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select %s from %s
+	where ($1 = '' or status = $1) and ($2 = 0 or (created_at, id) > ($3, $2))
+	order by created_at, id limit $4
+`, returnColumns, returnsTable)
+
+	returns := []models.Return{}
+	if err := r.Db.Select(&returns, query, filter.Status, filter.AfterID, filter.AfterCreatedAt, filter.Limit); err != nil {
+		log.Errorw("failed to get returns", zap.Error(err))
+		return nil, err
+	}
+
+	return returns, nil
+}
+
+// ApproveReturn takes the items of the order out of the inventory, puts
+// limited items back on stock and refunds the order total from the shop
+// revenue as a refund coin transaction, all in one transaction.
+func (r *ReturnStore) ApproveReturn(ctx context.Context, returnId int64, actorId int, note string) (models.Return, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	var ret models.Return
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		var err error
+		ret, err = lockPendingReturn(tx, returnId)
+		if err != nil {
+			return err
+		}
+
+		// the user row is locked before the inventory, in the order Checkout uses
+		firstQuery := fmt.Sprintf(`
+	select coins from %s where id = $1 for update
+`, usersTable)
+
+		var coins int
+		if err = tx.QueryRow(firstQuery, ret.UserID).Scan(&coins); err != nil {
+			log.Errorw("failed to lock user", zap.Error(err))
+			return err
+		}
+
+		linesQuery := fmt.Sprintf(`
+	select item_type, quantity from %s where order_id = $1 order by item_type
+`, orderLinesTable)
+
+		rows, err := tx.Query(linesQuery, ret.OrderID)
+		if err != nil {
+			log.Errorw("failed to get order lines", zap.Error(err))
+			return err
+		}
+		var lines []models.CartLine
+		for rows.Next() {
+			var line models.CartLine
+			if err = rows.Scan(&line.Item, &line.Quantity); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			lines = append(lines, line)
+		}
+		if err = rows.Close(); err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			if err = takeBackItem(tx, ret.UserID, line); err != nil {
+				log.Errorw("failed to take item back", zap.String("item", line.Item), zap.Error(err))
+				return err
+			}
+		}
+
+		secondQuery := fmt.Sprintf(`
+	insert into %s (sender_id, receiver_id, amount, kind, reason, actor_id, balance_after)
+	values (null, $1, $2, $3, $4, $5, $6) returning id
+`, coinTxTable)
+
+		var coinTxId int64
+		err = tx.QueryRow(secondQuery, ret.UserID, ret.Amount, models.CoinTxRefund,
+			fmt.Sprintf("return of order #%d", ret.OrderID), actorId, coins+ret.Amount).Scan(&coinTxId)
+		if err != nil {
+			log.Errorw("failed to insert coin transaction", zap.Error(err))
+			return err
+		}
+
+		err = postEntry(tx, journalEntry{
+			kind:     models.CoinTxRefund,
+			coinTxId: coinTxId,
+			orderId:  ret.OrderID,
+			postings: []posting{accountPosting(ledgerShopRevenue, -ret.Amount), userPosting(ret.UserID, ret.Amount)},
+		})
+		if err != nil {
+			log.Errorw("failed to refund coins", zap.Error(err))
+			return err
+		}
+
+		ret, err = decideReturn(tx, returnId, models.ReturnApproved, actorId, note)
+		return err
+	})
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	return ret, nil
+}
+
+func (r *ReturnStore) RejectReturn(ctx context.Context, returnId int64, actorId int, note string) (models.Return, error) {
+	var ret models.Return
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		if _, err := lockPendingReturn(tx, returnId); err != nil {
+			return err
+		}
+
+		var err error
+		ret, err = decideReturn(tx, returnId, models.ReturnRejected, actorId, note)
+		return err
+	})
+	if err != nil {
+		return models.Return{}, err
+	}
+
+	return ret, nil
+}
+
+func lockPendingReturn(tx *sql.Tx, returnId int64) (models.Return, error) {
+	query := fmt.Sprintf(`
+	select %s from %s where id = $1 for update
+`, returnColumns, returnsTable)
+
+	var ret models.Return
+	if err := tx.QueryRow(query, returnId).Scan(returnFields(&ret)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Return{}, internalErrors.ReturnNotFound
+		}
+		return models.Return{}, err
+	}
+	if ret.Status != models.ReturnPending {
+		return models.Return{}, internalErrors.ReturnDecided
+	}
+
+	return ret, nil
+}
+
+func decideReturn(tx *sql.Tx, returnId int64, status string, actorId int, note string) (models.Return, error) {
+	query := fmt.Sprintf(`
+	update %s set status = $1, note = $2, decided_by = $3, decided_at = now() where id = $4
+	returning %s
+`, returnsTable, returnColumns)
+
+	var ret models.Return
+	if err := tx.QueryRow(query, status, note, actorId, returnId).Scan(returnFields(&ret)...); err != nil {
+		return models.Return{}, err
+	}
+
+	return ret, nil
+}
+
+// takeBackItem removes a returned line from the inventory of the user and
+// puts it back on stock when the item has a limited stock.
+func takeBackItem(tx *sql.Tx, userId int, line models.CartLine) error {
+	if err := takeItem(tx, userId, line.Item, line.Quantity); err != nil {
+		if errors.Is(err, internalErrors.NotEnoughItems) {
+			return fmt.Errorf("%w: %s", internalErrors.ReturnItemsMissing, line.Item)
+		}
+		return err
+	}
+
+	stockQuery := fmt.Sprintf(`
+	update %s set stock = stock + $1 where name = $2 and stock is not null
+`, itemsTable)
+
+	_, err := tx.Exec(stockQuery, line.Quantity, line.Item)
+	return err
+}
+
+func returnFields(ret *models.Return) []interface{} {
+	return []interface{}{&ret.ID, &ret.OrderID, &ret.UserID, &ret.Status, &ret.Amount, &ret.Reason, &ret.Note,
+		&ret.CreatedAt, &ret.DecidedAt, &ret.DecidedBy}
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	internalErrors "testAlvtoShp/internal/errors"
+	"testAlvtoShp/internal/models"
+)
+
+var returnColumnNames = []string{"id", "order_id", "user_id", "status", "amount", "reason", "note", "created_at", "decided_at", "decided_by"}
+
+func TestReturnStore_CreateReturn(t *testing.T) {
+	orderedAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	orderQuery := regexp.QuoteMeta("select total, created_at, recipient_id is not null from " + ordersTable + " where id = $1 and user_id = $2")
+	insertQuery := regexp.QuoteMeta("insert into " + returnsTable + " (order_id, user_id, amount, reason) values ($1, $2, $3, $4)")
+
+	tests := []struct {
+		name         string
+		orderedAfter time.Time
+		setupMock    func(mock sqlmock.Sqlmock)
+		expected     models.Return
+		expectedErr  error
+	}{
+		{
+			name:         "Success",
+			orderedAfter: orderedAt.Add(-time.Hour),
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(orderQuery).WithArgs(int64(7), 42).
+					WillReturnRows(sqlmock.NewRows([]string{"total", "created_at", "gift"}).AddRow(120, orderedAt, false))
+				mock.ExpectQuery(insertQuery).WithArgs(int64(7), 42, 120, "wrong size").
+					WillReturnRows(sqlmock.NewRows(returnColumnNames).
+						AddRow(3, 7, 42, "pending", 120, "wrong size", "", orderedAt, nil, nil))
+				mock.ExpectCommit()
+			},
+			expected: models.Return{
+				ID: 3, OrderID: 7, UserID: 42, Status: models.ReturnPending, Amount: 120,
+				Reason: "wrong size", CreatedAt: orderedAt,
+			},
+		},
+		{
+			name:         "Order of another user",
+			orderedAfter: orderedAt.Add(-time.Hour),
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(orderQuery).WithArgs(int64(7), 42).
+					WillReturnRows(sqlmock.NewRows([]string{"total", "created_at", "gift"}))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.OrderNotFound,
+		},
+		{
+			name:         "Window closed",
+			orderedAfter: orderedAt.Add(time.Hour),
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(orderQuery).WithArgs(int64(7), 42).
+					WillReturnRows(sqlmock.NewRows([]string{"total", "created_at", "gift"}).AddRow(120, orderedAt, false))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ReturnWindowClosed,
+		},
+		{
+			name:         "Gift",
+			orderedAfter: orderedAt.Add(-time.Hour),
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(orderQuery).WithArgs(int64(7), 42).
+					WillReturnRows(sqlmock.NewRows([]string{"total", "created_at", "gift"}).AddRow(120, orderedAt, true))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.GiftNotReturnable,
+		},
+		{
+			name:         "Already returned",
+			orderedAfter: orderedAt.Add(-time.Hour),
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(orderQuery).WithArgs(int64(7), 42).
+					WillReturnRows(sqlmock.NewRows([]string{"total", "created_at", "gift"}).AddRow(120, orderedAt, false))
+				mock.ExpectQuery(insertQuery).WillReturnError(&pq.Error{Code: uniqueViolationCode})
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ReturnExists,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			returnStore := NewReturnStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			ret, err := returnStore.CreateReturn(context.Background(), 42, 7, "wrong size", tc.orderedAfter)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, ret)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReturnStore_ApproveReturn(t *testing.T) {
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	decidedAt := createdAt.Add(time.Hour)
+	dbErr := errors.New("db error")
+	lockQuery := regexp.QuoteMeta("select " + returnColumns + " from " + returnsTable + " where id = $1 for update")
+	inventoryQuery := regexp.QuoteMeta("update " + inventoryTable + " set quantity = quantity - $1 where user_id = $2 and item_type = $3 and quantity >= $1")
+	pending := func() *sqlmock.Rows {
+		return sqlmock.NewRows(returnColumnNames).AddRow(3, 7, 42, "pending", 120, "wrong size", "", createdAt, nil, nil)
+	}
+	expectLines := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta("select coins from " + usersTable + " where id = $1 for update")).WithArgs(42).
+			WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(880))
+		mock.ExpectQuery(regexp.QuoteMeta("select item_type, quantity from " + orderLinesTable + " where order_id = $1 order by item_type")).
+			WithArgs(int64(7)).
+			WillReturnRows(sqlmock.NewRows([]string{"item_type", "quantity"}).AddRow("cup", 1).AddRow("pen", 10))
+	}
+
+	tests := []struct {
+		name        string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expectedErr error
+	}{
+		{
+			name: "Success",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockQuery).WithArgs(int64(3)).WillReturnRows(pending())
+				expectLines(mock)
+				mock.ExpectQuery(inventoryQuery).WithArgs(1, 42, "cup").
+					WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(0))
+				mock.ExpectExec(regexp.QuoteMeta("delete from "+inventoryTable+" where user_id = $1 and item_type = $2")).
+					WithArgs(42, "cup").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("update "+itemsTable+" set stock = stock + $1 where name = $2 and stock is not null")).
+					WithArgs(1, "cup").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(inventoryQuery).WithArgs(10, 42, "pen").
+					WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(2))
+				mock.ExpectExec(regexp.QuoteMeta("update "+itemsTable+" set stock = stock + $1")).
+					WithArgs(10, "pen").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta("insert into "+coinTxTable+" (sender_id, receiver_id, amount, kind, reason, actor_id, balance_after)")).
+					WithArgs(42, 120, models.CoinTxRefund, "return of order #7", 1, 1000).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(15))
+				expectEntry(mock, 20, journalEntry{
+					kind:     models.CoinTxRefund,
+					coinTxId: 15,
+					orderId:  7,
+					postings: []posting{accountPosting(ledgerShopRevenue, -120), userPosting(42, 120)},
+				})
+				mock.ExpectQuery(regexp.QuoteMeta("update "+returnsTable+" set status = $1, note = $2, decided_by = $3, decided_at = now() where id = $4")).
+					WithArgs(models.ReturnApproved, "ok", 1, int64(3)).
+					WillReturnRows(sqlmock.NewRows(returnColumnNames).AddRow(3, 7, 42, "approved", 120, "wrong size", "ok", createdAt, decidedAt, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Already decided",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockQuery).WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows(returnColumnNames).AddRow(3, 7, 42, "rejected", 120, "wrong size", "", createdAt, decidedAt, 1))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ReturnDecided,
+		},
+		{
+			name: "Not found",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockQuery).WithArgs(int64(3)).WillReturnRows(sqlmock.NewRows(returnColumnNames))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ReturnNotFound,
+		},
+		{
+			name: "Items are gone",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockQuery).WithArgs(int64(3)).WillReturnRows(pending())
+				expectLines(mock)
+				mock.ExpectQuery(inventoryQuery).WithArgs(1, 42, "cup").
+					WillReturnRows(sqlmock.NewRows([]string{"quantity"}))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ReturnItemsMissing,
+		},
+		{
+			name: "Refund error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(lockQuery).WithArgs(int64(3)).WillReturnRows(pending())
+				mock.ExpectQuery(regexp.QuoteMeta("select coins from " + usersTable)).WillReturnError(dbErr)
+				mock.ExpectRollback()
+			},
+			expectedErr: dbErr,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			returnStore := NewReturnStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			ret, err := returnStore.ApproveReturn(context.Background(), 3, 1, "ok")
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, models.ReturnApproved, ret.Status)
+				assert.Equal(t, &decidedAt, ret.DecidedAt)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReturnStore_RejectReturn(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	returnStore := NewReturnStore(sqlx.NewDb(db, "sqlmock"))
+
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("select " + returnColumns + " from " + returnsTable + " where id = $1 for update")).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows(returnColumnNames).AddRow(3, 7, 42, "pending", 120, "wrong size", "", createdAt, nil, nil))
+	mock.ExpectQuery(regexp.QuoteMeta("update "+returnsTable+" set status = $1")).
+		WithArgs(models.ReturnRejected, "", 1, int64(3)).
+		WillReturnRows(sqlmock.NewRows(returnColumnNames).AddRow(3, 7, 42, "rejected", 120, "wrong size", "", createdAt, createdAt, 1))
+	mock.ExpectCommit()
+
+	ret, err := returnStore.RejectReturn(context.Background(), 3, 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReturnRejected, ret.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"testAlvtoShp/internal/logger"
+	"testAlvtoShp/internal/models"
+	"time"
+)
+
+type SessionStore struct {
+	Db *sqlx.DB
+}
+
+func NewSessionStore(db *sqlx.DB) *SessionStore {
+	return &SessionStore{
+		Db: db,
+	}
+}
+
+func (r *SessionStore) CreateSession(ctx context.Context, userId int, sessionId, tokenHash string, expiresAt time.Time) error {
+	log := logger.LoggerFromContext(ctx)
+	tx, err := r.Db.Begin()
+	if err != nil {
+		log.Errorw("failed to begin transaction", zap.Error(err))
+		return err
+	}
+
+	firstQuery := fmt.Sprintf(`
+	insert into %s (id, user_id) values ($1, $2)
+`, sessionsTable)
+
+	if _, err = tx.Exec(firstQuery, sessionId, userId); err != nil {
+		log.Errorw("failed to insert session", zap.Error(err))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return err
+	}
+
+	secondQuery := fmt.Sprintf(`
+	insert into %s (session_id, token_hash, expires_at) values ($1, $2, $3)
+`, refreshTokensTable)
+
+	if _, err = tx.Exec(secondQuery, sessionId, tokenHash, expiresAt); err != nil {
+		log.Errorw("failed to insert refresh token", zap.Error(err))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRefreshToken returns sql.ErrNoRows when the token is unknown.
+func (r *SessionStore) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select rt.id, rt.session_id, s.user_id, u.role, rt.expires_at, rt.used_at, s.revoked_at is not null as session_revoked
+	from %s rt
+	join %s s on s.id = rt.session_id
+	join %s u on u.id = s.user_id
+	where rt.token_hash = $1
+`, refreshTokensTable, sessionsTable, usersTable)
+
+	var token models.RefreshToken
+	if err := r.Db.Get(&token, query, tokenHash); err != nil {
+		log.Errorw("failed to get refresh token", zap.Error(err))
+		return models.RefreshToken{}, err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken marks the old token as used and issues its successor in
+// the same session. It returns false if the old token has already been used,
+// which means that it was presented twice.
+func (r *SessionStore) RotateRefreshToken(ctx context.Context, oldTokenId int64, sessionId, tokenHash string, expiresAt time.Time) (bool, error) {
+	log := logger.LoggerFromContext(ctx)
+	tx, err := r.Db.Begin()
+	if err != nil {
+		log.Errorw("failed to begin transaction", zap.Error(err))
+		return false, err
+	}
+
+	firstQuery := fmt.Sprintf(`
+	update %s set used_at = now() where id = $1 and used_at is null
+`, refreshTokensTable)
+
+	res, err := tx.Exec(firstQuery, oldTokenId)
+	if err != nil {
+		log.Errorw("failed to mark refresh token as used", zap.Error(err))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return false, err
+	}
+
+	secondQuery := fmt.Sprintf(`
+	insert into %s (session_id, token_hash, expires_at) values ($1, $2, $3)
+`, refreshTokensTable)
+
+	if _, err = tx.Exec(secondQuery, sessionId, tokenHash, expiresAt); err != nil {
+		log.Errorw("failed to insert refresh token", zap.Error(err))
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		}
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *SessionStore) RevokeSession(ctx context.Context, sessionId string) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	update %s set revoked_at = now() where id = $1 and revoked_at is null
+`, sessionsTable)
+
+	if _, err := r.Db.Exec(query, sessionId); err != nil {
+		log.Errorw("failed to revoke session", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *SessionStore) RevokeUserSessions(ctx context.Context, userId int) error {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	update %s set revoked_at = now() where user_id = $1 and revoked_at is null
+`, sessionsTable)
+
+	if _, err := r.Db.Exec(query, userId); err != nil {
+		log.Errorw("failed to revoke user sessions", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// GetSessionRole returns the current role of the session owner. It returns
+// an empty role if the session does not exist, belongs to another user or
+// has been revoked.
+func (r *SessionStore) GetSessionRole(ctx context.Context, userId int, sessionId string) (string, error) {
+	log := logger.LoggerFromContext(ctx)
+	query := fmt.Sprintf(`
+	select u.role from %s s join %s u on u.id = s.user_id where s.id = $1 and s.user_id = $2 and s.revoked_at is null
+`, sessionsTable, usersTable)
+
+	var role string
+
+	row := r.Db.QueryRow(query, sessionId, userId)
+
+	if err := row.Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		log.Errorw("error with scanning row", zap.Error(err))
+		return "", err
+	}
+
+	return role, nil
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStore_RotateRefreshToken(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name            string
+		setupMock       func(mock sqlmock.Sqlmock)
+		expectedRotated bool
+		expectErr       bool
+	}{
+		{
+			name: "Success",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("update " + refreshTokensTable + " set used_at = now() where id = $1 and used_at is null")).
+					WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("insert into "+refreshTokensTable+" (session_id, token_hash, expires_at) values ($1, $2, $3)")).
+					WithArgs("session", "newhash", expiresAt).WillReturnResult(sqlmock.NewResult(8, 1))
+				mock.ExpectCommit()
+			},
+			expectedRotated: true,
+			expectErr:       false,
+		},
+		{
+			name: "Token already used",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("update " + refreshTokensTable + " set used_at = now() where id = $1 and used_at is null")).
+					WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			expectedRotated: false,
+			expectErr:       false,
+		},
+		{
+			name: "Insert error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("update " + refreshTokensTable + " set used_at = now() where id = $1 and used_at is null")).
+					WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("insert into "+refreshTokensTable+" (session_id, token_hash, expires_at) values ($1, $2, $3)")).
+					WithArgs("session", "newhash", expiresAt).WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			expectedRotated: false,
+			expectErr:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			sqlxDB := sqlx.NewDb(db, "sqlmock")
+			sessionStore := NewSessionStore(sqlxDB)
+
+			tc.setupMock(mock)
+
+			rotated, err := sessionStore.RotateRefreshToken(context.Background(), 7, "session", "newhash", expiresAt)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedRotated, rotated)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSessionStore_GetSessionRole(t *testing.T) {
+	query := regexp.QuoteMeta("select u.role from " + sessionsTable + " s join " + usersTable + " u on u.id = s.user_id where s.id = $1 and s.user_id = $2 and s.revoked_at is null")
+
+	tests := []struct {
+		name         string
+		setupMock    func(mock sqlmock.Sqlmock)
+		expectedRole string
+		expectErr    bool
+	}{
+		{
+			name: "Active session",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"role"}).AddRow("admin")
+				mock.ExpectQuery(query).WithArgs("session", 42).WillReturnRows(rows)
+			},
+			expectedRole: "admin",
+			expectErr:    false,
+		},
+		{
+			name: "Revoked session",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("session", 42).WillReturnError(sql.ErrNoRows)
+			},
+			expectedRole: "",
+			expectErr:    false,
+		},
+		{
+			name: "Query error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("session", 42).WillReturnError(errors.New("db error"))
+			},
+			expectedRole: "",
+			expectErr:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			sqlxDB := sqlx.NewDb(db, "sqlmock")
+			sessionStore := NewSessionStore(sqlxDB)
+
+			tc.setupMock(mock)
+
+			role, err := sessionStore.GetSessionRole(context.Background(), 42, "session")
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedRole, role)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -2,14 +2,32 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
+	"strings"
 	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/logger"
 	"testAlvtoShp/internal/models"
 )
 
+// recentPurchasesLimit is the number of orders shown in the user info.
+const recentPurchasesLimit = 5
+
+// recentGiftsLimit is the number of received gift lines shown in the user info.
+const recentGiftsLimit = 10
+
+// pendingCoinRequestsLimit is the number of incoming and of outgoing pending
+// coin requests shown in the user info.
+const pendingCoinRequestsLimit = 50
+
+// orderColumns selects an order with the name of the gift recipient, if any.
+var orderColumns = fmt.Sprintf(`id, total, created_at,
+	COALESCE((SELECT username FROM %s WHERE %[1]s.id = recipient_id), '') AS gift_to`, usersTable)
+
 type ShopStore struct {
 	Db *sqlx.DB
 }
@@ -20,7 +38,9 @@ func NewShopStore(db *sqlx.DB) *ShopStore {
 	}
 }
 
-func (r *ShopStore) GetUserInfo(ctx context.Context, userId int) (models.InfoResponse, error) {
+// GetUserInfo shows at most historyLimit of the newest received and sent coin
+// transactions each.
+func (r *ShopStore) GetUserInfo(ctx context.Context, userId int, historyLimit int) (models.InfoResponse, error) {
 	log := logger.LoggerFromContext(ctx)
 	var user models.User
 	if err := r.Db.Get(&user, fmt.Sprintf("SELECT id, username, coins FROM %s WHERE id=$1", usersTable), userId); err != nil {
@@ -43,12 +63,15 @@ func (r *ShopStore) GetUserInfo(ctx context.Context, userId int) (models.InfoRes
 
 	var receivedTx []models.ReceivedTransaction
 	queryReceived := fmt.Sprintf(`
-			SELECT ct.amount, COALESCE(u.username, 'Unknown') AS from_user
+			SELECT ct.id, ct.amount, ct.kind, COALESCE(ct.reason, '') AS reason, COALESCE(ct.memo, '') AS memo, ct.timestamp,
+				CASE WHEN ct.kind = 'transfer' THEN COALESCE(u.username, 'Unknown') ELSE 'system' END AS from_user
 			FROM %s ct
 			LEFT JOIN users u ON ct.sender_id = u.id
 			WHERE ct.receiver_id = $1
+			ORDER BY ct.timestamp DESC, ct.id DESC
+			LIMIT $2
 		`, coinTxTable)
-	if err := r.Db.Select(&receivedTx, queryReceived, userId); err != nil {
+	if err := r.Db.Select(&receivedTx, queryReceived, userId, historyLimit); err != nil {
 		log.Errorw("GetUserInfo get story gotten tx", zap.Error(err))
 		return models.InfoResponse{}, err
 	}
@@ -56,30 +79,86 @@ func (r *ShopStore) GetUserInfo(ctx context.Context, userId int) (models.InfoRes
 	var received []models.ReceivedTransaction
 	for _, rt := range receivedTx {
 		received = append(received, models.ReceivedTransaction{
-			FromUser: rt.FromUser,
-			Amount:   rt.Amount,
+			ID:        rt.ID,
+			FromUser:  rt.FromUser,
+			Amount:    rt.Amount,
+			Kind:      rt.Kind,
+			Reason:    rt.Reason,
+			Memo:      rt.Memo,
+			Timestamp: rt.Timestamp,
 		})
 	}
 
 	var sentTx []models.SentTransaction
 	querySent := fmt.Sprintf(`
-			SELECT ct.amount, COALESCE(u.username, 'Unknown') AS to_user
+			SELECT ct.id, ct.amount, ct.kind, COALESCE(ct.reason, '') AS reason, COALESCE(ct.memo, '') AS memo, ct.timestamp,
+				CASE WHEN ct.kind = 'transfer' THEN COALESCE(u.username, 'Unknown') ELSE 'system' END AS to_user
 			FROM %s ct
 			LEFT JOIN users u ON ct.receiver_id = u.id
 			WHERE ct.sender_id = $1
+			ORDER BY ct.timestamp DESC, ct.id DESC
+			LIMIT $2
 		`, coinTxTable)
-	if err := r.Db.Select(&sentTx, querySent, userId); err != nil {
+	if err := r.Db.Select(&sentTx, querySent, userId, historyLimit); err != nil {
 		log.Errorw("GetUserInfo get story send tx", zap.Error(err))
 		return models.InfoResponse{}, err
 	}
 	var sent []models.SentTransaction
 	for _, st := range sentTx {
 		sent = append(sent, models.SentTransaction{
-			ToUser: st.ToUser,
-			Amount: st.Amount,
+			ID:        st.ID,
+			ToUser:    st.ToUser,
+			Amount:    st.Amount,
+			Kind:      st.Kind,
+			Reason:    st.Reason,
+			Memo:      st.Memo,
+			Timestamp: st.Timestamp,
 		})
 	}
 
+	recent := make([]models.Order, 0, recentPurchasesLimit)
+	queryRecent := fmt.Sprintf(`
+			SELECT %s
+			FROM %s
+			WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		`, orderColumns, ordersTable)
+	if err := r.Db.Select(&recent, queryRecent, userId, recentPurchasesLimit); err != nil {
+		log.Errorw("GetUserInfo get recent purchases", zap.Error(err))
+		return models.InfoResponse{}, err
+	}
+	if err := r.loadOrderLines(recent); err != nil {
+		log.Errorw("GetUserInfo get recent purchase lines", zap.Error(err))
+		return models.InfoResponse{}, err
+	}
+
+	gifts := make([]models.ReceivedGift, 0, recentGiftsLimit)
+	queryGifts := fmt.Sprintf(`
+			SELECT COALESCE(u.username, 'Unknown') AS from_user, l.item_type, l.quantity, o.created_at
+			FROM %s o
+			JOIN %s l ON l.order_id = o.id
+			LEFT JOIN %s u ON u.id = o.user_id
+			WHERE o.recipient_id = $1
+			ORDER BY o.created_at DESC, o.id DESC, l.id
+			LIMIT $2
+		`, ordersTable, orderLinesTable, usersTable)
+	if err := r.Db.Select(&gifts, queryGifts, userId, recentGiftsLimit); err != nil {
+		log.Errorw("GetUserInfo get received gifts", zap.Error(err))
+		return models.InfoResponse{}, err
+	}
+
+	incoming, err := pendingCoinRequests(r.Db, "payer_id", userId, pendingCoinRequestsLimit)
+	if err != nil {
+		log.Errorw("GetUserInfo get incoming coin requests", zap.Error(err))
+		return models.InfoResponse{}, err
+	}
+	outgoing, err := pendingCoinRequests(r.Db, "requester_id", userId, pendingCoinRequestsLimit)
+	if err != nil {
+		log.Errorw("GetUserInfo get outgoing coin requests", zap.Error(err))
+		return models.InfoResponse{}, err
+	}
+
 	response := models.InfoResponse{
 		Coins:     user.Coins,
 		Inventory: inventoryItems,
@@ -87,210 +166,545 @@ func (r *ShopStore) GetUserInfo(ctx context.Context, userId int) (models.InfoRes
 			Received: received,
 			Sent:     sent,
 		},
+		RecentPurchases: recent,
+		ReceivedGifts:   gifts,
+		CoinRequests:    models.CoinRequests{Incoming: incoming, Outgoing: outgoing},
 	}
 
 	return response, nil
 
 }
 
-func (r *ShopStore) BuyItem(ctx context.Context, userId int, item string) error {
+// Checkout buys every line of the cart in one transaction, either all of
+// them or none. Prices come from the items table at the time of the call.
+// The lines must name distinct items, sorted by name, so concurrent checkouts
+// lock the stock of items in the same order.
+func (r *ShopStore) Checkout(ctx context.Context, userId int, cart []models.CartLine) (models.Order, error) {
+	var order models.Order
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		var err error
+		order, err = placeOrder(ctx, tx, userId, nil, cart)
+		return err
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// Gift buys the cart like Checkout, but the items go to the inventory of
+// toUser. An unknown recipient is sql.ErrNoRows, as in SendCoin.
+func (r *ShopStore) Gift(ctx context.Context, userId int, toUser string, cart []models.CartLine) (models.Order, error) {
 	log := logger.LoggerFromContext(ctx)
-	tx, err := r.Db.Begin()
+
+	var order models.Order
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		firstQuery := fmt.Sprintf(`
+	select id from %s where username = $1
+`, usersTable)
+
+		var toUserId int
+		if err := tx.QueryRow(firstQuery, toUser).Scan(&toUserId); err != nil {
+			log.Errorw("failed to scan row", zap.Error(err))
+			return err
+		}
+		if toUserId == userId {
+			return internalErrors.GiftToSelf
+		}
+
+		var err error
+		order, err = placeOrder(ctx, tx, userId, &toUserId, cart)
+		if err != nil {
+			return err
+		}
+		order.GiftTo = toUser
+		return nil
+	})
 	if err != nil {
-		log.Errorw("failed to begin transaction", zap.Error(err))
-		return err
+		return models.Order{}, err
 	}
 
+	return order, nil
+}
+
+// placeOrder charges the buyer for the cart and puts the items into the
+// inventory of the recipient, or of the buyer when there is none.
+func placeOrder(ctx context.Context, tx *sql.Tx, userId int, recipientId *int, cart []models.CartLine) (models.Order, error) {
+	log := logger.LoggerFromContext(ctx)
+
 	firstQuery := fmt.Sprintf(`
-	select coins from %s where id = $1
+	select coins from %s where id = $1 for update
 `, usersTable)
 
 	var coins int
 
-	err = tx.QueryRow(firstQuery, userId).Scan(&coins)
-
-	if err != nil {
+	if err := tx.QueryRow(firstQuery, userId).Scan(&coins); err != nil {
 		log.Errorw("failed to scan row", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		return models.Order{}, err
+	}
+
+	order := models.Order{Lines: make([]models.OrderLine, 0, len(cart))}
+	limited := make([]models.CartLine, 0, len(cart))
+	for _, line := range cart {
+		price, stocked, err := checkItem(tx, userId, line)
+		if err != nil {
+			log.Errorw("item can not be bought", zap.String("item", line.Item), zap.Error(err))
+			return models.Order{}, err
 		}
-		return err
+		if stocked {
+			limited = append(limited, line)
+		}
+
+		order.Lines = append(order.Lines, models.OrderLine{Item: line.Item, UnitPrice: price, Quantity: line.Quantity})
+		order.Total += price * line.Quantity
 	}
 
-	secondQuery := fmt.Sprintf(`
-	select price from %s where name = $1
+	if coins < order.Total {
+		log.Errorw("user doesnt have enough money", zap.Int("total", order.Total), zap.Int("userCoins", coins))
+		return models.Order{}, internalErrors.NoMoney
+	}
+
+	stockQuery := fmt.Sprintf(`
+	update %s set stock = stock - $1 where name = $2 and stock >= $1
 `, itemsTable)
 
-	var price int
-	err = tx.QueryRow(secondQuery, item).Scan(&price)
-	if err != nil {
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+	for _, line := range limited {
+		res, err := tx.Exec(stockQuery, line.Quantity, line.Item)
+		if err != nil {
+			log.Errorw("failed to update stock", zap.Error(err))
+			return models.Order{}, err
 		}
-		log.Errorw("failed to scan row", zap.Error(err))
-		return err
-	}
-
-	if coins < price {
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return models.Order{}, err
+		}
+		if affected == 0 {
+			return models.Order{}, fmt.Errorf("%w: %s", internalErrors.SoldOut, line.Item)
 		}
-		log.Errorw("user doesnt have enough money")
-		return internalErrors.NoMoney
 	}
 
-	thirdQuery := fmt.Sprintf(`
-	update %s set coins = coins - $1 where id = $2
-`, usersTable)
+	secondQuery := fmt.Sprintf(`
+	insert into %s (user_id, total, recipient_id) values($1, $2, $3) returning id, created_at
+`, ordersTable)
 
-	_, err = tx.Exec(thirdQuery, price, userId)
+	if err := tx.QueryRow(secondQuery, userId, order.Total, recipientId).Scan(&order.ID, &order.CreatedAt); err != nil {
+		log.Errorw("failed to insert order", zap.Error(err))
+		return models.Order{}, err
+	}
+
+	err := postEntry(tx, journalEntry{
+		kind:     entryPurchase,
+		orderId:  order.ID,
+		postings: []posting{userPosting(userId, -order.Total), accountPosting(ledgerShopRevenue, order.Total)},
+	})
 	if err != nil {
-		log.Errorw("failed to scan row", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
-		}
-		return err
+		log.Errorw("failed to update coins", zap.Error(err))
+		return models.Order{}, err
 	}
 
+	thirdQuery := fmt.Sprintf(`
+	insert into %s (order_id, item_type, unit_price, quantity) values($1, $2, $3, $4)
+`, orderLinesTable)
+
 	fourthQuery := fmt.Sprintf(`
-	select count(*) from %s where user_id = $1 and item_type=$2
+	insert into %s (item_type, user_id, quantity) values($1, $2, $3)
+	on conflict (user_id, item_type) do update set quantity = coalesce(%[1]s.quantity, 0) + excluded.quantity
 `, inventoryTable)
 
-	row := tx.QueryRow(fourthQuery, userId, item)
-
-	var countInventory int
+	owner := userId
+	if recipientId != nil {
+		owner = *recipientId
+	}
+	for _, line := range order.Lines {
+		if _, err := tx.Exec(thirdQuery, order.ID, line.Item, line.UnitPrice, line.Quantity); err != nil {
+			log.Errorw("failed to insert order line", zap.Error(err))
+			return models.Order{}, err
+		}
 
-	if err = row.Scan(&countInventory); err != nil {
-		log.Errorw("failed to scan row", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		if _, err := tx.Exec(fourthQuery, line.Item, owner, line.Quantity); err != nil {
+			log.Errorw("failed to add item to inventory", zap.Error(err))
+			return models.Order{}, err
 		}
-		return err
 	}
 
-	var fifthQuery string
+	return order, nil
+}
 
-	if countInventory == 0 {
-		fifthQuery = fmt.Sprintf(`
-		insert into %s (item_type, user_id, quantity) values($1,$2,1)
-`, inventoryTable)
+// checkItem returns the price of a cart line and whether the item has a
+// limited stock, which the caller still has to take the quantity from.
+func checkItem(tx *sql.Tx, userId int, line models.CartLine) (int, bool, error) {
+	itemQuery := fmt.Sprintf(`
+	select price, stock, per_user_limit from %s where name = $1 and retired_at is null
+`, itemsTable)
 
-	} else {
-		fifthQuery = fmt.Sprintf(`
-	update %s set item_type = $1, quantity = quantity + 1 where user_id = $2
-`, inventoryTable)
+	var (
+		price        int
+		stock, limit sql.NullInt64
+	)
+	if err := tx.QueryRow(itemQuery, line.Item).Scan(&price, &stock, &limit); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, fmt.Errorf("%w: %s", internalErrors.ItemNotFound, line.Item)
+		}
+		return 0, false, err
 	}
 
-	_, err = tx.Exec(fifthQuery, item, userId)
+	if stock.Valid && stock.Int64 < int64(line.Quantity) {
+		return 0, false, fmt.Errorf("%w: %s", internalErrors.SoldOut, line.Item)
+	}
 
-	if err != nil {
-		log.Errorw("failed to scan row", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+	if limit.Valid {
+		// the buyer row is locked, so the orders of the buyer can not change
+		// meanwhile, units of refunded orders may be bought again
+		limitQuery := fmt.Sprintf(`
+	select coalesce(sum(l.quantity), 0)
+	from %s l
+	join %s o on o.id = l.order_id
+	where o.user_id = $1 and l.item_type = $2
+		and not exists (select 1 from %s r where r.order_id = o.id and r.status = $3)
+`, orderLinesTable, ordersTable, returnsTable)
+
+		var bought int64
+		if err := tx.QueryRow(limitQuery, userId, line.Item, models.ReturnApproved).Scan(&bought); err != nil {
+			return 0, false, err
+		}
+		if bought+int64(line.Quantity) > limit.Int64 {
+			return 0, false, fmt.Errorf("%w: %s", internalErrors.PurchaseLimitReached, line.Item)
 		}
-		return err
 	}
 
-	return tx.Commit()
-
+	return price, stock.Valid, nil
 }
 
+// SendCoin transfers the coins to req.ToUser, an unknown recipient is
+// sql.ErrNoRows.
 func (r *ShopStore) SendCoin(ctx context.Context, userId int, req models.SendCoinRequest) error {
 	log := logger.LoggerFromContext(ctx)
-	tx, err := r.Db.Begin()
-	if err != nil {
-		log.Errorw("failed to begin transaction", zap.Error(err))
-		return err
-	}
 
-	fQuery := fmt.Sprintf(`
+	return inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		fQuery := fmt.Sprintf(`
 	select id from %s where username = $1
 `, usersTable)
 
-	var toUserId, coins int
+		var toUserId int
 
-	if err = tx.QueryRow(fQuery, req.ToUser).Scan(&toUserId); err != nil {
-		log.Errorw("failed to scan row", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		if err := tx.QueryRow(fQuery, req.ToUser).Scan(&toUserId); err != nil {
+			log.Errorw("failed to scan row", zap.Error(err))
+			return err
 		}
+
+		_, err := transferCoins(ctx, tx, userId, toUserId, req.Amount, req.Memo)
 		return err
-	}
+	})
+}
+
+// transferCoins moves amount coins from userId to toUserId and returns the
+// id of the coin transaction. It locks the balances of both users in the
+// order of their ids, so two opposite transfers between the same users can
+// not deadlock.
+func transferCoins(ctx context.Context, tx *sql.Tx, userId, toUserId, amount int, memo string) (int64, error) {
+	log := logger.LoggerFromContext(ctx)
 
 	sQuery := fmt.Sprintf(`
-	select coins from %s where id = $1
+	select id, coins from %s where id in ($1, $2) order by id for update
 `, usersTable)
 
-	if err = tx.QueryRow(sQuery, userId).Scan(&coins); err != nil {
-		log.Errorw("failed to scan row", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
-		}
-
-		return err
+	rows, err := tx.Query(sQuery, userId, toUserId)
+	if err != nil {
+		log.Errorw("failed to lock balances", zap.Error(err))
+		return 0, err
 	}
 
-	if coins < req.Amount {
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+	coins, found := 0, false
+	for rows.Next() {
+		var id, balance int
+		if err = rows.Scan(&id, &balance); err != nil {
+			_ = rows.Close()
+			log.Errorw("failed to scan row", zap.Error(err))
+			return 0, err
+		}
+		if id == userId {
+			coins, found = balance, true
 		}
-		log.Errorw("user doesnt have enough money", zap.Int("amount", req.Amount), zap.Int("userCoins", coins))
-		return internalErrors.NoMoney
+	}
+	if err = rows.Err(); err != nil {
+		log.Errorw("failed to lock balances", zap.Error(err))
+		return 0, err
+	}
+	if !found {
+		return 0, sql.ErrNoRows
+	}
+
+	if coins < amount {
+		log.Errorw("user doesnt have enough money", zap.Int("amount", amount), zap.Int("userCoins", coins))
+		return 0, internalErrors.NoMoney
 	}
 
 	firstQuery := fmt.Sprintf(`
-	update %s set coins = coins + $1 where id = $2
-`, usersTable)
+	insert into %s(sender_id, receiver_id, amount, memo) values($1, $2, $3, nullif($4, '')) returning id
+`, coinTxTable)
 
-	_, err = tx.Exec(firstQuery, req.Amount, toUserId)
+	var coinTxId int64
+	if err = tx.QueryRow(firstQuery, userId, toUserId, amount, memo).Scan(&coinTxId); err != nil {
+		log.Errorw("failed to insert coin", zap.Error(err))
+		return 0, err
+	}
 
+	err = postEntry(tx, journalEntry{
+		kind:     models.CoinTxTransfer,
+		coinTxId: coinTxId,
+		postings: []posting{userPosting(userId, -amount), userPosting(toUserId, amount)},
+	})
 	if err != nil {
 		log.Errorw("failed to send coin", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
-		}
-		return err
+		return 0, err
 	}
 
-	secondQuery := fmt.Sprintf(`
-	update %s set coins = coins - $1 where id = $2
+	return coinTxId, nil
+}
+
+// TransferItem moves units of an item from the inventory of the user to the
+// inventory of req.ToUser and records the move. Both users are locked in the
+// order of their ids, as in transferCoins.
+func (r *ShopStore) TransferItem(ctx context.Context, userId int, req models.ItemTransferRequest) (models.ItemTransfer, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	transfer := models.ItemTransfer{ToUser: req.ToUser, Item: req.Item, Quantity: req.Quantity}
+	err := inTx(ctx, r.Db, func(tx *sql.Tx) error {
+		firstQuery := fmt.Sprintf(`
+	select id from %s where username = $1
 `, usersTable)
 
-	_, err = tx.Exec(secondQuery, req.Amount, userId)
+		var toUserId int
+		if err := tx.QueryRow(firstQuery, req.ToUser).Scan(&toUserId); err != nil {
+			log.Errorw("failed to scan row", zap.Error(err))
+			return err
+		}
+		if toUserId == userId {
+			return internalErrors.TransferToSelf
+		}
 
+		secondQuery := fmt.Sprintf(`
+	select id from %s where id in ($1, $2) order by id for update
+`, usersTable)
+
+		rows, err := tx.Query(secondQuery, userId, toUserId)
+		if err != nil {
+			log.Errorw("failed to lock users", zap.Error(err))
+			return err
+		}
+		if err = rows.Close(); err != nil {
+			return err
+		}
+
+		if err = takeItem(tx, userId, req.Item, req.Quantity); err != nil {
+			if !errors.Is(err, internalErrors.NotEnoughItems) {
+				log.Errorw("failed to take item", zap.Error(err))
+			}
+			return err
+		}
+
+		thirdQuery := fmt.Sprintf(`
+	insert into %s (item_type, user_id, quantity) values($1, $2, $3)
+	on conflict (user_id, item_type) do update set quantity = coalesce(%[1]s.quantity, 0) + excluded.quantity
+`, inventoryTable)
+
+		if _, err = tx.Exec(thirdQuery, req.Item, toUserId, req.Quantity); err != nil {
+			log.Errorw("failed to add item to inventory", zap.Error(err))
+			return err
+		}
+
+		fourthQuery := fmt.Sprintf(`
+	insert into %s (sender_id, receiver_id, item_type, quantity) values($1, $2, $3, $4)
+	returning id, created_at
+`, itemTransfersTable)
+
+		if err = tx.QueryRow(fourthQuery, userId, toUserId, req.Item, req.Quantity).Scan(&transfer.ID, &transfer.CreatedAt); err != nil {
+			log.Errorw("failed to insert item transfer", zap.Error(err))
+			return err
+		}
+
+		return nil
+	})
 	if err != nil {
-		log.Errorw("failed to send coin", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		return models.ItemTransfer{}, err
+	}
+
+	return transfer, nil
+}
+
+// takeItem removes units of an item from the inventory of the user, the row
+// goes away when no units are left.
+func takeItem(tx *sql.Tx, userId int, item string, quantity int) error {
+	updateQuery := fmt.Sprintf(`
+	update %s set quantity = quantity - $1 where user_id = $2 and item_type = $3 and quantity >= $1
+	returning quantity
+`, inventoryTable)
+
+	var left int
+	if err := tx.QueryRow(updateQuery, quantity, userId, item).Scan(&left); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", internalErrors.NotEnoughItems, item)
 		}
 		return err
 	}
 
-	thirdQuery := fmt.Sprintf(`
-	insert into %s(sender_id, receiver_id, amount) values($1, $2, $3)
-`, coinTxTable)
+	if left == 0 {
+		deleteQuery := fmt.Sprintf(`
+	delete from %s where user_id = $1 and item_type = $2
+`, inventoryTable)
 
-	_, err = tx.Exec(thirdQuery, userId, toUserId, req.Amount)
-	if err != nil {
-		log.Errorw("failed to insert coin", zap.Error(err))
-		rollbackErr := tx.Rollback()
-		if rollbackErr != nil {
-			log.Errorw("failed to rollback transaction", zap.Error(rollbackErr))
+		if _, err := tx.Exec(deleteQuery, userId, item); err != nil {
+			return err
 		}
-		return err
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+func (r *ShopStore) GetOrders(ctx context.Context, filter models.OrdersFilter) ([]models.Order, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+	addCondition := func(format string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	if filter.Item != "" {
+		addCondition("exists (select 1 from "+orderLinesTable+" l where l.order_id = "+ordersTable+".id and l.item_type = %s)", filter.Item)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= %s", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < %s", filter.To)
+	}
+	if filter.AfterID != 0 {
+		addCondition("(created_at, id) < (%s, %s)", filter.AfterCreatedAt, filter.AfterID)
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, orderColumns, ordersTable, strings.Join(conditions, " AND "), len(args))
+
+	orders := make([]models.Order, 0, filter.Limit)
+	if err := r.Db.Select(&orders, query, args...); err != nil {
+		log.Errorw("GetOrders", zap.Error(err))
+		return nil, err
+	}
+
+	if err := r.loadOrderLines(orders); err != nil {
+		log.Errorw("GetOrders lines", zap.Error(err))
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetTransactions returns a page of the coin transactions the user sent or
+// received, newest first. The counterparty of anything but a transfer is
+// "system".
+func (r *ShopStore) GetTransactions(ctx context.Context, filter models.TransactionsFilter) ([]models.Transaction, error) {
+	log := logger.LoggerFromContext(ctx)
+
+	args := []interface{}{filter.UserID}
+	var conditions []string
+	addCondition := func(format string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	switch filter.Direction {
+	case models.DirectionIn:
+		conditions = append(conditions, "ct.receiver_id = $1")
+	case models.DirectionOut:
+		conditions = append(conditions, "ct.sender_id = $1")
+	default:
+		conditions = append(conditions, "(ct.sender_id = $1 OR ct.receiver_id = $1)")
+	}
+	if filter.Counterparty != "" {
+		addCondition("ct.kind = 'transfer' AND u.username = %s", filter.Counterparty)
+	}
+	if filter.MinAmount != 0 {
+		addCondition("ct.amount >= %s", filter.MinAmount)
+	}
+	if filter.MaxAmount != 0 {
+		addCondition("ct.amount <= %s", filter.MaxAmount)
+	}
+	if !filter.From.IsZero() {
+		addCondition("ct.timestamp >= %s", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("ct.timestamp < %s", filter.To)
+	}
+	if filter.AfterID != 0 {
+		addCondition("(ct.timestamp, ct.id) < (%s, %s)", filter.AfterTimestamp, filter.AfterID)
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT ct.id,
+			CASE WHEN ct.receiver_id = $1 THEN 'in' ELSE 'out' END AS direction,
+			CASE WHEN ct.kind = 'transfer' THEN COALESCE(u.username, 'Unknown') ELSE 'system' END AS counterparty,
+			ct.amount, ct.kind, COALESCE(ct.reason, '') AS reason, COALESCE(ct.memo, '') AS memo, ct.timestamp
+		FROM %s ct
+		LEFT JOIN %s u ON u.id = CASE WHEN ct.receiver_id = $1 THEN ct.sender_id ELSE ct.receiver_id END
+		WHERE %s
+		ORDER BY ct.timestamp DESC, ct.id DESC
+		LIMIT $%d
+	`, coinTxTable, usersTable, strings.Join(conditions, " AND "), len(args))
+
+	transactions := make([]models.Transaction, 0, filter.Limit)
+	if err := r.Db.Select(&transactions, query, args...); err != nil {
+		log.Errorw("GetTransactions", zap.Error(err))
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// loadOrderLines fills in the lines of the orders with a single query.
+func (r *ShopStore) loadOrderLines(orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
 
+	ids := make([]int64, len(orders))
+	byId := make(map[int64]*models.Order, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].ID
+		orders[i].Lines = []models.OrderLine{}
+		byId[orders[i].ID] = &orders[i]
+	}
+
+	query := fmt.Sprintf(`
+		SELECT order_id, item_type, unit_price, quantity
+		FROM %s
+		WHERE order_id = ANY($1)
+		ORDER BY order_id, id
+	`, orderLinesTable)
+
+	var lines []models.OrderLine
+	if err := r.Db.Select(&lines, query, pq.Array(ids)); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		order := byId[line.OrderID]
+		order.Lines = append(order.Lines, line)
+	}
+	return nil
 }
@@ -2,14 +2,19 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 
 	"errors"
+	internalErrors "testAlvtoShp/internal/errors"
 	"testAlvtoShp/internal/models"
 )
 
@@ -32,6 +37,53 @@ func TestShopStore_GetUserInfo(t *testing.T) {
 			expectedResult: models.InfoResponse{},
 			expectErr:      true,
 		},
+		{
+			name:   "History is capped",
+			userId: 42,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`(?i)^SELECT id, username, coins FROM ` + usersTable).WithArgs(42).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username", "coins"}).AddRow(42, "alice", 100))
+				mock.ExpectQuery(`(?i)^SELECT id, user_id, item_type, quantity FROM ` + inventoryTable).WithArgs(42).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "item_type", "quantity"}))
+				mock.ExpectQuery(`WHERE ct.receiver_id = \$1 ORDER BY ct.timestamp DESC, ct.id DESC LIMIT \$2$`).WithArgs(42, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "kind", "reason", "memo", "timestamp", "from_user"}).
+						AddRow(8, 30, "transfer", "", "lunch", orderCreatedAt, "bob").
+						AddRow(3, 20, "grant", "bonus", "", orderCreatedAt, "system"))
+				mock.ExpectQuery(`WHERE ct.sender_id = \$1 ORDER BY ct.timestamp DESC, ct.id DESC LIMIT \$2$`).WithArgs(42, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "amount", "kind", "reason", "memo", "timestamp", "to_user"}).
+						AddRow(9, 10, "transfer", "", "", orderCreatedAt, "bob"))
+				mock.ExpectQuery(`FROM `+ordersTable+` WHERE user_id = \$1`).WithArgs(42, recentPurchasesLimit).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "total", "created_at", "gift_to"}))
+				mock.ExpectQuery(`WHERE o.recipient_id = \$1`).WithArgs(42, recentGiftsLimit).
+					WillReturnRows(sqlmock.NewRows([]string{"from_user", "item_type", "quantity", "created_at"}))
+				mock.ExpectQuery(`where cr.payer_id = \$1 and cr.status = \$2 and cr.expires_at > now\(\)`).
+					WithArgs(42, models.CoinRequestPending, pendingCoinRequestsLimit).
+					WillReturnRows(sqlmock.NewRows(coinRequestColumnNames).
+						AddRow(5, "bob", "alice", 40, "pizza", "pending", orderCreatedAt, orderCreatedAt.Add(time.Hour), nil))
+				mock.ExpectQuery(`where cr.requester_id = \$1 and cr.status = \$2 and cr.expires_at > now\(\)`).
+					WithArgs(42, models.CoinRequestPending, pendingCoinRequestsLimit).
+					WillReturnRows(sqlmock.NewRows(coinRequestColumnNames))
+			},
+			expectedResult: models.InfoResponse{
+				Coins: 100,
+				CoinHistory: models.CoinHistory{
+					Received: []models.ReceivedTransaction{
+						{ID: 8, FromUser: "bob", Amount: 30, Kind: "transfer", Memo: "lunch", Timestamp: orderCreatedAt},
+						{ID: 3, FromUser: "system", Amount: 20, Kind: "grant", Reason: "bonus", Timestamp: orderCreatedAt},
+					},
+					Sent: []models.SentTransaction{{ID: 9, ToUser: "bob", Amount: 10, Kind: "transfer", Timestamp: orderCreatedAt}},
+				},
+				RecentPurchases: []models.Order{},
+				ReceivedGifts:   []models.ReceivedGift{},
+				CoinRequests: models.CoinRequests{
+					Incoming: []models.CoinRequest{{
+						ID: 5, Requester: "bob", Payer: "alice", Amount: 40, Memo: "pizza", Status: models.CoinRequestPending,
+						CreatedAt: orderCreatedAt, ExpiresAt: orderCreatedAt.Add(time.Hour),
+					}},
+					Outgoing: []models.CoinRequest{},
+				},
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -44,7 +96,7 @@ func TestShopStore_GetUserInfo(t *testing.T) {
 
 			tc.setupMock(mock)
 
-			result, err := shopStore.GetUserInfo(context.Background(), tc.userId)
+			result, err := shopStore.GetUserInfo(context.Background(), tc.userId, 2)
 			if tc.expectErr {
 				assert.Error(t, err)
 			} else {
@@ -56,138 +108,383 @@ func TestShopStore_GetUserInfo(t *testing.T) {
 	}
 }
 
-func TestShopStore_BuyItem(t *testing.T) {
+var orderCreatedAt = time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+
+func expectLockBuyer(mock sqlmock.Sqlmock, userId, coins int) {
+	mock.ExpectQuery(`(?i)^select coins from\s+` + usersTable + `\s+where id = \$1 for update$`).WithArgs(userId).
+		WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(coins))
+}
+
+// expectItem expects the price lookup of an item, stock and limit are nil
+// for an unlimited item.
+func expectItem(mock sqlmock.Sqlmock, item string, price int, stock, limit interface{}) {
+	mock.ExpectQuery(`(?i)^select price, stock, per_user_limit from\s+` + itemsTable + `\s+where name = \$1 and retired_at is null$`).
+		WithArgs(item).
+		WillReturnRows(sqlmock.NewRows([]string{"price", "stock", "per_user_limit"}).AddRow(price, stock, limit))
+}
+
+func expectBought(mock sqlmock.Sqlmock, userId int, item string, bought int) {
+	mock.ExpectQuery(`(?i)^select coalesce\(sum\(l.quantity\), 0\) from\s+`+orderLinesTable+` l join `+ordersTable+
+		` o on o.id = l.order_id where o.user_id = \$1 and l.item_type = \$2`+
+		` and not exists \(select 1 from `+returnsTable+` r where r.order_id = o.id and r.status = \$3\)$`).
+		WithArgs(userId, item, models.ReturnApproved).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(bought))
+}
+
+func expectStockTaken(mock sqlmock.Sqlmock, item string, quantity int, affected int64) {
+	mock.ExpectExec(`(?i)^update\s+`+itemsTable+`\s+set stock = stock - \$1 where name = \$2 and stock >= \$1$`).
+		WithArgs(quantity, item).
+		WillReturnResult(sqlmock.NewResult(0, affected))
+}
+
+// expectCharge expects the order to be inserted and the buyer to be charged
+// for it, recipientId is nil unless the order is a gift.
+func expectCharge(mock sqlmock.Sqlmock, userId, total int, orderId int64, recipientId interface{}) {
+	mock.ExpectQuery(`(?i)^insert into\s+`+ordersTable+`\s+\(user_id, total, recipient_id\) values\(\$1, \$2, \$3\) returning id, created_at$`).
+		WithArgs(userId, total, recipientId).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(orderId, orderCreatedAt))
+	expectEntry(mock, orderId+100, journalEntry{
+		kind:     entryPurchase,
+		orderId:  orderId,
+		postings: []posting{userPosting(userId, -total), accountPosting(ledgerShopRevenue, total)},
+	})
+}
+
+func expectOrderLine(mock sqlmock.Sqlmock, userId int, orderId int64, item string, price, quantity int) {
+	mock.ExpectExec(`(?i)^insert into\s+`+orderLinesTable+`\s+\(order_id, item_type, unit_price, quantity\) values\(\$1, \$2, \$3, \$4\)$`).
+		WithArgs(orderId, item, price, quantity).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`(?i)^insert into\s+`+inventoryTable+`\s+\(item_type, user_id, quantity\) values\(\$1, \$2, \$3\) `+
+		`on conflict \(user_id, item_type\) do update set quantity = coalesce\(`+inventoryTable+`\.quantity, 0\) \+ excluded\.quantity$`).
+		WithArgs(item, userId, quantity).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestShopStore_Checkout(t *testing.T) {
 	tests := []struct {
-		name           string
-		userId         int
-		item           string
-		coins          int
-		price          int
-		invCount       int
-		setupMock      func(mock sqlmock.Sqlmock, userId int, item string, coins, price, invCount int)
-		expectErr      bool
-		expectedErrVal error
+		name        string
+		cart        []models.CartLine
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.Order
+		expectedErr error
 	}{
 		{
-			name:     "Success_Insert (нет записи в инвентаре)",
-			userId:   42,
-			item:     "sword",
-			coins:    100,
-			price:    50,
-			invCount: 0,
-			setupMock: func(mock sqlmock.Sqlmock, userId int, item string, coins, price, invCount int) {
+			name: "Success",
+			cart: []models.CartLine{{Item: "sword", Quantity: 1}},
+			setupMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 100)
+				expectItem(mock, "sword", 50, nil, nil)
+				expectCharge(mock, 42, 50, 7, nil)
+				expectOrderLine(mock, 42, 7, "sword", 50, 1)
+				mock.ExpectCommit()
+			},
+			expected: models.Order{ID: 7, Total: 50, CreatedAt: orderCreatedAt, Lines: []models.OrderLine{
+				{Item: "sword", UnitPrice: 50, Quantity: 1},
+			}},
+		},
+		{
+			name: "Several items and units",
+			cart: []models.CartLine{{Item: "cup", Quantity: 2}, {Item: "pen", Quantity: 10}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 1000)
+				expectItem(mock, "cup", 20, nil, nil)
+				expectItem(mock, "pen", 10, 50, 20)
+				expectBought(mock, 42, "pen", 5)
+				expectStockTaken(mock, "pen", 10, 1)
+				expectCharge(mock, 42, 140, 8, nil)
+				expectOrderLine(mock, 42, 8, "cup", 20, 2)
+				expectOrderLine(mock, 42, 8, "pen", 10, 10)
+				mock.ExpectCommit()
+			},
+			expected: models.Order{ID: 8, Total: 140, CreatedAt: orderCreatedAt, Lines: []models.OrderLine{
+				{Item: "cup", UnitPrice: 20, Quantity: 2},
+				{Item: "pen", UnitPrice: 10, Quantity: 10},
+			}},
+		},
+		{
+			name: "Insufficient funds for the whole cart",
+			cart: []models.CartLine{{Item: "cup", Quantity: 2}, {Item: "pen", Quantity: 3}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 60)
+				expectItem(mock, "cup", 20, nil, nil)
+				expectItem(mock, "pen", 10, nil, nil)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.NoMoney,
+		},
+		{
+			name: "Unknown item",
+			cart: []models.CartLine{{Item: "axe", Quantity: 1}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 100)
+				mock.ExpectQuery(`(?i)^select price, stock, per_user_limit from\s+` + itemsTable).WithArgs("axe").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.ItemNotFound,
+		},
+		{
+			name: "Error in price query",
+			cart: []models.CartLine{{Item: "axe", Quantity: 1}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 100)
+				mock.ExpectQuery(`(?i)^select price, stock, per_user_limit from\s+` + itemsTable).WithArgs("axe").
+					WillReturnError(errors.New("price query error"))
+				mock.ExpectRollback()
+			},
+			expectedErr: errors.New("price query error"),
+		},
+		{
+			name: "Not enough in stock",
+			cart: []models.CartLine{{Item: "pink-hoody", Quantity: 2}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 1000)
+				expectItem(mock, "pink-hoody", 500, 1, nil)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.SoldOut,
+		},
+		{
+			name: "Sold out by a concurrent purchase",
+			cart: []models.CartLine{{Item: "pink-hoody", Quantity: 1}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 1000)
+				expectItem(mock, "pink-hoody", 500, 1, nil)
+				expectStockTaken(mock, "pink-hoody", 1, 0)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.SoldOut,
+		},
+		{
+			name: "Limit reached",
+			cart: []models.CartLine{{Item: "pink-hoody", Quantity: 1}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 1000)
+				expectItem(mock, "pink-hoody", 500, 10, 2)
+				expectBought(mock, 42, "pink-hoody", 2)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.PurchaseLimitReached,
+		},
+		{
+			// the returned order is not counted by the limit query, only
+			// the one unit that was kept
+			name: "Buy again after a return",
+			cart: []models.CartLine{{Item: "pink-hoody", Quantity: 1}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectLockBuyer(mock, 42, 1000)
+				expectItem(mock, "pink-hoody", 500, 10, 2)
+				expectBought(mock, 42, "pink-hoody", 1)
+				expectStockTaken(mock, "pink-hoody", 1, 1)
+				expectCharge(mock, 42, 500, 9, nil)
+				expectOrderLine(mock, 42, 9, "pink-hoody", 500, 1)
+				mock.ExpectCommit()
+			},
+			expected: models.Order{ID: 9, Total: 500, CreatedAt: orderCreatedAt, Lines: []models.OrderLine{
+				{Item: "pink-hoody", UnitPrice: 500, Quantity: 1},
+			}},
+		},
+	}
 
-				queryCoins := regexp.MustCompile(`(?i)^select coins from\s+` + usersTable + `\s+where id = \$1$`)
-				mock.ExpectQuery(queryCoins.String()).WithArgs(userId).
-					WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(coins))
-
-				queryPrice := regexp.MustCompile(`(?i)^select price from\s+` + itemsTable + `\s+where name = \$1$`)
-				mock.ExpectQuery(queryPrice.String()).WithArgs(item).
-					WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(price))
-
-				queryUpdateCoins := regexp.MustCompile(`(?i)^update\s+` + usersTable + `\s+set coins = coins - \$1 where id = \$2$`)
-				mock.ExpectExec(queryUpdateCoins.String()).WithArgs(price, userId).
-					WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			shopStore := NewShopStore(sqlx.NewDb(db, "sqlmock"))
 
-				queryInvCount := regexp.MustCompile(`(?i)^select count\(\*\) from\s+` + inventoryTable + `\s+where user_id = \$1 and item_type=\$2$`)
-				mock.ExpectQuery(queryInvCount.String()).WithArgs(userId, item).
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(invCount))
+			tc.setupMock(mock)
 
-				if invCount == 0 {
-					_ = regexp.MustCompile(`(?i)^insert into\s+` + inventoryTable + `\s+\(item_type, user_id, quantity\)\s+values\(\$1,\$2,1\)`).
-						String()
-				} else {
-					_ = regexp.MustCompile(`(?i)^update\s+` + inventoryTable + `\s+set item_type = \$1, quantity = quantity \+ 1 where user_id = \$2`).
-						String()
+			order, err := shopStore.Checkout(context.Background(), 42, tc.cart)
+			if tc.expectedErr != nil {
+				assert.Error(t, err)
+				if !strings.Contains(tc.expectedErr.Error(), "query error") {
+					assert.ErrorIs(t, err, tc.expectedErr)
 				}
-				queryInvRegex := regexp.MustCompile(`(?i)^(insert into|update)\s+` + inventoryTable)
-				mock.ExpectExec(queryInvRegex.String()).WithArgs(item, userId).
-					WillReturnResult(sqlmock.NewResult(0, 1))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, order)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShopStore_Gift(t *testing.T) {
+	recipient := regexp.QuoteMeta("select id from " + usersTable + " where username = $1")
 
+	tests := []struct {
+		name        string
+		toUser      string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.Order
+		expectedErr error
+	}{
+		{
+			name:   "Success",
+			toUser: "bob",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(recipient).WithArgs("bob").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(43))
+				expectLockBuyer(mock, 42, 100)
+				expectItem(mock, "cup", 20, nil, nil)
+				expectCharge(mock, 42, 40, 7, 43)
+				expectOrderLine(mock, 43, 7, "cup", 20, 2)
 				mock.ExpectCommit()
 			},
-			expectErr: false,
+			expected: models.Order{ID: 7, Total: 40, CreatedAt: orderCreatedAt, GiftTo: "bob",
+				Lines: []models.OrderLine{{Item: "cup", UnitPrice: 20, Quantity: 2}}},
+		},
+		{
+			name:   "Unknown recipient",
+			toUser: "nobody",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(recipient).WithArgs("nobody").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			expectedErr: sql.ErrNoRows,
 		},
 		{
-			name:     "Success_Update (есть запись в инвентаре)",
-			userId:   42,
-			item:     "shield",
-			coins:    100,
-			price:    50,
-			invCount: 1,
-			setupMock: func(mock sqlmock.Sqlmock, userId int, item string, coins, price, invCount int) {
+			name:   "To self",
+			toUser: "alice",
+			setupMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
+				mock.ExpectQuery(recipient).WithArgs("alice").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.GiftToSelf,
+		},
+		{
+			name:   "Not enough coins",
+			toUser: "bob",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(recipient).WithArgs("bob").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(43))
+				expectLockBuyer(mock, 42, 30)
+				expectItem(mock, "cup", 20, nil, nil)
+				mock.ExpectRollback()
+			},
+			expectedErr: internalErrors.NoMoney,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			shopStore := NewShopStore(sqlx.NewDb(db, "sqlmock"))
 
-				queryCoins := regexp.MustCompile(`(?i)^select coins from\s+` + usersTable + `\s+where id = \$1$`)
-				mock.ExpectQuery(queryCoins.String()).WithArgs(userId).
-					WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(coins))
+			tc.setupMock(mock)
 
-				queryPrice := regexp.MustCompile(`(?i)^select price from\s+` + itemsTable + `\s+where name = \$1$`)
-				mock.ExpectQuery(queryPrice.String()).WithArgs(item).
-					WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(price))
+			order, err := shopStore.Gift(context.Background(), 42, tc.toUser, []models.CartLine{{Item: "cup", Quantity: 2}})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, order)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
 
-				queryUpdateCoins := regexp.MustCompile(`(?i)^update\s+` + usersTable + `\s+set coins = coins - \$1 where id = \$2$`)
-				mock.ExpectExec(queryUpdateCoins.String()).WithArgs(price, userId).
-					WillReturnResult(sqlmock.NewResult(0, 1))
+// TestShopStore_Checkout_SeveralPurchases makes sure that every purchase
+// only touches the inventory row of the bought item.
+func TestShopStore_Checkout_SeveralPurchases(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	shopStore := NewShopStore(sqlx.NewDb(db, "sqlmock"))
+
+	items := []string{"cup", "pen", "cup", "book"}
+	for i, item := range items {
+		mock.ExpectBegin()
+		expectLockBuyer(mock, 42, 1000)
+		expectItem(mock, item, 10, nil, nil)
+		expectCharge(mock, 42, 10, int64(i+1), nil)
+		expectOrderLine(mock, 42, int64(i+1), item, 10, 1)
+		mock.ExpectCommit()
+	}
 
-				queryInvCount := regexp.MustCompile(`(?i)^select count\(\*\) from\s+` + inventoryTable + `\s+where user_id = \$1 and item_type=\$2$`)
-				mock.ExpectQuery(queryInvCount.String()).WithArgs(userId, item).
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(invCount))
+	for _, item := range items {
+		_, err := shopStore.Checkout(context.Background(), 42, []models.CartLine{{Item: item, Quantity: 1}})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-				queryInvRegex := regexp.MustCompile(`(?i)^update\s+` + inventoryTable)
-				mock.ExpectExec(queryInvRegex.String()).WithArgs(item, userId).
-					WillReturnResult(sqlmock.NewResult(0, 1))
+func TestShopStore_TransferItem(t *testing.T) {
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	recipient := regexp.QuoteMeta("select id from " + usersTable + " where username = $1")
+	lockUsers := regexp.QuoteMeta("select id from " + usersTable + " where id in ($1, $2) order by id for update")
+	take := regexp.QuoteMeta("update " + inventoryTable + " set quantity = quantity - $1 where user_id = $2 and item_type = $3 and quantity >= $1")
+	expectRecipient := func(mock sqlmock.Sqlmock, id int) {
+		mock.ExpectQuery(recipient).WithArgs("bob").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+	}
 
+	tests := []struct {
+		name        string
+		setupMock   func(mock sqlmock.Sqlmock)
+		expected    models.ItemTransfer
+		expectedErr error
+	}{
+		{
+			name: "Last units",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectRecipient(mock, 43)
+				mock.ExpectQuery(lockUsers).WithArgs(42, 43).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42).AddRow(43))
+				mock.ExpectQuery(take).WithArgs(2, 42, "cup").WillReturnRows(sqlmock.NewRows([]string{"quantity"}).AddRow(0))
+				mock.ExpectExec(regexp.QuoteMeta("delete from "+inventoryTable+" where user_id = $1 and item_type = $2")).
+					WithArgs(42, "cup").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("insert into "+inventoryTable+" (item_type, user_id, quantity) values($1, $2, $3)")).
+					WithArgs("cup", 43, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta("insert into "+itemTransfersTable+" (sender_id, receiver_id, item_type, quantity) values($1, $2, $3, $4)")).
+					WithArgs(42, 43, "cup", 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(5, createdAt))
 				mock.ExpectCommit()
 			},
-			expectErr: false,
+			expected: models.ItemTransfer{ID: 5, ToUser: "bob", Item: "cup", Quantity: 2, CreatedAt: createdAt},
 		},
 		{
-			name:     "Insufficient funds",
-			userId:   42,
-			item:     "potion",
-			coins:    40,
-			price:    50,
-			invCount: 0,
-			setupMock: func(mock sqlmock.Sqlmock, userId int, item string, coins, price, invCount int) {
+			name: "Not enough units",
+			setupMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-
-				queryCoins := regexp.MustCompile(`(?i)^select coins from\s+` + usersTable + `\s+where id = \$1$`)
-				mock.ExpectQuery(queryCoins.String()).WithArgs(userId).
-					WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(coins))
-
-				queryPrice := regexp.MustCompile(`(?i)^select price from\s+` + itemsTable + `\s+where name = \$1$`)
-				mock.ExpectQuery(queryPrice.String()).WithArgs(item).
-					WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow(price))
-
+				expectRecipient(mock, 43)
+				mock.ExpectQuery(lockUsers).WithArgs(42, 43).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42).AddRow(43))
+				mock.ExpectQuery(take).WithArgs(2, 42, "cup").WillReturnRows(sqlmock.NewRows([]string{"quantity"}))
 				mock.ExpectRollback()
 			},
-			expectErr:      true,
-			expectedErrVal: errors.New(""),
+			expectedErr: internalErrors.NotEnoughItems,
 		},
 		{
-			name:     "Error in second query (price)",
-			userId:   42,
-			item:     "axe",
-			coins:    100,
-			price:    0,
-			invCount: 0,
-			setupMock: func(mock sqlmock.Sqlmock, userId int, item string, coins, price, invCount int) {
+			name: "To self",
+			setupMock: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-
-				queryCoins := regexp.MustCompile(`(?i)^select coins from\s+` + usersTable + `\s+where id = \$1$`)
-				mock.ExpectQuery(queryCoins.String()).WithArgs(userId).
-					WillReturnRows(sqlmock.NewRows([]string{"coins"}).AddRow(coins))
-
-				queryPrice := regexp.MustCompile(`(?i)^select price from\s+` + itemsTable + `\s+where name = \$1$`)
-				mock.ExpectQuery(queryPrice.String()).WithArgs(item).
-					WillReturnError(errors.New("price query error"))
-
+				expectRecipient(mock, 42)
 				mock.ExpectRollback()
 			},
-			expectErr:      true,
-			expectedErrVal: errors.New(""),
+			expectedErr: internalErrors.TransferToSelf,
+		},
+		{
+			name: "Unknown recipient",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(recipient).WithArgs("bob").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectRollback()
+			},
+			expectedErr: sql.ErrNoRows,
 		},
 	}
 
@@ -196,22 +493,163 @@ func TestShopStore_BuyItem(t *testing.T) {
 			db, mock, err := sqlmock.New()
 			assert.NoError(t, err)
 			defer db.Close()
-			sqlxDB := sqlx.NewDb(db, "sqlmock")
-			shopStore := NewShopStore(sqlxDB)
+			shopStore := NewShopStore(sqlx.NewDb(db, "sqlmock"))
 
-			tc.setupMock(mock, tc.userId, tc.item, tc.coins, tc.price, tc.invCount)
+			tc.setupMock(mock)
 
-			err = shopStore.BuyItem(context.Background(), tc.userId, tc.item)
-			if tc.expectErr {
-				assert.Error(t, err)
+			transfer, err := shopStore.TransferItem(context.Background(), 42,
+				models.ItemTransferRequest{ToUser: "bob", Item: "cup", Quantity: 2})
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
 			} else {
 				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, transfer)
 			}
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
 
+func TestShopStore_GetTransactions(t *testing.T) {
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "direction", "counterparty", "amount", "kind", "reason", "memo", "timestamp"}
+	selectTransactions := `(?i)^SELECT ct.id, CASE WHEN ct.receiver_id = \$1 THEN 'in' ELSE 'out' END AS direction, ` +
+		`CASE WHEN ct.kind = 'transfer' THEN COALESCE\(u.username, 'Unknown'\) ELSE 'system' END AS counterparty, ` +
+		`ct.amount, ct.kind, COALESCE\(ct.reason, ''\) AS reason, COALESCE\(ct.memo, ''\) AS memo, ct.timestamp FROM ` + coinTxTable + ` ct ` +
+		`LEFT JOIN ` + usersTable + ` u ON u.id = CASE WHEN ct.receiver_id = \$1 THEN ct.sender_id ELSE ct.receiver_id END `
+
+	tests := []struct {
+		name      string
+		filter    models.TransactionsFilter
+		setupMock func(mock sqlmock.Sqlmock)
+		expected  []models.Transaction
+	}{
+		{
+			name:   "Both directions",
+			filter: models.TransactionsFilter{UserID: 42, Limit: 3},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectTransactions+`WHERE \(ct.sender_id = \$1 OR ct.receiver_id = \$1\) `+
+					`ORDER BY ct.timestamp DESC, ct.id DESC LIMIT \$2$`).
+					WithArgs(42, 3).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(9, "out", "bob", 30, "transfer", "", "lunch", at).
+						AddRow(4, "in", "system", 1000, "grant", "welcome", "", at))
+			},
+			expected: []models.Transaction{
+				{ID: 9, Direction: models.DirectionOut, Counterparty: "bob", Amount: 30, Kind: "transfer", Memo: "lunch", Timestamp: at},
+				{ID: 4, Direction: models.DirectionIn, Counterparty: "system", Amount: 1000, Kind: "grant", Reason: "welcome", Timestamp: at},
+			},
+		},
+		{
+			name: "All filters and a cursor",
+			filter: models.TransactionsFilter{
+				UserID: 42, Direction: models.DirectionIn, Counterparty: "bob", MinAmount: 10, MaxAmount: 100,
+				From: from, To: to, AfterTimestamp: at, AfterID: 9, Limit: 3,
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectTransactions+`WHERE ct.receiver_id = \$1 `+
+					`AND ct.kind = 'transfer' AND u.username = \$2 AND ct.amount >= \$3 AND ct.amount <= \$4 `+
+					`AND ct.timestamp >= \$5 AND ct.timestamp < \$6 AND \(ct.timestamp, ct.id\) < \(\$7, \$8\) `+
+					`ORDER BY ct.timestamp DESC, ct.id DESC LIMIT \$9$`).
+					WithArgs(42, "bob", 10, 100, from, to, at, int64(9), 3).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expected: []models.Transaction{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			shopStore := NewShopStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			transactions, err := shopStore.GetTransactions(context.Background(), tc.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, transactions)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShopStore_GetOrders(t *testing.T) {
+	from := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2026, 9, 15, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "total", "created_at", "gift_to"}
+	selectOrders := `(?i)^SELECT id, total, created_at, COALESCE\(\(SELECT username FROM ` + usersTable + ` WHERE ` + usersTable +
+		`\.id = recipient_id\), ''\) AS gift_to FROM ` + ordersTable
+	lines := `(?i)^SELECT order_id, item_type, unit_price, quantity FROM ` + orderLinesTable + ` WHERE order_id = ANY\(\$1\) ORDER BY order_id, id$`
+
+	tests := []struct {
+		name      string
+		filter    models.OrdersFilter
+		setupMock func(mock sqlmock.Sqlmock)
+		expected  []models.Order
+	}{
+		{
+			name:   "First page",
+			filter: models.OrdersFilter{UserID: 42, Limit: 3},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectOrders+` WHERE user_id = \$1 ORDER BY created_at DESC, id DESC LIMIT \$2$`).
+					WithArgs(42, 3).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 60, createdAt, "").AddRow(5, 10, createdAt, "bob"))
+				mock.ExpectQuery(lines).WithArgs(pq.Array([]int64{7, 5})).
+					WillReturnRows(sqlmock.NewRows([]string{"order_id", "item_type", "unit_price", "quantity"}).
+						AddRow(5, "pen", 10, 1).
+						AddRow(7, "cup", 20, 2).
+						AddRow(7, "pen", 10, 2))
+			},
+			expected: []models.Order{
+				{ID: 7, Total: 60, CreatedAt: createdAt, Lines: []models.OrderLine{
+					{OrderID: 7, Item: "cup", UnitPrice: 20, Quantity: 2},
+					{OrderID: 7, Item: "pen", UnitPrice: 10, Quantity: 2},
+				}},
+				{ID: 5, Total: 10, CreatedAt: createdAt, GiftTo: "bob", Lines: []models.OrderLine{
+					{OrderID: 5, Item: "pen", UnitPrice: 10, Quantity: 1},
+				}},
+			},
+		},
+		{
+			name: "All filters and a cursor",
+			filter: models.OrdersFilter{
+				UserID: 42, Item: "pink-hoody", From: from, To: to,
+				AfterCreatedAt: createdAt, AfterID: 7, Limit: 3,
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(selectOrders+
+					` WHERE user_id = \$1 AND exists \(select 1 from `+orderLinesTable+` l where l.order_id = `+ordersTable+`\.id and l.item_type = \$2\) `+
+					`AND created_at >= \$3 AND created_at < \$4 `+
+					`AND \(created_at, id\) < \(\$5, \$6\) ORDER BY created_at DESC, id DESC LIMIT \$7$`).
+					WithArgs(42, "pink-hoody", from, to, createdAt, int64(7), 3).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expected: []models.Order{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+			shopStore := NewShopStore(sqlx.NewDb(db, "sqlmock"))
+
+			tc.setupMock(mock)
+
+			orders, err := shopStore.GetOrders(context.Background(), tc.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, orders)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestShopStore_SendCoin(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -243,6 +681,57 @@ func TestShopStore_SendCoin(t *testing.T) {
 			expectErr:      true,
 			expectedErrVal: errors.New(""),
 		},
+		{
+			name:   "Success locks both users in id order",
+			userId: 42,
+			req: models.SendCoinRequest{
+				ToUser: "alice",
+				Amount: 50,
+				Memo:   "for the pizza",
+			},
+			toUserId:    7,
+			toUserCoins: 30,
+			setupMock: func(mock sqlmock.Sqlmock, userId int, req models.SendCoinRequest, toUserId, toUserCoins int) {
+				expectTransfer(mock, userId, req, toUserId, toUserCoins, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:   "Deadlock is retried",
+			userId: 42,
+			req: models.SendCoinRequest{
+				ToUser: "alice",
+				Amount: 50,
+			},
+			toUserId:    7,
+			toUserCoins: 30,
+			setupMock: func(mock sqlmock.Sqlmock, userId int, req models.SendCoinRequest, toUserId, toUserCoins int) {
+				expectTransfer(mock, userId, req, toUserId, toUserCoins, &pq.Error{Code: deadlockDetectedCode})
+				mock.ExpectRollback()
+				expectTransfer(mock, userId, req, toUserId, toUserCoins, nil)
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:   "Sender without coins",
+			userId: 42,
+			req: models.SendCoinRequest{
+				ToUser: "alice",
+				Amount: 50,
+			},
+			toUserId: 7,
+			setupMock: func(mock sqlmock.Sqlmock, userId int, req models.SendCoinRequest, toUserId, toUserCoins int) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`(?i)^select id from\s+` + usersTable + `\s+where username = \$1$`).WithArgs(req.ToUser).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(toUserId))
+				mock.ExpectQuery(`(?i)^select id, coins from\s+`+usersTable+`\s+where id in \(\$1, \$2\) order by id for update$`).
+					WithArgs(userId, toUserId).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "coins"}).AddRow(toUserId, toUserCoins).AddRow(userId, 10))
+				mock.ExpectRollback()
+			},
+			expectErr:      true,
+			expectedErrVal: internalErrors.NoMoney,
+		},
 	}
 
 	for _, tc := range tests {
@@ -258,6 +747,9 @@ func TestShopStore_SendCoin(t *testing.T) {
 			err = shopStore.SendCoin(context.Background(), tc.userId, tc.req)
 			if tc.expectErr {
 				assert.Error(t, err)
+				if errors.Is(tc.expectedErrVal, internalErrors.NoMoney) {
+					assert.ErrorIs(t, err, internalErrors.NoMoney)
+				}
 			} else {
 				assert.NoError(t, err)
 			}
@@ -265,3 +757,67 @@ func TestShopStore_SendCoin(t *testing.T) {
 		})
 	}
 }
+
+// expectTransfer expects a single SendCoin attempt of a sender with 100 coins,
+// failing on the balance lock with lockErr if it is set.
+func expectTransfer(mock sqlmock.Sqlmock, userId int, req models.SendCoinRequest, toUserId, toUserCoins int, lockErr error) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`(?i)^select id from\s+` + usersTable + `\s+where username = \$1$`).WithArgs(req.ToUser).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(toUserId))
+
+	lock := mock.ExpectQuery(`(?i)^select id, coins from\s+`+usersTable+`\s+where id in \(\$1, \$2\) order by id for update$`).
+		WithArgs(userId, toUserId)
+	if lockErr != nil {
+		lock.WillReturnError(lockErr)
+		return
+	}
+	lock.WillReturnRows(sqlmock.NewRows([]string{"id", "coins"}).AddRow(toUserId, toUserCoins).AddRow(userId, 100))
+
+	mock.ExpectQuery(`(?i)^insert into\s+`+coinTxTable+`\s*\(sender_id, receiver_id, amount, memo\) values\(\$1, \$2, \$3, nullif\(\$4, ''\)\) returning id$`).
+		WithArgs(userId, toUserId, req.Amount, req.Memo).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+	expectEntry(mock, 40, journalEntry{
+		kind:     models.CoinTxTransfer,
+		coinTxId: 31,
+		postings: []posting{userPosting(userId, -req.Amount), userPosting(toUserId, req.Amount)},
+	})
+}
+
+func TestInTx_GivesUpAfterMaxAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	for i := 0; i < maxTxAttempts; i++ {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+	}
+
+	calls := 0
+	err = inTx(context.Background(), sqlx.NewDb(db, "sqlmock"), func(tx *sql.Tx) error {
+		calls++
+		return &pq.Error{Code: serializationFailureCode}
+	})
+
+	assert.True(t, isRetryable(err))
+	assert.Equal(t, maxTxAttempts, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInTx_DoesNotRetryOtherErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	calls := 0
+	err = inTx(context.Background(), sqlx.NewDb(db, "sqlmock"), func(tx *sql.Tx) error {
+		calls++
+		return internalErrors.NoMoney
+	})
+
+	assert.ErrorIs(t, err, internalErrors.NoMoney)
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,20 @@
+CREATE TABLE sessions (
+                          id VARCHAR(64) PRIMARY KEY,
+                          user_id INT NOT NULL,
+                          created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                          revoked_at TIMESTAMP,
+                          FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE refresh_tokens (
+                                id SERIAL PRIMARY KEY,
+                                session_id VARCHAR(64) NOT NULL,
+                                token_hash CHAR(64) UNIQUE NOT NULL,
+                                expires_at TIMESTAMP NOT NULL,
+                                used_at TIMESTAMP,
+                                created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                                FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+CREATE INDEX idx_refresh_tokens_session ON refresh_tokens(session_id);
@@ -0,0 +1,11 @@
+CREATE TABLE invites (
+                         id SERIAL PRIMARY KEY,
+                         code_hash CHAR(64) UNIQUE NOT NULL,
+                         created_by INT,
+                         used_by INT,
+                         created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                         expires_at TIMESTAMP NOT NULL,
+                         used_at TIMESTAMP,
+                         FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL,
+                         FOREIGN KEY (used_by) REFERENCES users(id) ON DELETE SET NULL
+);
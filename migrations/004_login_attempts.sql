@@ -0,0 +1,6 @@
+CREATE TABLE login_attempts (
+                                key VARCHAR(128) PRIMARY KEY,
+                                failures INT NOT NULL DEFAULT 0,
+                                last_failure_at TIMESTAMP NOT NULL,
+                                locked_until TIMESTAMP
+);
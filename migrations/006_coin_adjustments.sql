@@ -0,0 +1,7 @@
+ALTER TABLE coin_transactions ADD COLUMN kind VARCHAR(32) NOT NULL DEFAULT 'transfer';
+ALTER TABLE coin_transactions ADD COLUMN reason TEXT;
+ALTER TABLE coin_transactions ADD COLUMN actor_id INT REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE coin_transactions ADD COLUMN idempotency_key VARCHAR(128);
+ALTER TABLE coin_transactions ADD COLUMN balance_after INT;
+
+CREATE UNIQUE INDEX idx_coin_transactions_idempotency ON coin_transactions(idempotency_key) WHERE idempotency_key IS NOT NULL;
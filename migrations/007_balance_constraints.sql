@@ -0,0 +1,4 @@
+UPDATE users SET coins = 0 WHERE coins IS NULL;
+
+ALTER TABLE users ALTER COLUMN coins SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_coins_non_negative CHECK (coins >= 0);
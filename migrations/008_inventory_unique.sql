@@ -0,0 +1,20 @@
+-- Buying an owned item used to rename and bump every inventory row of the
+-- user, leaving several rows of one item type. Fold them into the oldest one.
+UPDATE inventory i
+SET quantity = d.total
+FROM (
+    SELECT MIN(id) AS keep_id, SUM(COALESCE(quantity, 0)) AS total
+    FROM inventory
+    GROUP BY user_id, item_type
+    HAVING COUNT(*) > 1
+) d
+WHERE i.id = d.keep_id;
+
+DELETE FROM inventory i
+USING inventory k
+WHERE i.user_id = k.user_id
+  AND i.item_type = k.item_type
+  AND i.id > k.id;
+
+DROP INDEX idx_inventory_user_item;
+ALTER TABLE inventory ADD CONSTRAINT inventory_user_item_unique UNIQUE (user_id, item_type);
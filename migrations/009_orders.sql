@@ -0,0 +1,12 @@
+CREATE TABLE orders (
+                        id SERIAL PRIMARY KEY,
+                        user_id INT NOT NULL,
+                        item_type VARCHAR(255) NOT NULL,
+                        unit_price INT NOT NULL,
+                        quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0),
+                        created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_orders_user_created ON orders(user_id, created_at DESC, id DESC);
+CREATE INDEX idx_orders_item_created ON orders(item_type, created_at);
@@ -0,0 +1,18 @@
+ALTER TABLE items ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE items ADD COLUMN image_url TEXT;
+ALTER TABLE items ADD COLUMN available BOOLEAN NOT NULL DEFAULT TRUE;
+
+UPDATE items SET description = d.description
+FROM (VALUES
+    ('t-shirt', 'Футболка с логотипом'),
+    ('cup', 'Кружка для чая и кофе'),
+    ('book', 'Блокнот в твёрдой обложке'),
+    ('pen', 'Шариковая ручка'),
+    ('powerbank', 'Внешний аккумулятор'),
+    ('hoody', 'Худи с логотипом'),
+    ('umbrella', 'Складной зонт'),
+    ('socks', 'Носки с принтом'),
+    ('wallet', 'Кошелёк'),
+    ('pink-hoody', 'Розовое худи')
+) AS d(name, description)
+WHERE items.name = d.name;
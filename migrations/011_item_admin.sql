@@ -0,0 +1,17 @@
+ALTER TABLE items ADD COLUMN retired_at TIMESTAMPTZ;
+
+CREATE TABLE item_price_history (
+                                    id SERIAL PRIMARY KEY,
+                                    item_id INT NOT NULL,
+                                    price INT NOT NULL,
+                                    changed_by INT,
+                                    changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                    FOREIGN KEY (item_id) REFERENCES items(id) ON DELETE CASCADE,
+                                    FOREIGN KEY (changed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_item_price_history_item ON item_price_history(item_id, changed_at);
+
+-- seeded prices have been in effect since before anything was recorded
+INSERT INTO item_price_history (item_id, price, changed_at)
+SELECT id, price, 'epoch' FROM items;
@@ -0,0 +1,5 @@
+-- NULL means unlimited for both
+ALTER TABLE items ADD COLUMN stock INT CHECK (stock >= 0);
+ALTER TABLE items ADD COLUMN per_user_limit INT CHECK (per_user_limit > 0);
+
+CREATE INDEX idx_orders_user_item ON orders(user_id, item_type);
@@ -0,0 +1,24 @@
+-- An order may now hold several items, each in its own line. Every existing
+-- order becomes an order with a single line.
+CREATE TABLE order_lines (
+                             id SERIAL PRIMARY KEY,
+                             order_id INT NOT NULL,
+                             item_type VARCHAR(255) NOT NULL,
+                             unit_price INT NOT NULL,
+                             quantity INT NOT NULL CHECK (quantity > 0),
+                             FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+);
+
+INSERT INTO order_lines (order_id, item_type, unit_price, quantity)
+SELECT id, item_type, unit_price, quantity FROM orders;
+
+ALTER TABLE orders ADD COLUMN total INT;
+UPDATE orders SET total = unit_price * quantity;
+ALTER TABLE orders ALTER COLUMN total SET NOT NULL;
+
+ALTER TABLE orders DROP COLUMN item_type;
+ALTER TABLE orders DROP COLUMN unit_price;
+ALTER TABLE orders DROP COLUMN quantity;
+
+CREATE INDEX idx_order_lines_order ON order_lines(order_id);
+CREATE INDEX idx_order_lines_item ON order_lines(item_type, order_id);
@@ -0,0 +1,15 @@
+-- Outcomes of user requests made with an Idempotency-Key header. A row
+-- without a status code belongs to a request that is still running.
+CREATE TABLE idempotency_keys (
+                                  user_id INT NOT NULL,
+                                  key VARCHAR(128) NOT NULL,
+                                  fingerprint VARCHAR(64) NOT NULL,
+                                  status_code INT,
+                                  response BYTEA,
+                                  created_at TIMESTAMPTZ NOT NULL,
+                                  expires_at TIMESTAMPTZ NOT NULL,
+                                  PRIMARY KEY (user_id, key),
+                                  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys(expires_at);
@@ -0,0 +1,19 @@
+-- A user may ask once to return an order. Approving the return takes the
+-- items back and refunds the total of the order.
+CREATE TABLE returns (
+                         id SERIAL PRIMARY KEY,
+                         order_id INT NOT NULL UNIQUE,
+                         user_id INT NOT NULL,
+                         status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+                         amount INT NOT NULL CHECK (amount >= 0),
+                         reason TEXT NOT NULL,
+                         note TEXT NOT NULL DEFAULT '',
+                         created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                         decided_at TIMESTAMPTZ,
+                         decided_by INT,
+                         FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
+                         FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+                         FOREIGN KEY (decided_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_returns_status_created ON returns(status, created_at);
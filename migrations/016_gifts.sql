@@ -0,0 +1,5 @@
+-- An order may be a gift: the sender pays for it and the items go to the
+-- inventory of the recipient.
+ALTER TABLE orders ADD COLUMN recipient_id INT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_orders_recipient_created ON orders(recipient_id, created_at DESC) WHERE recipient_id IS NOT NULL;
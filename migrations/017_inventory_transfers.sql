@@ -0,0 +1,16 @@
+-- Items may move between inventories. Every move is recorded, so where an
+-- item came from can be traced like coins in coin_transactions.
+CREATE TABLE inventory_transfers (
+                                     id SERIAL PRIMARY KEY,
+                                     sender_id INT,
+                                     receiver_id INT,
+                                     item_type VARCHAR(255) NOT NULL,
+                                     quantity INT NOT NULL CHECK (quantity > 0),
+                                     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                     FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE SET NULL,
+                                     FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_inventory_transfers_sender ON inventory_transfers(sender_id, created_at);
+CREATE INDEX idx_inventory_transfers_receiver ON inventory_transfers(receiver_id, created_at);
+CREATE INDEX idx_inventory_transfers_item ON inventory_transfers(item_type, created_at);
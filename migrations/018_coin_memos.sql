@@ -0,0 +1,3 @@
+-- A transfer may carry a short memo from the sender, telling the receiver
+-- what the coins are for.
+ALTER TABLE coin_transactions ADD COLUMN memo VARCHAR(200);
@@ -0,0 +1,68 @@
+-- Every movement of coins is a journal entry whose postings sum to zero. A
+-- posting either moves coins of a user or of a system account, so coins
+-- that enter or leave the users always come from or go to treasury or
+-- shop_revenue. users.coins is a cache of the postings of the user and is
+-- updated in the same transaction as they are written.
+CREATE TABLE ledger_accounts (
+                                 code VARCHAR(32) PRIMARY KEY,
+                                 description TEXT NOT NULL
+);
+
+INSERT INTO ledger_accounts (code, description) VALUES
+    ('treasury', 'coins issued to and withdrawn from users'),
+    ('shop_revenue', 'coins spent on merch and refunded on returns');
+
+CREATE TABLE journal_entries (
+                                 id SERIAL PRIMARY KEY,
+                                 kind VARCHAR(32) NOT NULL,
+                                 coin_tx_id INT,
+                                 order_id INT,
+                                 created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                 FOREIGN KEY (coin_tx_id) REFERENCES coin_transactions(id),
+                                 FOREIGN KEY (order_id) REFERENCES orders(id)
+);
+
+CREATE TABLE postings (
+                          id SERIAL PRIMARY KEY,
+                          entry_id INT NOT NULL,
+                          user_id INT,
+                          account VARCHAR(32),
+                          amount INT NOT NULL CHECK (amount <> 0),
+                          FOREIGN KEY (entry_id) REFERENCES journal_entries(id),
+                          FOREIGN KEY (user_id) REFERENCES users(id),
+                          FOREIGN KEY (account) REFERENCES ledger_accounts(code),
+                          CHECK ((user_id IS NULL) <> (account IS NULL))
+);
+
+CREATE INDEX idx_postings_entry ON postings(entry_id);
+CREATE INDEX idx_postings_user ON postings(user_id) WHERE user_id IS NOT NULL;
+CREATE INDEX idx_postings_account ON postings(account) WHERE account IS NOT NULL;
+CREATE INDEX idx_journal_entries_coin_tx ON journal_entries(coin_tx_id) WHERE coin_tx_id IS NOT NULL;
+CREATE INDEX idx_journal_entries_order ON journal_entries(order_id) WHERE order_id IS NOT NULL;
+
+-- The check runs at commit, when all postings of the entry are written.
+CREATE FUNCTION check_entry_balanced() RETURNS trigger AS $$
+BEGIN
+    IF (SELECT coalesce(sum(amount), 0) FROM postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'journal entry % is not balanced', NEW.entry_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER postings_balanced
+    AFTER INSERT ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE PROCEDURE check_entry_balanced();
+
+-- Balances from before the ledger are opened against the treasury.
+WITH entry AS (
+    INSERT INTO journal_entries (kind) VALUES ('opening') RETURNING id
+), opened AS (
+    INSERT INTO postings (entry_id, user_id, amount)
+    SELECT entry.id, users.id, users.coins FROM entry, users WHERE users.coins <> 0
+    RETURNING amount
+)
+INSERT INTO postings (entry_id, account, amount)
+SELECT (SELECT id FROM entry), 'treasury', -sum(amount) FROM opened
+HAVING sum(amount) <> 0;
@@ -0,0 +1,22 @@
+-- A user may ask another user to pay them. The payer accepts the request,
+-- which transfers the coins, or declines it. A request that is still
+-- pending at expires_at can no longer be decided.
+CREATE TABLE coin_requests (
+                               id SERIAL PRIMARY KEY,
+                               requester_id INT NOT NULL,
+                               payer_id INT NOT NULL,
+                               amount INT NOT NULL CHECK (amount > 0),
+                               memo VARCHAR(200),
+                               status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined')),
+                               created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                               expires_at TIMESTAMPTZ NOT NULL,
+                               decided_at TIMESTAMPTZ,
+                               coin_tx_id INT,
+                               FOREIGN KEY (requester_id) REFERENCES users(id) ON DELETE CASCADE,
+                               FOREIGN KEY (payer_id) REFERENCES users(id) ON DELETE CASCADE,
+                               FOREIGN KEY (coin_tx_id) REFERENCES coin_transactions(id),
+                               CHECK (requester_id <> payer_id)
+);
+
+CREATE INDEX idx_coin_requests_payer_pending ON coin_requests(payer_id, created_at) WHERE status = 'pending';
+CREATE INDEX idx_coin_requests_requester_pending ON coin_requests(requester_id, created_at) WHERE status = 'pending';
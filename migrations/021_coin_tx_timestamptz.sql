@@ -0,0 +1,4 @@
+-- The time of a coin transaction gets a zone, so that filters and cursors
+-- compare it correctly whatever the time zone of the session is. Times
+-- written so far are in UTC, the zone of the database.
+ALTER TABLE coin_transactions ALTER COLUMN timestamp TYPE TIMESTAMPTZ USING timestamp AT TIME ZONE 'UTC';
@@ -0,0 +1,12 @@
+-- Session and refresh token times get a zone. They are written from Go
+-- times, whose offset a column without a zone drops, so on a host that is
+-- not on UTC refresh tokens expired early or late. Times written so far are
+-- taken as UTC, the zone the service runs in.
+ALTER TABLE sessions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ USING revoked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE refresh_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN used_at TYPE TIMESTAMPTZ USING used_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
@@ -0,0 +1,8 @@
+-- Invite times get a zone. expires_at is written from Go times but compared
+-- with now() of the database, so invites expired at the wrong time when the
+-- service and the database were in different zones. Times written so far
+-- are taken as UTC, the zone the service runs in.
+ALTER TABLE invites
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN used_at TYPE TIMESTAMPTZ USING used_at AT TIME ZONE 'UTC';
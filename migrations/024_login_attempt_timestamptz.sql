@@ -0,0 +1,7 @@
+-- Login attempt times get a zone. They are written from Go times, whose
+-- offset a column without a zone drops, so on a host west of UTC the
+-- lockout ended before it began. Times written so far are taken as UTC,
+-- the zone the service runs in.
+ALTER TABLE login_attempts
+    ALTER COLUMN last_failure_at TYPE TIMESTAMPTZ USING last_failure_at AT TIME ZONE 'UTC',
+    ALTER COLUMN locked_until TYPE TIMESTAMPTZ USING locked_until AT TIME ZONE 'UTC';
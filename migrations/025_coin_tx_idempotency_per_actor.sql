@@ -0,0 +1,4 @@
+-- Admins choose their idempotency keys themselves, so a key is only unique
+-- per actor and two admins may use the same one.
+DROP INDEX idx_coin_transactions_idempotency;
+CREATE UNIQUE INDEX idx_coin_transactions_idempotency ON coin_transactions(actor_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
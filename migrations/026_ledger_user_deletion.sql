@@ -0,0 +1,16 @@
+-- Deleting a user keeps the ledger, as coin_transactions does: the postings
+-- of the user lose their owner and stay in their entries, so every entry
+-- still sums to zero. Entries of the orders that are deleted with the user
+-- lose the link to the order.
+ALTER TABLE journal_entries
+    DROP CONSTRAINT journal_entries_coin_tx_id_fkey,
+    DROP CONSTRAINT journal_entries_order_id_fkey,
+    ADD CONSTRAINT journal_entries_coin_tx_id_fkey FOREIGN KEY (coin_tx_id) REFERENCES coin_transactions(id) ON DELETE SET NULL,
+    ADD CONSTRAINT journal_entries_order_id_fkey FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE SET NULL;
+
+-- Both user_id and account are NULL only for a posting of a deleted user.
+ALTER TABLE postings
+    DROP CONSTRAINT postings_user_id_fkey,
+    DROP CONSTRAINT postings_check,
+    ADD CONSTRAINT postings_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL,
+    ADD CONSTRAINT postings_owner_check CHECK (user_id IS NULL OR account IS NULL);
@@ -14,6 +14,7 @@ import (
 	"testAlvtoShp/internal/service"
 	"testAlvtoShp/internal/store"
 	"testing"
+	"time"
 )
 
 type IntegrationTestSuite struct {
@@ -22,8 +23,9 @@ type IntegrationTestSuite struct {
 	client *http.Client
 	db     *sqlx.DB
 
-	tokenA string
-	tokenB string
+	tokenA     string
+	tokenB     string
+	adminToken string
 }
 
 func TestIntegrationSuite(t *testing.T) {
@@ -40,19 +42,62 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 	suite.Require().NoError(err)
 
 	cfg := &config.Config{
-		JwtSecret: "testsecret",
+		JwtSecret:        "testsecret",
+		AccessTokenTTL:   time.Hour,
+		RefreshTokenTTL:  time.Hour,
+		RegistrationMode: service.RegistrationOpen,
+		WelcomeBonus:     1000,
+
+		LoginMaxFailures:      3,
+		LoginMaxFailuresPerIP: 100,
+		LoginLockout:          time.Minute,
+		LoginFailureWindow:    time.Hour,
+		LoginAttemptsStore:    service.LoginAttemptsPostgres,
+
+		IdempotencyKeyTTL: time.Hour,
+		ReturnWindow:      time.Hour,
+		InfoHistoryLimit:  50,
+		CoinRequestTTL:    time.Hour,
 	}
 
-	s := service.NewService(&store.Store{
-		Auth: store.NewAuthStore(suite.db),
-		Shop: store.NewShopStore(suite.db),
+	s, err := service.NewService(&store.Store{
+		Auth:          store.NewAuthStore(suite.db),
+		Session:       store.NewSessionStore(suite.db),
+		LoginAttempts: store.NewLoginAttemptStore(suite.db),
+		Admin:         store.NewAdminStore(suite.db),
+		Shop:          store.NewShopStore(suite.db),
+		Catalog:       store.NewCatalogStore(suite.db),
+		Idempotency:   store.NewIdempotencyStore(suite.db),
+		Returns:       store.NewReturnStore(suite.db),
+		Ledger:        store.NewLedgerStore(suite.db),
+		CoinRequests:  store.NewCoinRequestStore(suite.db),
 	}, cfg)
+	suite.Require().NoError(err)
 
 	h := handler.NewHandler(s)
 
-	router := h.InitRoutes(context.Background())
+	router := h.InitRoutes(context.Background(), cfg)
 	suite.server = httptest.NewServer(router)
 	suite.client = suite.server.Client()
+
+	// the first admin is made like the create-admin subcommand does
+	bootstrap := service.NewAdminBootstrap(store.NewAuthStore(suite.db), store.NewAdminStore(suite.db),
+		service.NewPasswordHasher(cfg.PasswordHashAlgo), cfg)
+	_, _, err = bootstrap.EnsureAdmin(context.Background(), "admin", "passT")
+	suite.Require().NoError(err)
+	suite.adminToken = suite.login("admin", "passT").Token
+}
+
+func (suite *IntegrationTestSuite) login(username, password string) models.AuthResponse {
+	body := `{"username": "` + username + `", "password": "` + password + `"}`
+	resp, err := suite.client.Post(suite.server.URL+"/api/auth", "application/json", strings.NewReader(body))
+	suite.Require().NoError(err)
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	var tokens models.AuthResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&tokens))
+	suite.Require().NoError(resp.Body.Close())
+	return tokens
 }
 
 func (suite *IntegrationTestSuite) TearDownSuite() {
@@ -91,8 +136,141 @@ func (suite *IntegrationTestSuite) TestAuthAndGetInfo() {
 	err = resp.Body.Close()
 
 	suite.Require().NoError(err)
-	suite.Equal(0, infoResp.Coins)
+	suite.Equal(1000, infoResp.Coins)
 	suite.Empty(infoResp.Inventory)
-	suite.Empty(infoResp.CoinHistory.Received)
+	suite.Require().Len(infoResp.CoinHistory.Received, 1)
+	bonus := infoResp.CoinHistory.Received[0]
+	suite.NotZero(bonus.ID)
+	suite.False(bonus.Timestamp.IsZero())
+	suite.Equal(models.ReceivedTransaction{
+		ID:        bonus.ID,
+		FromUser:  "system",
+		Amount:    1000,
+		Kind:      models.CoinTxBonus,
+		Reason:    "welcome bonus",
+		Timestamp: bonus.Timestamp,
+	}, bonus)
 	suite.Empty(infoResp.CoinHistory.Sent)
 }
+
+func (suite *IntegrationTestSuite) TestRefreshAndLogout() {
+	reqBody := `{"username": "userR", "password": "passR"}`
+	resp, err := suite.client.Post(suite.server.URL+"/api/auth", "application/json", strings.NewReader(reqBody))
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	var first models.AuthResponse
+	err = json.NewDecoder(resp.Body).Decode(&first)
+	suite.Require().NoError(err)
+	err = resp.Body.Close()
+	suite.Require().NoError(err)
+	suite.NotEmpty(first.RefreshToken)
+
+	refresh := func(token string) (*http.Response, models.AuthResponse) {
+		body := `{"refreshToken": "` + token + `"}`
+		resp, err := suite.client.Post(suite.server.URL+"/api/auth/refresh", "application/json", strings.NewReader(body))
+		suite.Require().NoError(err)
+		var tokens models.AuthResponse
+		_ = json.NewDecoder(resp.Body).Decode(&tokens)
+		suite.Require().NoError(resp.Body.Close())
+		return resp, tokens
+	}
+
+	resp, second := refresh(first.RefreshToken)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.NotEqual(first.RefreshToken, second.RefreshToken)
+
+	// presenting the rotated token again kills the whole session
+	resp, _ = refresh(first.RefreshToken)
+	suite.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = refresh(second.RefreshToken)
+	suite.Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	suite.Equal(http.StatusUnauthorized, suite.getInfoStatus(second.Token))
+
+	resp, err = suite.client.Post(suite.server.URL+"/api/auth", "application/json", strings.NewReader(reqBody))
+	suite.Require().NoError(err)
+	var third models.AuthResponse
+	err = json.NewDecoder(resp.Body).Decode(&third)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resp.Body.Close())
+	suite.Equal(http.StatusOK, suite.getInfoStatus(third.Token))
+
+	req, err := http.NewRequest("POST", suite.server.URL+"/api/auth/logout", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+third.Token)
+	resp, err = suite.client.Do(req)
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusOK, resp.StatusCode)
+	suite.Require().NoError(resp.Body.Close())
+
+	suite.Equal(http.StatusUnauthorized, suite.getInfoStatus(third.Token))
+}
+
+func (suite *IntegrationTestSuite) getInfoStatus(token string) int {
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/info", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resp.Body.Close())
+	return resp.StatusCode
+}
+
+func (suite *IntegrationTestSuite) TestLoginLockout() {
+	login := func(password string) *http.Response {
+		body := `{"username": "userL", "password": "` + password + `"}`
+		resp, err := suite.client.Post(suite.server.URL+"/api/auth", "application/json", strings.NewReader(body))
+		suite.Require().NoError(err)
+		suite.Require().NoError(resp.Body.Close())
+		return resp
+	}
+
+	suite.Equal(http.StatusOK, login("passL").StatusCode)
+
+	for i := 0; i < 3; i++ {
+		suite.Equal(http.StatusUnauthorized, login("wrong").StatusCode)
+	}
+
+	// the right password does not help while the lockout lasts
+	resp := login("passL")
+	suite.Equal(http.StatusTooManyRequests, resp.StatusCode)
+	suite.Equal("60", resp.Header.Get("Retry-After"))
+}
+
+func (suite *IntegrationTestSuite) TestAdminRoutesRequireRole() {
+	reqBody := `{"username": "userAdm", "password": "passAdm"}`
+	resp, err := suite.client.Post(suite.server.URL+"/api/auth", "application/json", strings.NewReader(reqBody))
+	suite.Require().NoError(err)
+	var tokens models.AuthResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&tokens))
+	suite.Require().NoError(resp.Body.Close())
+
+	lookup := func(token string) int {
+		req, err := http.NewRequest("GET", suite.server.URL+"/api/admin/users/userAdm", nil)
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	suite.Equal(http.StatusForbidden, lookup(tokens.Token))
+
+	_, err = suite.db.Exec(`update users set role = 'auditor' where username = 'userAdm'`)
+	suite.Require().NoError(err)
+
+	// the old token carries the old role and has to be refreshed
+	suite.Equal(http.StatusUnauthorized, lookup(tokens.Token))
+
+	body := `{"refreshToken": "` + tokens.RefreshToken + `"}`
+	resp, err = suite.client.Post(suite.server.URL+"/api/auth/refresh", "application/json", strings.NewReader(body))
+	suite.Require().NoError(err)
+	var refreshed models.AuthResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&refreshed))
+	suite.Require().NoError(resp.Body.Close())
+
+	suite.Equal(http.StatusOK, lookup(refreshed.Token))
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"testAlvtoShp/internal/models"
+)
+
+// TestConcurrentTransfers fires transfers in both directions between a few
+// users at once. Every transfer either succeeds or is rejected for lack of
+// money, and the coins of the group neither appear nor disappear.
+func (suite *IntegrationTestSuite) TestConcurrentTransfers() {
+	const (
+		balance   = 100
+		transfers = 400
+		// stays below max_connections of a default Postgres
+		parallel = 32
+	)
+	users := []string{"racerA", "racerB", "racerC", "racerD"}
+
+	tokens := make([]string, len(users))
+	for i, username := range users {
+		tokens[i] = suite.login(username, "pass"+username).Token
+		suite.setBalance(username, balance)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = make(map[int]int)
+		slots    = make(chan struct{}, parallel)
+	)
+	for i := 0; i < transfers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			from, to := i%len(users), (i/len(users)+i+1)%len(users)
+			body := fmt.Sprintf(`{"toUser": "%s", "amount": %d}`, users[to], i%37+1)
+			req, err := http.NewRequest("POST", suite.server.URL+"/api/sendCoin", strings.NewReader(body))
+			if err != nil {
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tokens[from])
+
+			status := 0
+			if resp, err := suite.client.Do(req); err == nil {
+				status = resp.StatusCode
+				_ = resp.Body.Close()
+			}
+
+			mu.Lock()
+			statuses[status]++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	suite.Equal(transfers, statuses[http.StatusOK]+statuses[http.StatusBadRequest], "unexpected statuses: %v", statuses)
+	suite.NotZero(statuses[http.StatusOK])
+
+	total := 0
+	for _, username := range users {
+		var user models.AdminUserResponse
+		suite.Require().Equal(http.StatusOK, suite.adminRequest("GET", "/api/admin/users/"+username, "", "", &user))
+		suite.GreaterOrEqual(user.Coins, 0, username)
+		total += user.Coins
+	}
+	suite.Equal(balance*len(users), total)
+}
@@ -1,17 +1,21 @@
 package test
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 	"testAlvtoShp/internal/models"
+	"testAlvtoShp/internal/service"
+	"testAlvtoShp/internal/store"
+	"time"
 )
 
 func (suite *IntegrationTestSuite) TestBuyItem() {
-	_, err := suite.db.Exec("UPDATE users SET coins = 100 WHERE username = $1", "userA")
-	suite.Require().NoError(err)
+	suite.setBalance("userA", 100)
 
-	req, err := http.NewRequest("GET", suite.server.URL+"/api/buy/t-shirt", nil)
+	req, err := http.NewRequest("POST", suite.server.URL+"/api/buy/t-shirt", nil)
 	suite.Require().NoError(err)
 	req.Header.Set("Authorization", "Bearer "+suite.tokenA)
 
@@ -48,6 +52,201 @@ func (suite *IntegrationTestSuite) TestBuyItem() {
 	suite.True(found, "t-shirt should be present in inventory")
 }
 
+func (suite *IntegrationTestSuite) TestBuyWithGetIsDisabled() {
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/buy/cup", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+suite.login("userG", "passG").Token)
+
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resp.Body.Close())
+	suite.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func (suite *IntegrationTestSuite) TestBuySeveralUnits() {
+	token := suite.login("userU", "passU").Token
+
+	req, err := http.NewRequest("POST", suite.server.URL+"/api/buy/pen", strings.NewReader(`{"quantity": 3}`))
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resp.Body.Close())
+	suite.Equal(http.StatusOK, resp.StatusCode)
+
+	req, err = http.NewRequest("GET", suite.server.URL+"/api/info", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = suite.client.Do(req)
+	suite.Require().NoError(err)
+
+	var info models.InfoResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&info))
+	suite.Require().NoError(resp.Body.Close())
+	suite.Equal([]models.Item{{Type: "pen", Quantity: 3}}, info.Inventory)
+	suite.Equal(1000-3*10, info.Coins)
+}
+
+func (suite *IntegrationTestSuite) TestBuySeveralItems() {
+	token := suite.login("userI", "passI").Token
+
+	for _, item := range []string{"cup", "pen", "cup", "socks", "cup"} {
+		req, err := http.NewRequest("POST", suite.server.URL+"/api/buy/"+item, nil)
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		suite.Equal(http.StatusOK, resp.StatusCode, item)
+		suite.Require().NoError(resp.Body.Close())
+	}
+
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/info", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+
+	var info models.InfoResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&info))
+	suite.Require().NoError(resp.Body.Close())
+
+	inventory := make(map[string]int)
+	for _, item := range info.Inventory {
+		inventory[item.Type] += item.Quantity
+	}
+	suite.Equal(map[string]int{"cup": 3, "pen": 1, "socks": 1}, inventory)
+	suite.Len(info.Inventory, 3)
+	suite.Equal(1000-3*20-10-10, info.Coins)
+}
+
+func (suite *IntegrationTestSuite) TestCheckout() {
+	token := suite.login("userK", "passK").Token
+	checkout := func(body string, out interface{}) int {
+		req, err := http.NewRequest("POST", suite.server.URL+"/api/checkout", strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	var order models.Order
+	suite.Require().Equal(http.StatusCreated,
+		checkout(`{"lines": [{"item": "pen", "quantity": 10}, {"item": "cup", "quantity": 1}]}`, &order))
+	suite.Equal(10*10+20, order.Total)
+	suite.Equal([]models.OrderLine{
+		{Item: "cup", UnitPrice: 20, Quantity: 1},
+		{Item: "pen", UnitPrice: 10, Quantity: 10},
+	}, order.Lines)
+
+	suite.Equal(http.StatusBadRequest,
+		checkout(`{"lines": [{"item": "pen", "quantity": 1}, {"item": "no-such-item", "quantity": 1}]}`, nil))
+	suite.Equal(http.StatusBadRequest, checkout(`{"lines": [{"item": "pen", "quantity": 0}]}`, nil))
+
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/info", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+	var info models.InfoResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&info))
+	suite.Require().NoError(resp.Body.Close())
+
+	suite.Equal(1000-120, info.Coins, "a failed checkout must not charge anything")
+	suite.ElementsMatch([]models.Item{{Type: "pen", Quantity: 10}, {Type: "cup", Quantity: 1}}, info.Inventory)
+	suite.Require().Len(info.RecentPurchases, 1)
+	suite.Len(info.RecentPurchases[0].Lines, 2)
+}
+
+func (suite *IntegrationTestSuite) TestOrdersHistory() {
+	token := suite.login("userO", "passO").Token
+	do := func(method, path string, out interface{}) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, nil)
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	bought := []string{"pen", "cup", "book", "pen", "socks", "wallet", "cup"}
+	for _, item := range bought {
+		suite.Require().Equal(http.StatusOK, do("POST", "/api/buy/"+item, nil), item)
+	}
+
+	var items []string
+	path := "/api/orders?limit=3"
+	for {
+		var page models.OrdersResponse
+		suite.Require().Equal(http.StatusOK, do("GET", path, &page))
+		suite.LessOrEqual(len(page.Orders), 3)
+		for _, order := range page.Orders {
+			items = append(items, order.Lines[0].Item)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		path = "/api/orders?limit=3&cursor=" + page.NextCursor
+	}
+	suite.Equal([]string{"cup", "wallet", "socks", "pen", "book", "cup", "pen"}, items)
+
+	var cups models.OrdersResponse
+	suite.Require().Equal(http.StatusOK, do("GET", "/api/orders?item=cup", &cups))
+	suite.Require().Len(cups.Orders, 2)
+	suite.Equal(20, cups.Orders[0].Lines[0].UnitPrice)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	var none models.OrdersResponse
+	suite.Require().Equal(http.StatusOK, do("GET", "/api/orders?from="+future, &none))
+	suite.Empty(none.Orders)
+	suite.Equal(http.StatusBadRequest, do("GET", "/api/orders?cursor=broken", nil))
+
+	var info models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do("GET", "/api/info", &info))
+	suite.Require().Len(info.RecentPurchases, 5)
+	suite.Equal("cup", info.RecentPurchases[0].Lines[0].Item)
+}
+
+func (suite *IntegrationTestSuite) TestCatalog() {
+	resp, err := suite.client.Get(suite.server.URL + "/api/items")
+	suite.Require().NoError(err)
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	var catalog models.CatalogResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&catalog))
+	suite.Require().NoError(resp.Body.Close())
+
+	prices := make(map[string]int)
+	for _, item := range catalog.Items {
+		prices[item.Name] = item.Price
+		suite.NotEmpty(item.Description, item.Name)
+	}
+	suite.Equal(80, prices["t-shirt"])
+	suite.Equal(500, prices["pink-hoody"])
+
+	tag := resp.Header.Get("ETag")
+	suite.Require().NotEmpty(tag)
+
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/items", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("If-None-Match", tag)
+	resp, err = suite.client.Do(req)
+	suite.Require().NoError(err)
+	suite.Require().NoError(resp.Body.Close())
+	suite.Equal(http.StatusNotModified, resp.StatusCode)
+}
+
 func (suite *IntegrationTestSuite) TestSendCoin() {
 	reqBody := `{"username": "userB", "password": "passB"}`
 
@@ -64,8 +263,7 @@ func (suite *IntegrationTestSuite) TestSendCoin() {
 	suite.NotEmpty(authResp.Token)
 	suite.tokenB = authResp.Token
 
-	_, err = suite.db.Exec("UPDATE users SET coins = 100 WHERE username = $1", "userA")
-	suite.Require().NoError(err)
+	suite.setBalance("userA", 100)
 
 	sendReqBody := `{"toUser": "userB", "amount": 50}`
 	req, err := http.NewRequest("POST", suite.server.URL+"/api/sendCoin", strings.NewReader(sendReqBody))
@@ -110,3 +308,578 @@ func (suite *IntegrationTestSuite) TestSendCoin() {
 	suite.Require().NoError(err)
 	suite.Equal(50, infoB.Coins)
 }
+
+func (suite *IntegrationTestSuite) TestIdempotentRetries() {
+	sender := suite.login("userR1", "passR1").Token
+	suite.login("userR2", "passR2")
+	do := func(method, path, body, key string) *http.Response {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+sender)
+		req.Header.Set("Idempotency-Key", key)
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		suite.Require().NoError(resp.Body.Close())
+		return resp
+	}
+
+	for i := 0; i < 3; i++ {
+		resp := do("POST", "/api/sendCoin", `{"toUser": "userR2", "amount": 100}`, "transfer-1")
+		suite.Equal(http.StatusOK, resp.StatusCode)
+		suite.Equal(i > 0, resp.Header.Get("Idempotent-Replayed") == "true")
+	}
+	suite.Equal(http.StatusUnprocessableEntity,
+		do("POST", "/api/sendCoin", `{"toUser": "userR2", "amount": 200}`, "transfer-1").StatusCode)
+
+	for i := 0; i < 2; i++ {
+		suite.Equal(http.StatusOK, do("POST", "/api/buy/cup", "", "cup-1").StatusCode)
+	}
+	suite.Equal(http.StatusUnprocessableEntity, do("POST", "/api/buy/pen", "", "cup-1").StatusCode)
+
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/info", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+sender)
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+	var info models.InfoResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&info))
+	suite.Require().NoError(resp.Body.Close())
+
+	suite.Equal(1000-100-20, info.Coins)
+	suite.Equal([]models.Item{{Type: "cup", Quantity: 1}}, info.Inventory)
+}
+
+// adminRequest sends a request with the admin token and decodes the JSON answer into out.
+func (suite *IntegrationTestSuite) adminRequest(method, path, body, idempotencyKey string, out interface{}) int {
+	req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+	suite.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+suite.adminToken)
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+	if out != nil {
+		suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+	}
+	suite.Require().NoError(resp.Body.Close())
+	return resp.StatusCode
+}
+
+func (suite *IntegrationTestSuite) userId(username string) int {
+	var user models.AdminUserResponse
+	suite.Require().Equal(http.StatusOK, suite.adminRequest("GET", "/api/admin/users/"+username, "", "", &user))
+	return user.ID
+}
+
+func (suite *IntegrationTestSuite) setBalance(username string, balance int) {
+	path := fmt.Sprintf("/api/admin/users/%d/coins", suite.userId(username))
+	body := fmt.Sprintf(`{"balance": %d, "reason": "integration test seed"}`, balance)
+	key := fmt.Sprintf("seed-%s-%d", username, time.Now().UnixNano())
+	suite.Require().Equal(http.StatusOK, suite.adminRequest("PUT", path, body, key, nil))
+}
+
+func (suite *IntegrationTestSuite) TestAdminGrantIsIdempotent() {
+	suite.login("userG", "passG")
+
+	path := fmt.Sprintf("/api/admin/users/%d/coins/grant", suite.userId("userG"))
+	key := fmt.Sprintf("grant-%d", time.Now().UnixNano())
+
+	var first, second models.BalanceAdjustmentResponse
+	suite.Equal(http.StatusOK, suite.adminRequest("POST", path, `{"amount": 30, "reason": "prize"}`, key, &first))
+	suite.Equal(http.StatusOK, suite.adminRequest("POST", path, `{"amount": 30, "reason": "prize"}`, key, &second))
+	suite.Equal(first, second)
+	suite.Equal(1030, second.Balance)
+
+	suite.Equal(http.StatusUnprocessableEntity, suite.adminRequest("POST", path, `{"amount": 40, "reason": "prize"}`, key, nil))
+	suite.Equal(http.StatusBadRequest, suite.adminRequest("POST", path, `{"amount": 40}`, key+"-2", nil))
+
+	token := suite.login("userG", "passG").Token
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/info", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+
+	var info models.InfoResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&info))
+	suite.Require().NoError(resp.Body.Close())
+
+	suite.Equal(1030, info.Coins)
+	suite.Require().NotEmpty(info.CoinHistory.Received)
+	newest := info.CoinHistory.Received[0]
+	suite.Equal("system", newest.FromUser)
+	suite.Equal(models.CoinTxGrant, newest.Kind)
+	suite.Equal("prize", newest.Reason)
+}
+
+func (suite *IntegrationTestSuite) TestCatalogManagement() {
+	name := fmt.Sprintf("sticker-%d", time.Now().UnixNano())
+	token := suite.login("userM", "passM").Token
+	buy := func() int {
+		req, err := http.NewRequest("POST", suite.server.URL+"/api/buy/"+name, nil)
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	var created models.CatalogItem
+	suite.Require().Equal(http.StatusCreated, suite.adminRequest("POST", "/api/admin/items",
+		`{"name": "`+name+`", "price": 15, "description": "Стикеры"}`, "", &created))
+	suite.Equal(15, created.Price)
+	suite.Equal(http.StatusConflict, suite.adminRequest("POST", "/api/admin/items", `{"name": "`+name+`", "price": 15}`, "", nil))
+
+	suite.Equal(http.StatusOK, buy())
+	suite.Equal(http.StatusOK, suite.adminRequest("PUT", "/api/admin/items/"+name+"/price", `{"price": 25}`, "", nil))
+	suite.Equal(http.StatusOK, buy())
+
+	var prices []models.ItemPrice
+	suite.Require().Equal(http.StatusOK, suite.adminRequest("GET", "/api/admin/items/"+name+"/prices", "", "", &prices))
+	suite.Require().Len(prices, 2)
+	suite.Equal(15, prices[0].Price)
+	suite.Equal(25, prices[1].Price)
+
+	suite.Equal(http.StatusOK, suite.adminRequest("DELETE", "/api/admin/items/"+name, "", "", nil))
+	suite.Equal(http.StatusNotFound, suite.adminRequest("DELETE", "/api/admin/items/"+name, "", "", nil))
+	suite.Equal(http.StatusBadRequest, buy())
+
+	req, err := http.NewRequest("GET", suite.server.URL+"/api/info", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := suite.client.Do(req)
+	suite.Require().NoError(err)
+	var info models.InfoResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&info))
+	suite.Require().NoError(resp.Body.Close())
+
+	suite.Contains(info.Inventory, models.Item{Type: name, Quantity: 2})
+	suite.Require().Len(info.RecentPurchases, 2)
+	suite.Equal(25, info.RecentPurchases[0].Lines[0].UnitPrice)
+	suite.Equal(15, info.RecentPurchases[1].Lines[0].UnitPrice)
+
+	resp, err = suite.client.Get(suite.server.URL + "/api/items")
+	suite.Require().NoError(err)
+	var catalog models.CatalogResponse
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&catalog))
+	suite.Require().NoError(resp.Body.Close())
+	for _, item := range catalog.Items {
+		suite.NotEqual(name, item.Name, "retired items are not listed")
+	}
+}
+
+func (suite *IntegrationTestSuite) TestLimitedDrop() {
+	name := fmt.Sprintf("drop-%d", time.Now().UnixNano())
+	suite.Require().Equal(http.StatusCreated, suite.adminRequest("POST", "/api/admin/items",
+		`{"name": "`+name+`", "price": 10, "stock": 3, "perUserLimit": 2}`, "", nil))
+
+	buy := func(token string) int {
+		req, err := http.NewRequest("POST", suite.server.URL+"/api/buy/"+name, nil)
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	first := suite.login("userL1", "passL1").Token
+	second := suite.login("userL2", "passL2").Token
+
+	suite.Equal(http.StatusOK, buy(first))
+	suite.Equal(http.StatusOK, buy(first))
+	suite.Equal(http.StatusConflict, buy(first), "per user limit")
+	suite.Equal(http.StatusOK, buy(second))
+	suite.Equal(http.StatusConflict, buy(second), "sold out")
+
+	var item models.CatalogItem
+	suite.Require().Equal(http.StatusOK, suite.adminRequest("PUT", "/api/admin/items/"+name+"/limits", `{"stock": null}`, "", &item))
+	suite.Nil(item.Stock)
+	suite.True(item.Available)
+	suite.Equal(http.StatusOK, buy(second))
+}
+
+func (suite *IntegrationTestSuite) TestReturnFlow() {
+	token := suite.login("userV", "passV").Token
+	do := func(method, path, body string, out interface{}) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	var order models.Order
+	suite.Require().Equal(http.StatusCreated,
+		do("POST", "/api/checkout", `{"lines": [{"item": "cup", "quantity": 2}, {"item": "pen", "quantity": 1}]}`, &order))
+
+	var ret models.Return
+	path := fmt.Sprintf("/api/orders/%d/return", order.ID)
+	suite.Require().Equal(http.StatusCreated, do("POST", path, `{"reason": "ordered twice"}`, &ret))
+	suite.Equal(models.ReturnPending, ret.Status)
+	suite.Equal(order.Total, ret.Amount)
+	suite.Equal(http.StatusConflict, do("POST", path, `{"reason": "ordered twice"}`, nil))
+
+	var pending models.ReturnsResponse
+	suite.Require().Equal(http.StatusOK, suite.adminRequest("GET", "/api/admin/returns?status=pending", "", "", &pending))
+	suite.Contains(pending.Returns, ret)
+
+	approvePath := fmt.Sprintf("/api/admin/returns/%d/approve", ret.ID)
+	suite.Require().Equal(http.StatusOK, suite.adminRequest("POST", approvePath, `{"note": "ok"}`, "", &ret))
+	suite.Equal(models.ReturnApproved, ret.Status)
+	suite.Equal(http.StatusConflict, suite.adminRequest("POST", approvePath, "", "", nil))
+
+	var info models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do("GET", "/api/info", "", &info))
+	suite.Equal(1000, info.Coins)
+	suite.Empty(info.Inventory)
+	suite.Require().NotEmpty(info.CoinHistory.Received)
+	refund := info.CoinHistory.Received[0]
+	suite.Equal(models.ReceivedTransaction{
+		ID: refund.ID, FromUser: "system", Amount: order.Total, Kind: models.CoinTxRefund,
+		Reason: fmt.Sprintf("return of order #%d", order.ID), Timestamp: refund.Timestamp,
+	}, refund)
+}
+
+func (suite *IntegrationTestSuite) TestGift() {
+	sender := suite.login("userW1", "passW1").Token
+	recipient := suite.login("userW2", "passW2").Token
+	do := func(token, method, path, body string, out interface{}) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	var order models.Order
+	suite.Require().Equal(http.StatusCreated,
+		do(sender, "POST", "/api/gift", `{"toUser": "userW2", "item": "cup", "quantity": 2}`, &order))
+	suite.Equal("userW2", order.GiftTo)
+	suite.Equal(40, order.Total)
+
+	suite.Equal(http.StatusBadRequest, do(sender, "POST", "/api/gift", `{"toUser": "userW1", "item": "cup"}`, nil))
+	suite.Equal(http.StatusBadRequest, do(sender, "POST", "/api/gift", `{"toUser": "no-such-user", "item": "cup"}`, nil))
+	suite.Equal(http.StatusConflict,
+		do(sender, "POST", fmt.Sprintf("/api/orders/%d/return", order.ID), `{"reason": "changed my mind"}`, nil))
+
+	var senderInfo models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do(sender, "GET", "/api/info", "", &senderInfo))
+	suite.Equal(1000-40, senderInfo.Coins, "a failed gift must not charge anything")
+	suite.Empty(senderInfo.Inventory)
+	suite.Require().Len(senderInfo.RecentPurchases, 1)
+	suite.Equal("userW2", senderInfo.RecentPurchases[0].GiftTo)
+
+	var recipientInfo models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do(recipient, "GET", "/api/info", "", &recipientInfo))
+	suite.Equal(1000, recipientInfo.Coins)
+	suite.Equal([]models.Item{{Type: "cup", Quantity: 2}}, recipientInfo.Inventory)
+	suite.Require().Len(recipientInfo.ReceivedGifts, 1)
+	suite.Equal("userW1", recipientInfo.ReceivedGifts[0].FromUser)
+	suite.Equal("cup", recipientInfo.ReceivedGifts[0].Item)
+	suite.Equal(2, recipientInfo.ReceivedGifts[0].Quantity)
+}
+
+func (suite *IntegrationTestSuite) TestInventoryTransfer() {
+	sender := suite.login("userX1", "passX1").Token
+	recipient := suite.login("userX2", "passX2").Token
+	do := func(token, method, path, body string, out interface{}) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	suite.Require().Equal(http.StatusCreated,
+		do(sender, "POST", "/api/checkout", `{"lines": [{"item": "pen", "quantity": 3}]}`, nil))
+
+	var transfer models.ItemTransfer
+	suite.Require().Equal(http.StatusOK,
+		do(sender, "POST", "/api/inventory/transfer", `{"toUser": "userX2", "item": "pen", "quantity": 2}`, &transfer))
+	suite.NotZero(transfer.ID)
+	suite.Equal(2, transfer.Quantity)
+
+	suite.Equal(http.StatusBadRequest,
+		do(sender, "POST", "/api/inventory/transfer", `{"toUser": "userX2", "item": "pen", "quantity": 2}`, nil))
+	suite.Equal(http.StatusBadRequest,
+		do(sender, "POST", "/api/inventory/transfer", `{"toUser": "userX1", "item": "pen", "quantity": 1}`, nil))
+	suite.Equal(http.StatusBadRequest,
+		do(recipient, "POST", "/api/inventory/transfer", `{"toUser": "userX1", "item": "cup", "quantity": 1}`, nil))
+
+	var senderInfo, recipientInfo models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do(sender, "GET", "/api/info", "", &senderInfo))
+	suite.Require().Equal(http.StatusOK, do(recipient, "GET", "/api/info", "", &recipientInfo))
+	suite.Equal([]models.Item{{Type: "pen", Quantity: 1}}, senderInfo.Inventory)
+	suite.Equal([]models.Item{{Type: "pen", Quantity: 2}}, recipientInfo.Inventory)
+	suite.Equal(1000, recipientInfo.Coins, "a transfer moves items, not coins")
+
+	var ledger int
+	suite.Require().NoError(suite.db.QueryRow(
+		`SELECT COUNT(*) FROM inventory_transfers WHERE sender_id = $1 AND receiver_id = $2 AND item_type = 'pen'`,
+		suite.userId("userX1"), suite.userId("userX2")).Scan(&ledger))
+	suite.Equal(1, ledger)
+}
+
+func (suite *IntegrationTestSuite) TestTransactionsHistory() {
+	token := suite.login("userY1", "passY1").Token
+	suite.login("userY2", "passY2")
+	do := func(method, path, body string, out interface{}) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	for _, amount := range []int{5, 15, 25} {
+		suite.Require().Equal(http.StatusOK,
+			do("POST", "/api/sendCoin", fmt.Sprintf(`{"toUser": "userY2", "amount": %d}`, amount), nil))
+	}
+
+	var amounts []int
+	path := "/api/transactions?direction=out&counterparty=userY2&limit=2"
+	for pages := 0; pages < 5; pages++ {
+		var page models.TransactionsResponse
+		suite.Require().Equal(http.StatusOK, do("GET", path, "", &page))
+		for _, tx := range page.Transactions {
+			suite.Equal(models.DirectionOut, tx.Direction)
+			suite.Equal("userY2", tx.Counterparty)
+			amounts = append(amounts, tx.Amount)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		path = "/api/transactions?direction=out&counterparty=userY2&limit=2&cursor=" + page.NextCursor
+	}
+	suite.Equal([]int{25, 15, 5}, amounts, "newest first across pages")
+
+	var ranged models.TransactionsResponse
+	suite.Require().Equal(http.StatusOK, do("GET", "/api/transactions?direction=out&minAmount=10&maxAmount=20", "", &ranged))
+	suite.Require().Len(ranged.Transactions, 1)
+	suite.Equal(15, ranged.Transactions[0].Amount)
+
+	suite.Equal(http.StatusBadRequest, do("GET", "/api/transactions?direction=sideways", "", nil))
+
+	suite.Require().Equal(http.StatusOK,
+		do("POST", "/api/sendCoin", `{"toUser": "userY2", "amount": 1, "memo": " team lunch\n"}`, nil))
+	suite.Equal(http.StatusBadRequest,
+		do("POST", "/api/sendCoin", fmt.Sprintf(`{"toUser": "userY2", "amount": 1, "memo": %q}`, strings.Repeat("a", 201)), nil))
+
+	var info models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do("GET", "/api/info", "", &info))
+	suite.Require().NotEmpty(info.CoinHistory.Sent)
+	newest := info.CoinHistory.Sent[0]
+	suite.Equal("team lunch", newest.Memo)
+	suite.NotZero(newest.ID)
+	suite.False(newest.Timestamp.IsZero())
+}
+
+func (suite *IntegrationTestSuite) TestLedgerMatchesBalances() {
+	token := suite.login("userZ1", "passZ1").Token
+	suite.login("userZ2", "passZ2")
+	do := func(method, path, body string) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	suite.Require().Equal(http.StatusCreated, do("POST", "/api/checkout", `{"lines": [{"item": "cup", "quantity": 1}]}`))
+	suite.Require().Equal(http.StatusOK, do("POST", "/api/sendCoin", `{"toUser": "userZ2", "amount": 30}`))
+	suite.setBalance("userZ2", 500)
+
+	var unbalanced int
+	suite.Require().NoError(suite.db.Get(&unbalanced, `
+		select count(*) from (select entry_id from postings group by entry_id having sum(amount) <> 0) e`))
+	suite.Zero(unbalanced)
+
+	var drifted []string
+	suite.Require().NoError(suite.db.Select(&drifted, `
+		select u.username from users u
+		left join (select user_id, sum(amount) as amount from postings where user_id is not null group by user_id) p
+			on p.user_id = u.id
+		where u.coins <> coalesce(p.amount, 0)`))
+	suite.Empty(drifted)
+
+	var revenue int
+	suite.Require().NoError(suite.db.Get(&revenue, `
+		select coalesce(sum(amount), 0) from postings where account = 'shop_revenue'`))
+	suite.Positive(revenue)
+}
+
+func (suite *IntegrationTestSuite) TestReconcileBalances() {
+	suite.login("userR", "passR")
+	userId := suite.userId("userR")
+	_, err := suite.db.Exec(`update users set coins = coins + 70 where id = $1`, userId)
+	suite.Require().NoError(err)
+
+	reconciler := service.NewReconcileService(store.NewLedgerStore(suite.db))
+	ctx := context.Background()
+
+	report, err := reconciler.Reconcile(ctx, false)
+	suite.Require().NoError(err)
+	suite.True(report.DryRun)
+	suite.Positive(report.Checked)
+	suite.Contains(report.Drifted, models.BalanceDrift{UserID: userId, Username: "userR", Coins: 1070, Expected: 1000, Drift: 70})
+
+	report, err = reconciler.Reconcile(ctx, true)
+	suite.Require().NoError(err)
+	suite.Contains(report.Drifted, models.BalanceDrift{UserID: userId, Username: "userR", Coins: 1070, Expected: 1000, Drift: 70, Fixed: true})
+
+	report, err = reconciler.Reconcile(ctx, false)
+	suite.Require().NoError(err)
+	suite.Empty(report.Drifted)
+}
+
+func (suite *IntegrationTestSuite) TestCoinRequests() {
+	requester := suite.login("userCR1", "passCR1").Token
+	payer := suite.login("userCR2", "passCR2").Token
+	do := func(token, method, path, body string, out interface{}) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	var request models.CoinRequest
+	suite.Require().Equal(http.StatusCreated,
+		do(requester, "POST", "/api/coinRequests", `{"fromUser": "userCR2", "amount": 40, "memo": "pizza"}`, &request))
+	suite.Equal(models.CoinRequestPending, request.Status)
+	suite.Equal(http.StatusBadRequest, do(requester, "POST", "/api/coinRequests", `{"fromUser": "userCR1", "amount": 40}`, nil))
+	suite.Equal(http.StatusBadRequest, do(requester, "POST", "/api/coinRequests", `{"fromUser": "no-such-user", "amount": 40}`, nil))
+
+	var payerInfo models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do(payer, "GET", "/api/info", "", &payerInfo))
+	suite.Equal([]models.CoinRequest{request}, payerInfo.CoinRequests.Incoming)
+	suite.Empty(payerInfo.CoinRequests.Outgoing)
+
+	acceptPath := fmt.Sprintf("/api/coinRequests/%d/accept", request.ID)
+	suite.Equal(http.StatusNotFound, do(requester, "POST", acceptPath, "", nil), "only the payer decides")
+	suite.Require().Equal(http.StatusOK, do(payer, "POST", acceptPath, "", &request))
+	suite.Equal(models.CoinRequestAccepted, request.Status)
+	suite.Equal(http.StatusConflict, do(payer, "POST", acceptPath, "", nil))
+
+	var requesterInfo models.InfoResponse
+	suite.Require().Equal(http.StatusOK, do(requester, "GET", "/api/info", "", &requesterInfo))
+	suite.Equal(1000+40, requesterInfo.Coins)
+	suite.Empty(requesterInfo.CoinRequests.Outgoing)
+	suite.Require().NotEmpty(requesterInfo.CoinHistory.Received)
+	suite.Equal("userCR2", requesterInfo.CoinHistory.Received[0].FromUser)
+	suite.Equal("pizza", requesterInfo.CoinHistory.Received[0].Memo)
+
+	suite.Require().Equal(http.StatusCreated,
+		do(requester, "POST", "/api/coinRequests", `{"fromUser": "userCR2", "amount": 10}`, &request))
+	declinePath := fmt.Sprintf("/api/coinRequests/%d/decline", request.ID)
+	suite.Require().Equal(http.StatusOK, do(payer, "POST", declinePath, "", &request))
+	suite.Equal(models.CoinRequestDeclined, request.Status)
+
+	suite.Require().Equal(http.StatusCreated,
+		do(requester, "POST", "/api/coinRequests", `{"fromUser": "userCR2", "amount": 10}`, &request))
+	_, err := suite.db.Exec(`update coin_requests set expires_at = now() where id = $1`, request.ID)
+	suite.Require().NoError(err)
+	suite.Equal(http.StatusConflict, do(payer, "POST", fmt.Sprintf("/api/coinRequests/%d/accept", request.ID), "", nil))
+
+	suite.Require().Equal(http.StatusOK, do(payer, "GET", "/api/info", "", &payerInfo))
+	suite.Equal(1000-40, payerInfo.Coins)
+	suite.Empty(payerInfo.CoinRequests.Incoming)
+}
+
+func (suite *IntegrationTestSuite) TestDeleteUserKeepsLedger() {
+	token := suite.login("userDL1", "passDL1").Token
+	suite.login("userDL2", "passDL2")
+	do := func(method, path, body string) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	suite.Require().Equal(http.StatusCreated, do("POST", "/api/checkout", `{"lines": [{"item": "cup", "quantity": 1}]}`))
+	suite.Require().Equal(http.StatusOK, do("POST", "/api/sendCoin", `{"toUser": "userDL2", "amount": 30}`))
+
+	_, err := suite.db.Exec(`delete from users where username = $1`, "userDL1")
+	suite.Require().NoError(err)
+
+	var unbalanced int
+	suite.Require().NoError(suite.db.Get(&unbalanced, `
+		select count(*) from (select entry_id from postings group by entry_id having sum(amount) <> 0) e`))
+	suite.Zero(unbalanced)
+}
+
+func (suite *IntegrationTestSuite) TestBuyAgainAfterReturn() {
+	name := fmt.Sprintf("drop-%d", time.Now().UnixNano())
+	suite.Require().Equal(http.StatusCreated, suite.adminRequest("POST", "/api/admin/items",
+		`{"name": "`+name+`", "price": 10, "perUserLimit": 1}`, "", nil))
+
+	token := suite.login("userLR", "passLR").Token
+	do := func(method, path, body string, out interface{}) int {
+		req, err := http.NewRequest(method, suite.server.URL+path, strings.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := suite.client.Do(req)
+		suite.Require().NoError(err)
+		if out != nil {
+			suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+		}
+		suite.Require().NoError(resp.Body.Close())
+		return resp.StatusCode
+	}
+
+	var order models.Order
+	suite.Require().Equal(http.StatusCreated, do("POST", "/api/checkout", `{"lines": [{"item": "`+name+`", "quantity": 1}]}`, &order))
+	suite.Equal(http.StatusConflict, do("POST", "/api/buy/"+name, "", nil), "per user limit")
+
+	var ret models.Return
+	suite.Require().Equal(http.StatusCreated, do("POST", fmt.Sprintf("/api/orders/%d/return", order.ID), `{"reason": "too small"}`, &ret))
+	suite.Equal(http.StatusConflict, do("POST", "/api/buy/"+name, "", nil), "a pending return still counts")
+	suite.Require().Equal(http.StatusOK, suite.adminRequest("POST", fmt.Sprintf("/api/admin/returns/%d/approve", ret.ID), "", "", nil))
+
+	suite.Equal(http.StatusOK, do("POST", "/api/buy/"+name, "", nil))
+	suite.Equal(http.StatusConflict, do("POST", "/api/buy/"+name, "", nil), "per user limit")
+}